@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -10,6 +11,9 @@ import (
 	"github.com/SheltonZhu/115driver/pkg/driver"
 )
 
+// 所有请求使用的User-Agent, 下载链接与其绑定
+const userAgent = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/138.0.0.0 Safari/537.36"
+
 // 文件列表响应
 type ListResponse struct {
 	Success bool       `json:"success"`
@@ -79,25 +83,20 @@ func main() {
 
 
 func initClient(cookiesFile string) (*driver.Pan115Client, error) {
-	// 检查cookies文件是否存在
-	if _, err := os.Stat(cookiesFile); os.IsNotExist(err) {
+	// 读取并解析cookies文件
+	cr, err := driver.LoadCredential(driver.FromFile(cookiesFile))
+	if errors.Is(err, driver.ErrCredentialNotFound) {
 		return nil, fmt.Errorf("cookies文件不存在: %s", cookiesFile)
 	}
-
-	// 读取cookies文件
-	cookieData, err := os.ReadFile(cookiesFile)
 	if err != nil {
-		return nil, fmt.Errorf("读取cookies文件失败: %v", err)
-	}
-
-	// 解析cookies
-	cr := &driver.Credential{}
-	if err := cr.FromCookie(strings.TrimSpace(string(cookieData))); err != nil {
 		return nil, fmt.Errorf("解析cookies失败: %v", err)
 	}
+	if err := cr.Validate(); err != nil {
+		return nil, fmt.Errorf("cookies格式错误: %v", err)
+	}
 
 	// 创建客户端
-	client := driver.Defalut().ImportCredential(cr)
+	client := driver.New(driver.UA(userAgent)).ImportCredential(cr)
 	
 	// 检查登录状态
 	if err := client.LoginCheck(); err != nil {
@@ -212,43 +211,23 @@ func handlePlay(client *driver.Pan115Client, filePath string) {
 		dirPath = "/"
 	}
 
-	// 【优化】使用DirName2CID替代resolvePath
-	var dirCid string
-	if dirPath == "/" {
-		dirCid = "0"
-	} else {
-		result, err := client.DirName2CID(dirPath)
-		if err != nil {
-			outputError("解析目录路径失败: " + err.Error())
-			return
-		}
-		dirCid = string(result.CategoryID)
+	// 按路径查找文件, 不受目录文件数量限制
+	targetFile, err := client.StatByPath(filePath)
+	if errors.Is(err, driver.ErrNotExist) {
+		outputError("文件不存在: " + fileName)
+		return
 	}
-
-	// 【优化】使用排序版本保持一致性
-	files, err := getFilesSortedByName(client, dirCid, 1000)
 	if err != nil {
-		outputError("获取目录内容失败: " + err.Error())
+		outputError("解析文件路径失败: " + err.Error())
 		return
 	}
-
-	// 查找指定文件
-	var targetFile *driver.File
-	for _, file := range *files {
-		if !file.IsDirectory && file.Name == fileName {
-			targetFile = &file
-			break
-		}
-	}
-
-	if targetFile == nil {
-		outputError("文件不存在: " + fileName)
+	if targetFile.IsDirectory {
+		outputError("不是文件: " + fileName)
 		return
 	}
 
-	// 获取下载链接
-	userAgent := "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/138.0.0.0 Safari/537.36"
-	downloadInfo, err := client.DownloadWithUA(targetFile.PickCode, userAgent)
+	// 获取播放链接
+	playURL, err := client.GetPlayURL(targetFile.PickCode, "")
 	if err != nil {
 		outputError("获取下载链接失败: " + err.Error())
 		return
@@ -263,8 +242,8 @@ func handlePlay(client *driver.Pan115Client, filePath string) {
 	// 构建响应
 	response := PlayResponse{
 		Success:       true,
-		URL:           downloadInfo.Url.Url,
-		UserAgent:     userAgent,
+		URL:           playURL.URL,
+		UserAgent:     playURL.Headers["User-Agent"],
 		DirPath:       dirPath,
 		FilenameNoExt: filenameNoExt,
 	}
@@ -3,9 +3,12 @@ package m115
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"io"
 )
 
+var ErrInvalidData = errors.New("m115: invalid data")
+
 type Key [16]byte
 
 func GenerateKey() Key {
@@ -37,6 +40,10 @@ func Decode(input string, key Key) (output []byte, err error) {
 	}
 	// RSA decrypt
 	data = rsaDecrypt(data)
+	if len(data) < 16 {
+		err = ErrInvalidData
+		return
+	}
 	// XOR decode
 	output = make([]byte, len(data)-16)
 	copy(output, data[16:])
@@ -17,6 +17,9 @@ const (
 	ApiFileCopy      = "https://webapi.115.com/files/copy"
 	ApiFileRename    = "https://webapi.115.com/files/batch_rename"
 	ApiFileIndexInfo = "https://webapi.115.com/files/index_info"
+	ApiFileStar      = "https://webapi.115.com/files/star"
+	ApiFileUpdate    = "https://webapi.115.com/files/update"
+	ApiHiddenSwitch  = "https://115.com/?ct=hiddenfiles&ac=switching"
 
 	ApiFileList       = "https://webapi.115.com/files"
 	ApiFileList1       = "http://web.api.115.com/files"
@@ -24,22 +27,41 @@ const (
 	// ApiFileList3       = "http://v.anxia.com/webapi/files"
 	ApiFileListByName = "https://aps.115.com/natsort/files.php"
 
-	ApiFileStat = "https://webapi.115.com/category/get"
-	ApiFileInfo = "https://webapi.115.com/files/get_info"
+	ApiFileSearch     = "https://webapi.115.com/files/search"
+	ApiFileSHA1Search = "https://webapi.115.com/files/shasearch"
+
+	ApiFileStat  = "https://webapi.115.com/category/get"
+	ApiFileInfo  = "https://webapi.115.com/files/get_info"
+	ApiFileImage = "https://webapi.115.com/files/image"
+
+	// label
+	ApiLabelList   = "https://webapi.115.com/label/list"
+	ApiLabelAdd    = "https://webapi.115.com/label/add_multi"
+	ApiLabelDelete = "https://webapi.115.com/label/delete"
+	ApiFileEdit    = "https://webapi.115.com/files/edit"
 
 	// share
-	ApiShareSnap = "https://webapi.115.com/share/snap"
+	ApiShareSnap   = "https://webapi.115.com/share/snap"
+	ApiShareSend   = "https://webapi.115.com/share/send"
+	ApiShareUpdate = "https://webapi.115.com/share/updateshare"
+	ApiShareSave   = "https://webapi.115.com/share/receive"
 
 	// download
 	ApiDownloadGetUrl        = "https://proapi.115.com/app/chrome/downurl"
 	ApiDownloadGetShareUrl   = "https://proapi.115.com/app/share/downurl"
 	AndroidApiDownloadGetUrl = "https://proapi.115.com/android/2.0/ufile/download"
 
+	// video
+	ApiVideoM3U8     = "https://115.com/api/video/m3u8/%s.m3u8"
+	ApiVideoInfo     = "https://webapi.115.com/files/video"
+	ApiVideoSubtitle = "https://webapi.115.com/movies/subtitle"
+
 	// offline download
 	ApiAddOfflineUrl   = "https://lixian.115.com/lixianssp/?ac=add_task_urls"
 	ApiDelOfflineUrl   = "https://lixian.115.com/lixian/?ct=lixian&ac=task_del"
 	ApiListOfflineUrl  = "https://lixian.115.com/lixian/?ct=lixian&ac=task_lists"
 	ApiClearOfflineUrl = "https://lixian.115.com/lixian/?ct=lixian&ac=task_clear"
+	ApiOfflineSaveDir  = "https://webapi.115.com/offine/downpath"
 
 	// upload
 	ApiUploadInfo        = "https://proapi.115.com/app/uploadinfo"
@@ -56,6 +78,11 @@ const (
 	ApiQrcodeLoginWithApp = "https://passportapi.115.com/app/1.0/%s/1.0/login/qrcode"
 	ApiQrcodeImage        = "https://qrcodeapi.115.com/api/1.0/mac/1.0/qrcode?uid=%s"
 
+	// captcha
+	ApiCaptchaPage   = "https://captchaapi.115.com/?ac=security_code&type=web&cb=Close911"
+	ApiCaptchaSign   = "https://captchaapi.115.com/?ac=code&t=sign"
+	ApiCaptchaSubmit = "https://webapi.115.com/user/captcha"
+
 	// recycle
 	ApiRecycleList   = "https://webapi.115.com/rb"
 	ApiRecycleClean  = "https://webapi.115.com/rb/clean"
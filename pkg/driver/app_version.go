@@ -1,9 +1,17 @@
 package driver
 
+import "context"
+
 // GetAppVersion get app version (win, android, mac, mac_arc, etc...)
 func (c *Pan115Client) GetAppVersion() ([]AppVersion, error) {
+	return c.GetAppVersionContext(context.Background())
+}
+
+// GetAppVersionContext is like GetAppVersion but with a context.
+func (c *Pan115Client) GetAppVersionContext(ctx context.Context) ([]AppVersion, error) {
 	result := VersionResp{}
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetResult(&result).
 		ForceContentType("application/json;charset=UTF-8")
 
@@ -0,0 +1,75 @@
+package driver
+
+import (
+	"context"
+	"io"
+	"math"
+
+	"golang.org/x/time/rate"
+)
+
+// SetBandwidthLimit limits the total bandwidth of all uploads and downloads of
+// the client to bytesPerSec, 0 disables the limit. It applies to transfers
+// in flight as well.
+func (c *Pan115Client) SetBandwidthLimit(bytesPerSec int64) *Pan115Client {
+	lim := c.bandwidth.Load()
+	if lim == nil {
+		c.bandwidth.CompareAndSwap(nil, rate.NewLimiter(rate.Inf, 0))
+		lim = c.bandwidth.Load()
+	}
+	if bytesPerSec <= 0 {
+		lim.SetLimit(rate.Inf)
+		return c
+	}
+	burst := bytesPerSec
+	if burst > math.MaxInt32 {
+		burst = math.MaxInt32
+	}
+	lim.SetBurst(int(burst))
+	lim.SetLimit(rate.Limit(bytesPerSec))
+	return c
+}
+
+// limitReader returns r limited by the bandwidth limit of the client.
+func (c *Pan115Client) limitReader(ctx context.Context, r io.Reader) io.Reader {
+	r = c.countUpload(r)
+	lim := c.bandwidth.Load()
+	if lim == nil {
+		return r
+	}
+	return &limitedReader{ctx: ctx, r: r, lim: lim}
+}
+
+// limitReadCloser is like limitReader but keeps the Closer.
+func (c *Pan115Client) limitReadCloser(ctx context.Context, rc io.ReadCloser) io.ReadCloser {
+	rc = c.countDownload(rc)
+	lim := c.bandwidth.Load()
+	if lim == nil {
+		return rc
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{&limitedReader{ctx: ctx, r: rc, lim: lim}, rc}
+}
+
+type limitedReader struct {
+	ctx context.Context
+	r   io.Reader
+	lim *rate.Limiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if r.lim.Limit() != rate.Inf {
+		if b := r.lim.Burst(); b > 0 && len(p) > b {
+			p = p[:b]
+		}
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.lim.WaitN(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
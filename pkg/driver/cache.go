@@ -0,0 +1,118 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// respCache memoizes the results of List and Stat until they expire or one of
+// the ids they depend on is invalidated.
+type respCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*respEntry
+}
+
+type respEntry struct {
+	value interface{}
+	// ids are the directory and the files the value depends on.
+	ids     []string
+	expires time.Time
+}
+
+func newRespCache(ttl time.Duration) *respCache {
+	return &respCache{ttl: ttl, entries: make(map[string]*respEntry)}
+}
+
+func (rc *respCache) get(key string) (interface{}, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	e, ok := rc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expires) {
+		delete(rc.entries, key)
+		return nil, false
+	}
+	return e.value, true
+}
+
+func (rc *respCache) put(key string, value interface{}, ids ...string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.entries[key] = &respEntry{value: value, ids: ids, expires: time.Now().Add(rc.ttl)}
+}
+
+// remove drops the entries depending on any of ids.
+func (rc *respCache) remove(ids ...string) {
+	set := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for key, e := range rc.entries {
+		for _, id := range e.ids {
+			if _, ok := set[id]; ok {
+				delete(rc.entries, key)
+				break
+			}
+		}
+	}
+}
+
+// EnableCache caches the results of List, ListWithLimit, ListAll and Stat for
+// ttl, a ttl <= 0 disables the cache. Changes made through the client, e.g.
+// Move, Copy, Delete, Rename, Mkdir, StarFile, SetFileTime, uploads, saved
+// shares, restores and moved offline results, drop the results they affect.
+// Call InvalidateCID after changes made by others.
+func (c *Pan115Client) EnableCache(ttl time.Duration) *Pan115Client {
+	if ttl <= 0 {
+		c.respCache.Store(nil)
+		return c
+	}
+	c.respCache.Store(newRespCache(ttl))
+	return c
+}
+
+// InvalidateCID drops the cached results of the file or directory cid, the
+// listings containing it and, for a directory, the stats of its children.
+func (c *Pan115Client) InvalidateCID(cid string) {
+	c.invalidateCache(cid)
+}
+
+func (c *Pan115Client) invalidateCache(ids ...string) {
+	if rc := c.respCache.Load(); rc != nil && len(ids) > 0 {
+		rc.remove(ids...)
+	}
+}
+
+// listCached is like listUpTo but served by the cache if it is enabled.
+func (c *Pan115Client) listCached(ctx context.Context, dirID string, limit int64, max int, opts ...ListOption) ([]File, error) {
+	rc := c.respCache.Load()
+	if rc == nil {
+		return c.listUpTo(ctx, dirID, limit, max, opts...)
+	}
+	o := DefaultListOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	key := fmt.Sprintf("list:%s:%d:%d:%+v", dirID, limit, max, *o)
+	if v, ok := rc.get(key); ok {
+		return append([]File(nil), v.([]File)...), nil
+	}
+	files, err := c.listUpTo(ctx, dirID, limit, max, opts...)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(files)+1)
+	ids = append(ids, dirID)
+	for _, f := range files {
+		ids = append(ids, f.FileID)
+	}
+	rc.put(key, append([]File(nil), files...), ids...)
+	return files, nil
+}
@@ -2,18 +2,74 @@ package driver
 
 import (
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
 )
 
-// Pan115Client driver client
+// Pan115Client driver client. It is safe for concurrent use by multiple
+// goroutines once configured, every call builds its own request.
 type Pan115Client struct {
-	Client            *resty.Client
-	Request           *resty.Request
+	Client *resty.Client
+	// Deprecated: requests are not shared, see NewRequest.
+	Request *resty.Request
+	// UserID, Userkey and UploadMetaInfo are filled by login checks, shares,
+	// offline tasks and uploads, read them only while no call is running.
 	UserID            int64
 	Userkey           string
 	UploadMetaInfo    *UploadMetaInfo
 	UseInternalUpload bool
+	userMu            sync.RWMutex
+
+	refresher       CredentialRefresher
+	refresherHooked *resty.Client
+	refreshMu       sync.Mutex
+
+	retryPolicy    *RetryPolicy
+	retryHooked    *resty.Client
+	retryHookAdded *resty.Client
+
+	// customTransport makes OSS uploads share the http client.
+	customTransport bool
+
+	userAgent string
+
+	requestLogger func(RequestLog)
+	metrics       MetricsRecorder
+	loggerHooked  *resty.Client
+
+	timeout         time.Duration
+	transferTimeout time.Duration
+	timeoutHooked   *resty.Client
+
+	headersHooked *resty.Client
+
+	// cookies is swapped as a whole, requests read it in hookCookies.
+	cookies       atomic.Pointer[[]*http.Cookie]
+	cookiesMu     sync.Mutex
+	cookiesHooked *resty.Client
+
+	pathCache    *pathCache
+	pathCacheOff bool
+	pathCacheMu  sync.Mutex
+	mkdirMu      sync.Mutex
+	dirInfos     sync.Map
+	dirNodes     sync.Map
+
+	bandwidth atomic.Pointer[rate.Limiter]
+	dryRun    atomic.Pointer[dryRun]
+	respCache atomic.Pointer[respCache]
+
+	noOpPolicy NoOpPolicy
+
+	hiddenPassword string
+	hiddenShown    atomic.Bool
 }
 
 // New creates Client with customized options.
@@ -26,6 +82,10 @@ func New(opts ...Option) *Pan115Client {
 			optFunc(c)
 		}
 	}
+	c.hookRequestHeaders()
+	c.hookCookies()
+	c.hookRetryPolicy()
+	c.hookRefresher()
 	return c
 }
 
@@ -36,19 +96,114 @@ func Defalut() *Pan115Client {
 
 func (c *Pan115Client) SetHttpClient(httpClient *http.Client) *Pan115Client {
 	c.Client = resty.NewWithClient(httpClient)
+	c.customTransport = true
+	if c.userAgent != "" {
+		c.Client.SetHeader("User-Agent", c.userAgent)
+	}
+	c.hookRequestLogger()
+	c.hookTimeout()
+	c.hookRequestHeaders()
+	c.hookCookies()
+	c.hookRetryPolicy()
+	c.hookRefresher()
+	return c
+}
+
+// SetTransport sets the transport used by all requests, including OSS uploads.
+func (c *Pan115Client) SetTransport(transport http.RoundTripper) *Pan115Client {
+	c.Client.SetTransport(transport)
+	c.customTransport = true
 	return c
 }
 
+// SetUserAgent sets the User-Agent of every request, the download URLs are bound
+// to it unless DownloadWithUA is given another one.
 func (c *Pan115Client) SetUserAgent(userAgent string) *Pan115Client {
+	c.userAgent = userAgent
 	c.Client.SetHeader("User-Agent", userAgent)
 	return c
 }
 
+// UserAgent returns the User-Agent set by SetUserAgent.
+func (c *Pan115Client) UserAgent() string {
+	return c.userAgent
+}
+
+// SetCookies replaces the session cookies of the same names and domains, it
+// may be called while requests are in flight.
 func (c *Pan115Client) SetCookies(cs ...*http.Cookie) *Pan115Client {
-	c.Client.SetCookies(cs)
+	c.cookiesMu.Lock()
+	defer c.cookiesMu.Unlock()
+	cookies := replaceCookies(c.loadCookies(), cs)
+	c.cookies.Store(&cookies)
 	return c
 }
 
+// loadCookies returns the current session cookies, the slice must not be
+// modified.
+func (c *Pan115Client) loadCookies() []*http.Cookie {
+	if cookies := c.cookies.Load(); cookies != nil {
+		return *cookies
+	}
+	return nil
+}
+
+// hookCookies adds the hook sending the session cookies to the resty client
+// once. A retried request replaces the cookies of its previous attempt.
+func (c *Pan115Client) hookCookies() {
+	if c.cookiesHooked == c.Client {
+		return
+	}
+	c.cookiesHooked = c.Client
+	c.Client.OnBeforeRequest(func(_ *resty.Client, req *resty.Request) error {
+		cookies := c.loadCookies()
+		if len(cookies) == 0 {
+			return nil
+		}
+		names := make(map[string]bool, len(cookies))
+		for _, ck := range cookies {
+			names[ck.Name] = true
+		}
+		merged := make([]*http.Cookie, 0, len(req.Cookies)+len(cookies))
+		for _, ck := range req.Cookies {
+			if !names[ck.Name] {
+				merged = append(merged, ck)
+			}
+		}
+		req.Cookies = append(merged, cookies...)
+		return nil
+	})
+}
+
+// Cookies returns copies of the session cookies set by SetCookies,
+// ImportCredential and ImportCookies, which are sent with every request. Like
+// CookieHeader it reads a snapshot, so it may run concurrently with SetCookies.
+func (c *Pan115Client) Cookies() []*http.Cookie {
+	current := c.loadCookies()
+	cookies := make([]*http.Cookie, len(current))
+	for i, ck := range current {
+		copied := *ck
+		cookies[i] = &copied
+	}
+	return cookies
+}
+
+// CookieHeader returns the session cookies as the value of a Cookie header,
+// e.g. for an external player of GetPlayURL. A cookie set for several domains
+// appears once.
+func (c *Pan115Client) CookieHeader() string {
+	cookies := c.loadCookies()
+	seen := make(map[string]bool, len(cookies))
+	pairs := make([]string, 0, len(cookies))
+	for _, ck := range cookies {
+		if !seen[ck.Name] {
+			seen[ck.Name] = true
+			pairs = append(pairs, ck.Name+"="+ck.Value)
+		}
+	}
+	return strings.Join(pairs, "; ")
+}
+
 func (c *Pan115Client) SetDebug(d bool) *Pan115Client {
 	c.Client.SetDebug(d)
 	return c
@@ -61,14 +216,36 @@ func (c *Pan115Client) EnableTrace() *Pan115Client {
 
 func (c *Pan115Client) SetProxy(proxy string) *Pan115Client {
 	c.Client.SetProxy(proxy)
+	c.customTransport = true
 	return c
 }
 
+// SetProxyURL validates proxyURL and routes all requests through it, including
+// OSS uploads. Supported schemes are http, https, socks5 and socks5h.
+func (c *Pan115Client) SetProxyURL(proxyURL string) error {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return errors.Wrap(ErrWrongParams, err.Error())
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return errors.Wrapf(ErrWrongParams, "unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.Wrap(ErrWrongParams, "missing proxy host")
+	}
+	c.SetProxy(proxyURL)
+	return nil
+}
+
+// NewRequest returns a fresh request of the client, it is not stored in the
+// client so that concurrent calls never share one.
 func (c *Pan115Client) NewRequest() *resty.Request {
-	c.Request = c.Client.R()
-	return c.Request
+	return c.Client.R()
 }
 
+// Deprecated: use NewRequest, the returned request must not be shared.
 func (c *Pan115Client) GetRequest() *resty.Request {
 	if c.Request != nil {
 		return c.Request
@@ -31,4 +31,5 @@ const (
 	KB = 1 << (10 * (iota + 1))
 	MB
 	GB
+	TB
 )
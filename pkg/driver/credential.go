@@ -0,0 +1,84 @@
+package driver
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CredentialEnv is the environment variable read by FromEnv, its value is a
+// cookie string like "UID=...;CID=...;SEID=...;KID=...".
+const CredentialEnv = "PAN115_COOKIE"
+
+// CredentialSource is where LoadCredential reads the credential, see
+// FromCookieString, FromFile and FromEnv.
+type CredentialSource interface {
+	read() (string, error)
+}
+
+type cookieSource string
+
+func (s cookieSource) read() (string, error) {
+	return string(s), nil
+}
+
+type fileSource string
+
+func (s fileSource) read() (string, error) {
+	b, err := os.ReadFile(string(s))
+	if os.IsNotExist(err) {
+		return "", errors.Wrapf(ErrCredentialNotFound, "file %s", string(s))
+	}
+	return string(b), err
+}
+
+type envSource string
+
+func (s envSource) read() (string, error) {
+	return os.Getenv(string(s)), nil
+}
+
+// FromCookieString reads the credential from a cookie string.
+func FromCookieString(cookie string) CredentialSource {
+	return cookieSource(cookie)
+}
+
+// FromFile reads the credential from the file at path, which holds a cookie
+// string or a Credential in JSON.
+func FromFile(path string) CredentialSource {
+	return fileSource(path)
+}
+
+// FromEnv reads the credential from the environment variable CredentialEnv.
+func FromEnv() CredentialSource {
+	return envSource(CredentialEnv)
+}
+
+// LoadCredential reads the credential from src. ErrCredentialNotFound is
+// returned if src is empty or missing, and ErrBadCookie if it is malformed.
+func LoadCredential(src CredentialSource) (*Credential, error) {
+	s, err := src.read()
+	if err != nil {
+		return nil, err
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, ErrCredentialNotFound
+	}
+	cr := &Credential{}
+	if strings.HasPrefix(s, "{") {
+		if err := json.Unmarshal([]byte(s), cr); err != nil {
+			return nil, errors.Wrap(ErrBadCookie, err.Error())
+		}
+		if cr.CID == "" || cr.UID == "" || cr.SEID == "" {
+			return nil, errors.Wrap(ErrBadCookie, "bad cookie, miss UID, CID or SEID")
+		}
+		return cr, nil
+	}
+	if err := cr.FromCookie(s); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
@@ -1,19 +1,29 @@
 package driver
 
 import (
+	"context"
+	"path"
+	"strconv"
 	"strings"
 
 	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
 )
 
 // Mkdir make a new directory which name and parent directory id, return directory id
 func (c *Pan115Client) Mkdir(parentID string, name string) (string, error) {
+	return c.MkdirContext(context.Background(), parentID, name)
+}
+
+// MkdirContext is like Mkdir but with a context.
+func (c *Pan115Client) MkdirContext(ctx context.Context, parentID string, name string) (string, error) {
 	result := MkdirResp{}
 	form := map[string]string{
 		"pid":   parentID,
 		"cname": name,
 	}
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetFormData(form).
 		SetResult(&result).
 		ForceContentType("application/json;charset=UTF-8")
@@ -24,18 +34,110 @@ func (c *Pan115Client) Mkdir(parentID string, name string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	c.invalidateCache(parentID)
 	return string(result.CategoryID), nil
 }
 
+// MkdirAll creates the directory at path p along with any missing parents,
+// and returns its directory id. The id of an existing directory is returned
+// instead of ErrExist, so it is safe to call repeatedly or concurrently.
+func (c *Pan115Client) MkdirAll(p string) (string, error) {
+	return c.MkdirAllContext(context.Background(), p)
+}
+
+// MkdirAllContext is like MkdirAll but with a context.
+func (c *Pan115Client) MkdirAllContext(ctx context.Context, p string) (string, error) {
+	return c.mkdirAll(ctx, p)
+}
+
+func (c *Pan115Client) mkdirAll(ctx context.Context, p string) (string, error) {
+	p = cleanPath(p)
+	cid, err := c.dirCID(ctx, p)
+	if !errors.Is(err, ErrNotExist) {
+		return cid, err
+	}
+	parent, name := path.Split(p)
+	pid, err := c.mkdirAll(ctx, parent)
+	if err != nil {
+		return "", err
+	}
+	return c.mkdirOnce(ctx, pid, p, name)
+}
+
+// mkdirOnce creates the directory name under pid, whose path is p, unless it
+// has been created by others.
+func (c *Pan115Client) mkdirOnce(ctx context.Context, pid, p, name string) (string, error) {
+	c.mkdirMu.Lock()
+	defer c.mkdirMu.Unlock()
+	pc := c.getPathCache()
+	if pc != nil {
+		if e, ok := pc.get(p); ok {
+			return e.cid, nil
+		}
+	}
+	cid, err := c.MkdirContext(ctx, pid, name)
+	if errors.Is(err, ErrExist) {
+		var result *APIGetDirIDResp
+		if result, err = c.DirName2CIDContext(ctx, p); err != nil {
+			return "", err
+		}
+		cid = string(result.CategoryID)
+		if cid == "" || cid == "0" {
+			return "", errors.Wrapf(ErrExist, "%s exists and is not a directory", p)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	if pc != nil {
+		pc.put(p, cid, nil)
+	}
+	return cid, nil
+}
+
 // List list all files and directories
 func (c *Pan115Client) List(dirID string, opts ...ListOption) (*[]File, error) {
-	return c.ListWithLimit(dirID, FileListLimit, opts...)
+	return c.ListContext(context.Background(), dirID, opts...)
+}
+
+// ListContext is like List but with a context.
+func (c *Pan115Client) ListContext(ctx context.Context, dirID string, opts ...ListOption) (*[]File, error) {
+	return c.ListWithLimitContext(ctx, dirID, FileListLimit, opts...)
 }
 
 const MaxDirPageLimit = 1150
 
 // ListWithLimit list all files and directories with limit
 func (c *Pan115Client) ListWithLimit(dirID string, limit int64, opts ...ListOption) (*[]File, error) {
+	return c.ListWithLimitContext(context.Background(), dirID, limit, opts...)
+}
+
+// ListWithLimitContext is like ListWithLimit but with a context.
+func (c *Pan115Client) ListWithLimitContext(ctx context.Context, dirID string, limit int64, opts ...ListOption) (*[]File, error) {
+	files, err := c.listCached(ctx, dirID, limit, 0, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &files, nil
+}
+
+// ListAll lists the files and directories of dirID by pages of MaxDirPageLimit,
+// and stops after max of them, 0 means all. Nothing is cut off unless max is
+// reached.
+func (c *Pan115Client) ListAll(dirID string, max int, opts ...ListOption) ([]File, error) {
+	return c.ListAllContext(context.Background(), dirID, max, opts...)
+}
+
+// ListAllContext is like ListAll but with a context.
+func (c *Pan115Client) ListAllContext(ctx context.Context, dirID string, max int, opts ...ListOption) ([]File, error) {
+	if max < 0 {
+		return nil, errors.Wrap(ErrWrongParams, "negative max")
+	}
+	return c.listCached(ctx, dirID, MaxDirPageLimit, max, opts...)
+}
+
+// listUpTo lists dirID by pages of limit until max files are got, 0 means all.
+func (c *Pan115Client) listUpTo(ctx context.Context, dirID string, limit int64, max int, opts ...ListOption) ([]File, error) {
 	if isCalledByAlistV3() {
 		return nil, ErrorNotSupportAlist
 	}
@@ -49,18 +151,24 @@ func (c *Pan115Client) ListWithLimit(dirID string, limit int64, opts ...ListOpti
 			opt(o)
 		}
 	}
+	if err := c.prepareList(ctx, o); err != nil {
+		return nil, err
+	}
 
 	apiURLs := o.ApiURLs
 	var files []File
 	offset := int64(0)
 	for i := 0; ; i++ {
 		apiURL := apiURLs[i%len(apiURLs)]
-		req := c.NewRequest().ForceContentType("application/json;charset=UTF-8")
-		getFilesOpts := []GetFileOptions{
-			WithApiURL(apiURL),
-			WithLimit(limit),
-			WithOffset(offset),
+		pageLimit := limit
+		if max > 0 && int64(max-len(files)) < pageLimit {
+			pageLimit = int64(max - len(files))
 		}
+		req := c.NewRequest().SetContext(ctx).ForceContentType("application/json;charset=UTF-8")
+		getFilesOpts := append(o.getFileOptions(apiURL),
+			WithLimit(pageLimit),
+			WithOffset(offset),
+		)
 		result, err := GetFiles(req, dirID, getFilesOpts...)
 		if err != nil {
 			return nil, err
@@ -68,31 +176,41 @@ func (c *Pan115Client) ListWithLimit(dirID string, limit int64, opts ...ListOpti
 		for _, fileInfo := range result.Files {
 			files = append(files, *(&File{}).from(&fileInfo))
 		}
-		offset = int64(result.Offset) + limit
-		if offset >= int64(result.Count) {
+		offset = int64(result.Offset) + pageLimit
+		if offset >= int64(result.Count) || (max > 0 && len(files) >= max) {
 			break
 		}
 	}
-	return &files, nil
+	if max > 0 && len(files) > max {
+		files = files[:max]
+	}
+	return files, nil
 }
 
 // ListPage list files and directories with page
 func (c *Pan115Client) ListPage(dirID string, offset, limit int64, opts ...ListOption) (*[]File, error) {
+	return c.ListPageContext(context.Background(), dirID, offset, limit, opts...)
+}
+
+// ListPageContext is like ListPage but with a context.
+func (c *Pan115Client) ListPageContext(ctx context.Context, dirID string, offset, limit int64, opts ...ListOption) (*[]File, error) {
 	o := DefaultListOptions()
 	if len(opts) > 0 {
 		for _, opt := range opts {
 			opt(o)
 		}
 	}
+	if err := c.prepareList(ctx, o); err != nil {
+		return nil, err
+	}
 
 	apiURLs := o.ApiURLs
 	var files []File
-	req := c.NewRequest().ForceContentType("application/json;charset=UTF-8")
-	getFilesOpts := []GetFileOptions{
-		WithApiURL(apiURLs[0]),
+	req := c.NewRequest().SetContext(ctx).ForceContentType("application/json;charset=UTF-8")
+	getFilesOpts := append(o.getFileOptions(apiURLs[0]),
 		WithLimit(limit),
 		WithOffset(offset),
-	}
+	)
 	result, err := GetFiles(req, dirID, getFilesOpts...)
 	if err != nil {
 		return nil, err
@@ -106,6 +224,84 @@ func (c *Pan115Client) ListPage(dirID string, offset, limit int64, opts ...ListO
 	return &files, nil
 }
 
+// ListWithOptions lists a page of files and directories, the ordering and the
+// page are set by ListOption, like ListWithOrder and ListWithPage.
+func (c *Pan115Client) ListWithOptions(dirID string, opts ...ListOption) (*[]File, error) {
+	return c.ListWithOptionsContext(context.Background(), dirID, opts...)
+}
+
+// ListWithOptionsContext is like ListWithOptions but with a context.
+func (c *Pan115Client) ListWithOptionsContext(ctx context.Context, dirID string, opts ...ListOption) (*[]File, error) {
+	page, err := c.ListFilePageContext(ctx, dirID, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &page.Files, nil
+}
+
+// FilePage is a page of a directory listing.
+type FilePage struct {
+	Files []File
+	// Count is the total number of items in the directory.
+	Count    int
+	Offset   int64
+	PageSize int64
+}
+
+// Pages returns the number of pages of the directory.
+func (p *FilePage) Pages() int {
+	if p.PageSize <= 0 {
+		return 0
+	}
+	return int((int64(p.Count) + p.PageSize - 1) / p.PageSize)
+}
+
+// HasNextPage reports whether there are items after this page.
+func (p *FilePage) HasNextPage() bool {
+	return p.Offset+int64(len(p.Files)) < int64(p.Count)
+}
+
+// ListFilePage lists a page like ListWithOptions, along with the total count of
+// the directory for pagination.
+func (c *Pan115Client) ListFilePage(dirID string, opts ...ListOption) (*FilePage, error) {
+	return c.ListFilePageContext(context.Background(), dirID, opts...)
+}
+
+// ListFilePageContext is like ListFilePage but with a context.
+func (c *Pan115Client) ListFilePageContext(ctx context.Context, dirID string, opts ...ListOption) (*FilePage, error) {
+	o := DefaultListOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	limit := o.Limit
+	if limit <= 0 {
+		limit = FileListLimit
+	}
+	if limit > MaxDirPageLimit {
+		limit = MaxDirPageLimit
+	}
+	if err := c.prepareList(ctx, o); err != nil {
+		return nil, err
+	}
+	req := c.NewRequest().SetContext(ctx).ForceContentType("application/json;charset=UTF-8")
+	getFilesOpts := append(o.getFileOptions(o.ApiURLs[0]),
+		WithLimit(limit),
+		WithOffset(o.Offset),
+	)
+	result, err := GetFiles(req, dirID, getFilesOpts...)
+	if err != nil {
+		return nil, err
+	}
+	page := &FilePage{Count: result.Count, Offset: o.Offset, PageSize: limit}
+	if int64(result.Count) <= o.Offset {
+		return page, nil
+	}
+	for _, fileInfo := range result.Files {
+		page.Files = append(page.Files, *(&File{}).from(&fileInfo))
+	}
+	return page, nil
+}
+
 func GetFiles(req *resty.Request, dirID string, opts ...GetFileOptions) (*FileListResp, error) {
 	if dirID == "" {
 		dirID = "0"
@@ -126,11 +322,21 @@ func GetFiles(req *resty.Request, dirID string, opts ...GetFileOptions) (*FileLi
 		"show_dir":         o.GetshowDir(),
 		"limit":            o.GetPageSize(),
 		"snap":             "0",
-		"natsort":          "0",
+		"natsort":          o.GetNatsort(),
 		"record_open_time": "1",
 		"format":           "json",
 		"fc_mix":           "0",
 	}
+	if o.GetOrder() == FileOrderByNone {
+		delete(params, "o")
+		delete(params, "asc")
+	}
+	if o.GetStar() != "" {
+		params["star"] = o.GetStar()
+	}
+	if o.GetFileType() != FileTypeAll {
+		params["type"] = strconv.Itoa(int(o.GetFileType()))
+	}
 	req = req.SetQueryParams(params).
 		SetResult(&result)
 	resp, err := req.Get(o.GetApiURL())
@@ -143,10 +349,16 @@ func GetFiles(req *resty.Request, dirID string, opts ...GetFileOptions) (*FileLi
 	return &result, err
 }
 
+// DirName2CID get directory id by directory path
 func (c *Pan115Client) DirName2CID(dir string) (*APIGetDirIDResp, error) {
+	return c.DirName2CIDContext(context.Background(), dir)
+}
+
+// DirName2CIDContext is like DirName2CID but with a context.
+func (c *Pan115Client) DirName2CIDContext(ctx context.Context, dir string) (*APIGetDirIDResp, error) {
 	result := APIGetDirIDResp{}
 	dir = strings.TrimPrefix(dir, "/")
-	req := c.NewRequest().ForceContentType("application/json;charset=UTF-8")
+	req := c.NewRequest().SetContext(ctx).ForceContentType("application/json;charset=UTF-8")
 	req.SetQueryParam("path", dir).SetResult(&result)
 	resp, err := req.Get(ApiDirName2CID)
 	if err = CheckErr(err, &result, resp); err != nil {
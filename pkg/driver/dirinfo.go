@@ -0,0 +1,96 @@
+package driver
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DirInfoCacheTTL is how long GetDirInfo caches the detail of a directory.
+const DirInfoCacheTTL = 30 * time.Second
+
+// DirDetail is the summary of a directory, counted recursively.
+type DirDetail struct {
+	Name string
+	// Size is the total size in bytes, 115 reports it rounded like "1.5GB"
+	// so it is approximate for large directories.
+	Size      int64
+	FileCount int
+	DirCount  int
+}
+
+type dirInfoEntry struct {
+	detail  *DirDetail
+	expires time.Time
+}
+
+// GetDirInfo gets the total size, file count and directory count of a directory,
+// the result is cached for DirInfoCacheTTL.
+func (c *Pan115Client) GetDirInfo(dirID string) (*DirDetail, error) {
+	return c.GetDirInfoContext(context.Background(), dirID)
+}
+
+// GetDirInfoContext is like GetDirInfo but with a context.
+func (c *Pan115Client) GetDirInfoContext(ctx context.Context, dirID string) (*DirDetail, error) {
+	if v, ok := c.dirInfos.Load(dirID); ok {
+		e := v.(*dirInfoEntry)
+		if time.Now().Before(e.expires) {
+			detail := *e.detail
+			return &detail, nil
+		}
+		c.dirInfos.Delete(dirID)
+	}
+	result := FileStatResponse{}
+	req := c.NewRequest().
+		SetContext(ctx).
+		SetQueryParam("cid", dirID).
+		ForceContentType("application/json;charset=UTF-8").
+		SetResult(&result)
+	resp, err := req.Get(ApiFileStat)
+	if err := CheckErr(err, &result, resp); err != nil {
+		return nil, err
+	}
+	if result.IsFile != 0 {
+		return nil, errors.Wrapf(ErrWrongParams, "%s is not a directory", dirID)
+	}
+	size, err := parseSize(result.Size)
+	if err != nil {
+		return nil, err
+	}
+	detail := &DirDetail{
+		Name:      result.FileName,
+		Size:      size,
+		FileCount: int(result.FileCount),
+		DirCount:  int(result.FolderCount),
+	}
+	c.dirInfos.Store(dirID, &dirInfoEntry{detail: detail, expires: time.Now().Add(DirInfoCacheTTL)})
+	copied := *detail
+	return &copied, nil
+}
+
+// parseSize parses a size like "1024", "1.5GB" or "10 KB" into bytes.
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" {
+		return 0, nil
+	}
+	units := []struct {
+		suffix string
+		scale  float64
+	}{{"TB", TB}, {"GB", GB}, {"MB", MB}, {"KB", KB}, {"B", 1}}
+	scale := float64(1)
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			s, scale = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.scale
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, errors.Wrapf(ErrUnexpected, "invalid size %q", s)
+	}
+	return int64(n * scale), nil
+}
@@ -2,12 +2,21 @@ package driver
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	crypto "github.com/SheltonZhu/115driver/pkg/crypto/m115"
 	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
 )
 
 type FileDownloadUrl struct {
@@ -22,11 +31,18 @@ type DownloadInfo struct {
 	PickCode string          `json:"pick_code"`
 	Url      FileDownloadUrl `json:"url"`
 	Header   http.Header
+	// FileID is filled by DownloadWithUA.
+	FileID string `json:"-"`
 }
 
 // Get Download file from download info url
 func (info *DownloadInfo) Get() (io.ReadSeeker, error) {
-	req := resty.New().R().SetHeaderMultiValues(info.Header)
+	return info.GetContext(context.Background())
+}
+
+// GetContext is like Get but with a context.
+func (info *DownloadInfo) GetContext(ctx context.Context) (io.ReadSeeker, error) {
+	req := resty.New().R().SetContext(ctx).SetHeaderMultiValues(info.Header)
 	resp, err := req.Get(info.Url.Url)
 	if err != nil {
 		return nil, err
@@ -37,8 +53,302 @@ func (info *DownloadInfo) Get() (io.ReadSeeker, error) {
 
 type DownloadData map[string]*DownloadInfo
 
-// DownloadWithUA get download info with pickcode and user agent
+// DownloadStream downloads file with pickcode, returns the body and its length.
+// The caller should close the body.
+func (c *Pan115Client) DownloadStream(pickCode string, opts ...DownloadOption) (io.ReadCloser, int64, error) {
+	return c.DownloadStreamContext(context.Background(), pickCode, opts...)
+}
+
+// DownloadStreamContext is like DownloadStream but with a context.
+func (c *Pan115Client) DownloadStreamContext(ctx context.Context, pickCode string, opts ...DownloadOption) (io.ReadCloser, int64, error) {
+	o := DefaultDownloadOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	info, err := c.DownloadWithUAContext(ctx, pickCode, o.UserAgent)
+	if err != nil {
+		return nil, 0, err
+	}
+	return c.downloadStream(ctx, info, o)
+}
+
+// DownloadTo downloads the file of pickCode into w, and returns the number of
+// bytes written.
+func (c *Pan115Client) DownloadTo(w io.Writer, pickCode string, opts ...DownloadOption) (int64, error) {
+	return c.DownloadToContext(context.Background(), w, pickCode, opts...)
+}
+
+// DownloadToContext is like DownloadTo but with a context.
+func (c *Pan115Client) DownloadToContext(ctx context.Context, w io.Writer, pickCode string, opts ...DownloadOption) (int64, error) {
+	o := DefaultDownloadOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	info, err := c.DownloadWithUAContext(ctx, pickCode, o.UserAgent)
+	if err != nil {
+		return 0, err
+	}
+	return c.downloadTo(ctx, w, info, o)
+}
+
+func (c *Pan115Client) downloadTo(ctx context.Context, w io.Writer, info *DownloadInfo, o *DownloadOptions) (int64, error) {
+	body, size, err := c.downloadStream(ctx, info, o)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	if prog := newProgress(o.OnProgress, size, o.ProgressEvery); prog != nil {
+		w = io.MultiWriter(w, prog)
+	}
+	return io.Copy(w, body)
+}
+
+// DownloadToFile downloads the file of pickCode to destPath. The content is
+// written to destPath+".part" first, which is resumed if it exists, and renamed
+// to destPath after the SHA1 is verified. ErrChecksumMismatch is returned and
+// the part file is removed if the SHA1 mismatches.
+func (c *Pan115Client) DownloadToFile(pickCode, destPath string, opts ...DownloadOption) error {
+	return c.DownloadToFileContext(context.Background(), pickCode, destPath, opts...)
+}
+
+// DownloadToFileContext is like DownloadToFile but with a context.
+func (c *Pan115Client) DownloadToFileContext(ctx context.Context, pickCode, destPath string, opts ...DownloadOption) error {
+	o := DefaultDownloadOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	info, err := c.DownloadWithUAContext(ctx, pickCode, o.UserAgent)
+	if err != nil {
+		return err
+	}
+	var sha1 string
+	if info.FileID != "" {
+		file, err := c.GetFileContext(ctx, info.FileID)
+		if err != nil {
+			return err
+		}
+		sha1 = file.Sha1
+	}
+	return c.downloadToFile(ctx, info, sha1, destPath, o)
+}
+
+// downloadToFile downloads info to destPath, the SHA1 is not verified if sha1 is empty.
+func (c *Pan115Client) downloadToFile(ctx context.Context, info *DownloadInfo, sha1, destPath string, o *DownloadOptions) error {
+	partPath := destPath + ".part"
+	f, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	size := int64(info.FileSize)
+	offset := fi.Size()
+	if offset > size {
+		offset = 0
+	}
+	if offset < size {
+		ro := *o
+		ro.RangeStart, ro.RangeEnd = offset, -1
+		body, _, err := c.downloadStream(ctx, info, &ro)
+		if errors.Is(err, ErrRangeNotSupported) {
+			offset, ro.RangeStart = 0, 0
+			body, _, err = c.downloadStream(ctx, info, &ro)
+		}
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		if err = f.Truncate(offset); err != nil {
+			return err
+		}
+		if _, err = f.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		var w io.Writer = f
+		if prog := newProgress(o.OnProgress, size, o.ProgressEvery); prog != nil {
+			prog.skip(offset)
+			w = io.MultiWriter(f, prog)
+		}
+		if _, err = io.Copy(w, body); err != nil {
+			return err
+		}
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	if sha1 != "" {
+		sum, err := fileSHA1(partPath)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(sum, sha1) {
+			_ = os.Remove(partPath)
+			return errors.Wrapf(ErrChecksumMismatch, "expected %s, got %s", sha1, sum)
+		}
+	}
+	return os.Rename(partPath, destPath)
+}
+
+func fileSHA1(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha1.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// downloadStream opens info, the URL of info.PickCode is fetched again if it
+// expires and the body resumes by range when o.AutoRefreshURL is set.
+func (c *Pan115Client) downloadStream(ctx context.Context, info *DownloadInfo, o *DownloadOptions) (io.ReadCloser, int64, error) {
+	if !o.AutoRefreshURL || info.PickCode == "" {
+		return c.openStream(ctx, info, o)
+	}
+	pickCode, ua := info.PickCode, o.UserAgent
+	return c.newRefreshingBody(ctx, info, o, func(ctx context.Context) (*DownloadInfo, error) {
+		return c.DownloadWithUAContext(ctx, pickCode, ua)
+	})
+}
+
+func (c *Pan115Client) openStream(ctx context.Context, info *DownloadInfo, o *DownloadOptions) (io.ReadCloser, int64, error) {
+	header := info.Header.Clone()
+	// cookies will be added by client
+	header.Del("Cookie")
+	req := c.NewRequest().
+		SetContext(withTransfer(ctx)).
+		SetHeaderMultiValues(header).
+		SetDoNotParseResponse(true)
+	if o.HasRange() {
+		req = req.SetHeader("Range", o.GetRange())
+	}
+	resp, err := req.Get(info.Url.Url)
+	if err != nil {
+		return nil, 0, err
+	}
+	body := resp.RawBody()
+	if resp.StatusCode() == http.StatusForbidden {
+		body.Close()
+		return nil, 0, errors.Wrap(ErrDownloadURLExpired, resp.Status())
+	}
+	if code := resp.StatusCode(); code != http.StatusOK && code != http.StatusPartialContent {
+		body.Close()
+		return nil, 0, errors.Wrap(ErrUnexpected, resp.Status())
+	}
+	if o.HasRange() && resp.StatusCode() == http.StatusOK {
+		body.Close()
+		return nil, 0, ErrRangeNotSupported
+	}
+	size := resp.RawResponse.ContentLength
+	if size < 0 && !o.HasRange() {
+		size = int64(info.FileSize)
+	}
+	return c.limitReadCloser(ctx, body), size, nil
+}
+
+// maxDownloadRefresh is how many times in a row a broken body is reopened.
+const maxDownloadRefresh = 3
+
+// refreshingBody reopens the download by range from where it was broken, the
+// URL is fetched again by refresh if the CDN answers 403.
+type refreshingBody struct {
+	ctx     context.Context
+	c       *Pan115Client
+	info    *DownloadInfo
+	o       DownloadOptions
+	refresh func(ctx context.Context) (*DownloadInfo, error)
+
+	body    io.ReadCloser
+	read    int64
+	retries int
+}
+
+func (c *Pan115Client) newRefreshingBody(ctx context.Context, info *DownloadInfo, o *DownloadOptions, refresh func(ctx context.Context) (*DownloadInfo, error)) (io.ReadCloser, int64, error) {
+	b := &refreshingBody{ctx: ctx, c: c, info: info, o: *o, refresh: refresh}
+	size, err := b.open(o)
+	if err != nil {
+		return nil, 0, err
+	}
+	return b, size, nil
+}
+
+func (b *refreshingBody) open(o *DownloadOptions) (int64, error) {
+	body, size, err := b.c.openStream(b.ctx, b.info, o)
+	if errors.Is(err, ErrDownloadURLExpired) {
+		info, rerr := b.refresh(b.ctx)
+		if rerr != nil {
+			return 0, rerr
+		}
+		b.info = info
+		body, size, err = b.c.openStream(b.ctx, info, o)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if b.body != nil {
+		_ = b.body.Close()
+	}
+	b.body = body
+	return size, nil
+}
+
+func (b *refreshingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	b.read += int64(n)
+	if n > 0 {
+		b.retries = 0
+	}
+	if err == nil || err == io.EOF || b.ctx.Err() != nil || b.retries >= maxDownloadRefresh {
+		return n, err
+	}
+	b.retries++
+	o := b.o
+	o.RangeStart += b.read
+	if _, rerr := b.open(&o); rerr != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+func (b *refreshingBody) Close() error {
+	return b.body.Close()
+}
+
+// DownloadWithUA get download info with pickcode and user agent, an empty ua
+// means the one of SetUserAgent.
 func (c *Pan115Client) DownloadWithUA(pickCode, ua string) (*DownloadInfo, error) {
+	return c.DownloadWithUAContext(context.Background(), pickCode, ua)
+}
+
+// DownloadWithUAContext is like DownloadWithUA but with a context.
+func (c *Pan115Client) DownloadWithUAContext(ctx context.Context, pickCode, ua string) (*DownloadInfo, error) {
+	downloadInfo, err := c.downloadData(ctx, pickCode, ua)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range downloadInfo {
+		if info.FileSize < 0 {
+			return nil, ErrDownloadEmpty
+		}
+		if info.PickCode == "" {
+			info.PickCode = pickCode
+		}
+		return info, nil
+	}
+	return nil, ErrUnexpected
+}
+
+// downloadData gets the download info of pickCodes joined by comma, keyed by
+// the file ids.
+func (c *Pan115Client) downloadData(ctx context.Context, pickCode, ua string) (DownloadData, error) {
 	key := crypto.GenerateKey()
 
 	result := DownloadResp{}
@@ -49,6 +359,7 @@ func (c *Pan115Client) DownloadWithUA(pickCode, ua string) (*DownloadInfo, error
 
 	data := crypto.Encode(params, key)
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetQueryParam("t", Now().String()).
 		SetFormData(map[string]string{"data": data}).
 		ForceContentType("application/json").
@@ -71,18 +382,20 @@ func (c *Pan115Client) DownloadWithUA(pickCode, ua string) (*DownloadInfo, error
 		return nil, err
 	}
 
-	for _, info := range downloadInfo {
-		if info.FileSize < 0 {
-			return nil, ErrDownloadEmpty
-		}
+	for fileID, info := range downloadInfo {
 		info.Header = resp.Request.Header
-		return info, nil
+		info.FileID = fileID
 	}
-	return nil, ErrUnexpected
+	return downloadInfo, nil
 }
 
 // DownloadWithUAByAndroidAPI get download info with pickcode and user agent
 func (c *Pan115Client) DownloadWithUAByAndroidAPI(pickCode string, ua string) (*DownloadInfo, error) {
+	return c.DownloadWithUAByAndroidAPIContext(context.Background(), pickCode, ua)
+}
+
+// DownloadWithUAByAndroidAPIContext is like DownloadWithUAByAndroidAPI but with a context.
+func (c *Pan115Client) DownloadWithUAByAndroidAPIContext(ctx context.Context, pickCode string, ua string) (*DownloadInfo, error) {
 	key := crypto.GenerateKey()
 
 	result := DownloadResp{}
@@ -93,6 +406,7 @@ func (c *Pan115Client) DownloadWithUAByAndroidAPI(pickCode string, ua string) (*
 
 	data := crypto.Encode(params, key)
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetQueryParam("t", Now().String()).
 		SetFormData(map[string]string{"data": data}).
 		ForceContentType("application/json").
@@ -128,11 +442,80 @@ func (c *Pan115Client) DownloadWithUAByAndroidAPI(pickCode string, ua string) (*
 	return &info, nil
 }
 
+// PlayURL is a download URL together with the headers which fetching it needs.
+type PlayURL struct {
+	URL string
+	// Headers has the User-Agent the URL is bound to and the cookies.
+	Headers map[string]string
+	// ExpiresAt is when the URL expires, zero if unknown.
+	ExpiresAt time.Time
+}
+
+// GetPlayURL gets the download URL of pickCode bound to ua for players, an empty
+// ua means the one of SetUserAgent. A player must send all of the headers,
+// otherwise 115 answers 403.
+func (c *Pan115Client) GetPlayURL(pickCode, ua string) (*PlayURL, error) {
+	return c.GetPlayURLContext(context.Background(), pickCode, ua)
+}
+
+// GetPlayURLContext is like GetPlayURL but with a context.
+func (c *Pan115Client) GetPlayURLContext(ctx context.Context, pickCode, ua string) (*PlayURL, error) {
+	if pickCode == "" {
+		return nil, ErrPickCodeIsEmpty
+	}
+	info, err := c.DownloadWithUAContext(ctx, pickCode, ua)
+	if err != nil {
+		return nil, err
+	}
+	return c.playURL(info), nil
+}
+
+func (c *Pan115Client) playURL(info *DownloadInfo) *PlayURL {
+	p := &PlayURL{URL: info.Url.Url, Headers: map[string]string{}}
+	ua := info.Header.Get("User-Agent")
+	if ua == "" {
+		ua = c.Client.Header.Get("User-Agent")
+	}
+	if ua != "" {
+		p.Headers["User-Agent"] = ua
+	}
+	if cookie := c.CookieHeader(); cookie != "" {
+		p.Headers["Cookie"] = cookie
+	}
+	// the t parameter of a download URL is its expiration in unix seconds
+	if u, err := url.Parse(p.URL); err == nil {
+		if t, err := strconv.ParseInt(u.Query().Get("t"), 10, 64); err == nil && t > 0 {
+			p.ExpiresAt = time.Unix(t, 0)
+		}
+	}
+	return p
+}
+
+// DownloadApp gets download info with pickcode by the Android API, which is
+// throttled less than the web API of Download, so it suits bulk downloads. The
+// info has no file name and size.
+func (c *Pan115Client) DownloadApp(pickCode string) (*DownloadInfo, error) {
+	return c.DownloadAppContext(context.Background(), pickCode)
+}
+
+// DownloadAppContext is like DownloadApp but with a context.
+func (c *Pan115Client) DownloadAppContext(ctx context.Context, pickCode string) (*DownloadInfo, error) {
+	if pickCode == "" {
+		return nil, ErrPickCodeIsEmpty
+	}
+	return c.DownloadWithUAByAndroidAPIContext(ctx, pickCode, "")
+}
+
 // Download get download info with pickcode
 func (c *Pan115Client) Download(pickCode string) (*DownloadInfo, error) {
 	return c.DownloadWithUA(pickCode, "")
 }
 
+// DownloadContext is like Download but with a context.
+func (c *Pan115Client) DownloadContext(ctx context.Context, pickCode string) (*DownloadInfo, error) {
+	return c.DownloadWithUAContext(ctx, pickCode, "")
+}
+
 type SharedDownloadInfo struct {
 	FileID   string      `json:"fid"`
 	FileName string      `json:"fn"`
@@ -147,6 +530,11 @@ type SharedDownloadInfo struct {
 
 // DownloadByShareCode get download info with share code
 func (c *Pan115Client) DownloadByShareCode(shareCode, receiveCode, fileID string) (*SharedDownloadInfo, error) {
+	return c.DownloadByShareCodeContext(context.Background(), shareCode, receiveCode, fileID)
+}
+
+// DownloadByShareCodeContext is like DownloadByShareCode but with a context.
+func (c *Pan115Client) DownloadByShareCodeContext(ctx context.Context, shareCode, receiveCode, fileID string) (*SharedDownloadInfo, error) {
 	if isCalledByAlistV3() {
 		return nil, ErrorNotSupportAlist
 	}
@@ -164,6 +552,7 @@ func (c *Pan115Client) DownloadByShareCode(shareCode, receiveCode, fileID string
 
 	data := crypto.Encode(params, key)
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetQueryParam("t", Now().String()).
 		SetFormData(map[string]string{"data": data}).
 		ForceContentType("application/json").
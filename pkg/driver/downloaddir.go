@@ -0,0 +1,109 @@
+package driver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// DownloadDir downloads the directory tree of dirID into destDir concurrently,
+// mirroring its structure. Files already in destDir with the same SHA1 are
+// skipped. A failed file does not stop the others, a *BatchError listing the
+// failed file ids is returned.
+func (c *Pan115Client) DownloadDir(dirID, destDir string, opts ...BatchDownloadOption) error {
+	return c.DownloadDirContext(context.Background(), dirID, destDir, opts...)
+}
+
+// DownloadDirContext is like DownloadDir but with a context.
+func (c *Pan115Client) DownloadDirContext(ctx context.Context, dirID, destDir string, opts ...BatchDownloadOption) error {
+	o := DefaultBatchDownloadOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.Concurrency < 1 {
+		o.Concurrency = 1
+	}
+	root, err := filepath.Abs(destDir)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(root, 0o755); err != nil {
+		return err
+	}
+
+	type job struct {
+		file      *File
+		localPath string
+	}
+	jobs := make(chan job)
+	batchErr := &BatchError{}
+	var mu sync.Mutex
+	fail := func(err error, fileID string) {
+		mu.Lock()
+		defer mu.Unlock()
+		batchErr.add(err, fileID)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < o.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if err := c.downloadDirFile(ctx, j.file, j.localPath, &o.DownloadOptions); err != nil {
+					fail(err, j.file.FileID)
+				}
+			}
+		}()
+	}
+
+	walkErr := c.WalkContext(ctx, dirID, func(p string, f *File) error {
+		localPath := filepath.Join(root, filepath.FromSlash(p))
+		if !strings.HasPrefix(localPath, root+string(filepath.Separator)) {
+			fail(errors.Wrapf(ErrInvalidFileName, "%s escapes %s", p, destDir), f.FileID)
+			return nil
+		}
+		if f.IsDirectory {
+			if err := os.MkdirAll(localPath, 0o755); err != nil {
+				fail(err, f.FileID)
+			}
+			return nil
+		}
+		mu.Lock()
+		batchErr.Total++
+		mu.Unlock()
+		select {
+		case jobs <- job{file: f, localPath: localPath}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(jobs)
+	wg.Wait()
+	if walkErr != nil {
+		return walkErr
+	}
+	return batchErr.orNil()
+}
+
+// downloadDirFile downloads f to localPath unless it exists with the same SHA1.
+func (c *Pan115Client) downloadDirFile(ctx context.Context, f *File, localPath string, o *DownloadOptions) error {
+	if fi, err := os.Stat(localPath); err == nil && fi.Size() == f.Size && f.Sha1 != "" {
+		if sum, err := fileSHA1(localPath); err == nil && strings.EqualFold(sum, f.Sha1) {
+			return nil
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(localPath), 0o755); err != nil {
+		return err
+	}
+	info, err := c.DownloadWithUAContext(ctx, f.PickCode, o.UserAgent)
+	if err != nil {
+		return err
+	}
+	return c.downloadToFile(ctx, info, f.Sha1, localPath, o)
+}
@@ -1,13 +1,31 @@
 package driver
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+	"golang.org/x/time/rate"
 )
 
 var (
@@ -29,6 +47,75 @@ func TestImportFromCookie(t *testing.T) {
 	assert.Error(t, ErrBadCookie, cr.FromCookie("1=2;2=3;3=4"))
 }
 
+func TestCredentialRoundTrip(t *testing.T) {
+	cr := &Credential{UID: "1_A1_2", CID: "cid", SEID: "se=id", KID: "kid"}
+	b, err := json.Marshal(cr)
+	assert.NoError(t, err)
+	cr1 := &Credential{}
+	assert.NoError(t, json.Unmarshal(b, cr1))
+	assert.Equal(t, cr, cr1)
+
+	cr2 := &Credential{}
+	assert.NoError(t, cr2.FromCookie(cr.Cookie()))
+	assert.Equal(t, cr, cr2)
+}
+
+func TestCredentialValidate(t *testing.T) {
+	cr := &Credential{UID: "100_A1_1700000000", CID: "abc123", SEID: "Def456", KID: "k1"}
+	assert.NoError(t, cr.Validate())
+	cr.KID = ""
+	assert.NoError(t, cr.Validate())
+
+	for _, bad := range []Credential{
+		{CID: "a", SEID: "b"},
+		{UID: "100_A1_1", SEID: "b"},
+		{UID: "100_A1_1", CID: "a"},
+		{UID: "100", CID: "a", SEID: "b"},
+		{UID: "x_A1_1", CID: "a", SEID: "b"},
+		{UID: "100_A1_", CID: "a", SEID: "b"},
+		{UID: "100_A1_1", CID: "a b", SEID: "b"},
+		{UID: "100_A1_1", CID: "a", SEID: "b;"},
+		{UID: "100_A1_1", CID: "a", SEID: "b", KID: "k="},
+	} {
+		assert.ErrorIs(t, bad.Validate(), ErrBadCookie, bad)
+	}
+	err := (&Credential{UID: "100_A1_1", CID: "a"}).Validate()
+	assert.Contains(t, err.Error(), "SEID")
+}
+
+func TestLoadCredential(t *testing.T) {
+	want := &Credential{UID: "1", CID: "2", SEID: "3", KID: "4"}
+	cr, err := LoadCredential(FromCookieString(want.Cookie()))
+	assert.NoError(t, err)
+	assert.Equal(t, want, cr)
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(dir+"/cookie", []byte(want.Cookie()+"\n"), 0o600))
+	cr, err = LoadCredential(FromFile(dir + "/cookie"))
+	assert.NoError(t, err)
+	assert.Equal(t, want, cr)
+	b, _ := json.Marshal(want)
+	assert.NoError(t, os.WriteFile(dir+"/cookie.json", b, 0o600))
+	cr, err = LoadCredential(FromFile(dir + "/cookie.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, want, cr)
+
+	t.Setenv(CredentialEnv, want.Cookie())
+	cr, err = LoadCredential(FromEnv())
+	assert.NoError(t, err)
+	assert.Equal(t, want, cr)
+
+	_, err = LoadCredential(FromFile(dir + "/missing"))
+	assert.ErrorIs(t, err, ErrCredentialNotFound)
+	t.Setenv(CredentialEnv, "")
+	_, err = LoadCredential(FromEnv())
+	assert.ErrorIs(t, err, ErrCredentialNotFound)
+	_, err = LoadCredential(FromCookieString("UID=1;CID=2"))
+	assert.ErrorIs(t, err, ErrBadCookie)
+	_, err = LoadCredential(FromCookieString(`{"UID":"1"}`))
+	assert.ErrorIs(t, err, ErrBadCookie)
+}
+
 func TestLoginErr(t *testing.T) {
 	assert.Error(t, New().ImportCredential(&Credential{}).LoginCheck())
 }
@@ -37,6 +124,3070 @@ func TestBadCookie(t *testing.T) {
 	assert.Error(t, New().ImportCredential(&Credential{}).CookieCheck())
 }
 
+func TestListContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := New().ListContext(ctx, "0")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// newFileListServer serves a fake directory "1" with total files.
+func newFileListServer(total int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		data := []map[string]any{}
+		for i := offset; i < offset+limit && i < total; i++ {
+			data = append(data, map[string]any{
+				"fid": strconv.Itoa(i),
+				"cid": "1",
+				"n":   fmt.Sprintf("file%d", i),
+			})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"state":  true,
+			"cid":    "1",
+			"count":  total,
+			"offset": offset,
+			"data":   data,
+		})
+	}))
+}
+
+func TestListIterator(t *testing.T) {
+	s := newFileListServer(25)
+	defer s.Close()
+
+	it := New().ListIterator("1", WithApiURL(s.URL), WithLimit(10))
+	var names []string
+	var kept []*File
+	for f, ok := it.Next(); ok; f, ok = it.Next() {
+		names = append(names, f.Name)
+		kept = append(kept, f)
+	}
+	assert.NoError(t, it.Err())
+	assert.Len(t, names, 25)
+	assert.Equal(t, "file24", names[24])
+	// the files of the first page are not overwritten by the later pages
+	for i, f := range kept {
+		assert.Equal(t, names[i], f.Name)
+	}
+}
+
+// newTreeServer serves a fake directory tree, tree maps cid to its children.
+func newTreeServer(tree map[string][]map[string]any) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cid := r.URL.Query().Get("cid")
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		children := tree[cid]
+		data := []map[string]any{}
+		for i := offset; i < offset+limit && i < len(children); i++ {
+			data = append(data, children[i])
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"state":  true,
+			"cid":    cid,
+			"count":  len(children),
+			"offset": offset,
+			"data":   data,
+		})
+	}))
+}
+
+func TestWalk(t *testing.T) {
+	s := newTreeServer(map[string][]map[string]any{
+		"0": {
+			{"cid": "1", "pid": "0", "n": "a"},
+			{"cid": "2", "pid": "0", "n": "b"},
+			{"fid": "10", "cid": "0", "n": "c.txt"},
+		},
+		"1": {
+			{"fid": "11", "cid": "1", "n": "d.txt"},
+			// cycle back to root
+			{"cid": "0", "pid": "1", "n": "loop"},
+		},
+		"2": {
+			{"fid": "12", "cid": "2", "n": "e.txt"},
+		},
+	})
+	defer s.Close()
+
+	var paths []string
+	err := New().Walk("0", func(p string, f *File) error {
+		paths = append(paths, p)
+		if p == "/b" {
+			return fs.SkipDir
+		}
+		return nil
+	}, WithApiURL(s.URL))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/a", "/a/d.txt", "/a/loop", "/b", "/c.txt"}, paths)
+}
+
+func TestResumeState(t *testing.T) {
+	buf := &bytes.Buffer{}
+	state, err := LoadResumeState(buf)
+	assert.NoError(t, err)
+	assert.Empty(t, state.UploadID)
+
+	assert.NoError(t, SaveResumeState(buf, &ResumeState{UploadID: "1"}))
+	assert.NoError(t, SaveResumeState(buf, &ResumeState{
+		UploadID: "1",
+		Parts:    []ResumePart{{Number: 1, ETag: "etag"}},
+	}))
+	state, err = LoadResumeState(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", state.UploadID)
+	assert.Equal(t, []ResumePart{{Number: 1, ETag: "etag"}}, state.Parts)
+
+	_, err = LoadResumeState(strings.NewReader("{bad"))
+	assert.Error(t, err)
+}
+
+func TestDownloadStreamRange(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, UA115Browser, r.UserAgent())
+		http.ServeContent(w, r, "f", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	defer s.Close()
+
+	c := New()
+	info := &DownloadInfo{
+		FileSize: 10,
+		Url:      FileDownloadUrl{Url: s.URL},
+		Header:   http.Header{"User-Agent": {UA115Browser}},
+	}
+	o := DefaultDownloadOptions()
+	DownloadWithRange(2, 5)(o)
+	body, size, err := c.downloadStream(context.Background(), info, o)
+	assert.NoError(t, err)
+	defer body.Close()
+	b, _ := io.ReadAll(body)
+	assert.Equal(t, int64(4), size)
+	assert.Equal(t, "2345", string(b))
+}
+
+func TestRefreshingBody(t *testing.T) {
+	data := []byte("0123456789")
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/old":
+			w.WriteHeader(http.StatusForbidden)
+		case r.Header.Get("Range") == "":
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			_, _ = w.Write(data[:4])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		default:
+			http.ServeContent(w, r, "f", time.Time{}, bytes.NewReader(data))
+		}
+	}))
+	defer s.Close()
+
+	c := New()
+	info := &DownloadInfo{FileSize: 10, PickCode: "pc", Url: FileDownloadUrl{Url: s.URL + "/old"}, Header: http.Header{}}
+	o := DefaultDownloadOptions()
+	DownloadWithAutoRefreshURL(false)(o)
+	_, _, err := c.downloadStream(context.Background(), info, o)
+	assert.ErrorIs(t, err, ErrDownloadURLExpired)
+
+	refreshed := 0
+	body, _, err := c.newRefreshingBody(context.Background(), info, DefaultDownloadOptions(), func(ctx context.Context) (*DownloadInfo, error) {
+		refreshed++
+		return &DownloadInfo{FileSize: 10, Url: FileDownloadUrl{Url: s.URL + "/new"}, Header: http.Header{}}, nil
+	})
+	assert.NoError(t, err)
+	defer body.Close()
+	b, err := io.ReadAll(body)
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+	assert.Equal(t, 1, refreshed)
+}
+
+func TestDownloadToProgress(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 100*KB)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "f", time.Time{}, bytes.NewReader(data))
+	}))
+	defer s.Close()
+
+	var reports [][2]int64
+	o := DefaultDownloadOptions()
+	DownloadWithProgress(func(transferred, total int64) {
+		reports = append(reports, [2]int64{transferred, total})
+	}, 40*KB)(o)
+	info := &DownloadInfo{FileSize: StringInt64(len(data)), Url: FileDownloadUrl{Url: s.URL}, Header: http.Header{}}
+	buf := &bytes.Buffer{}
+	n, err := New().downloadTo(context.Background(), buf, info, o)
+	assert.NoError(t, err)
+	assert.EqualValues(t, len(data), n)
+	assert.Equal(t, data, buf.Bytes())
+	assert.NotEmpty(t, reports)
+	for i, r := range reports {
+		assert.EqualValues(t, len(data), r[1])
+		if i > 0 && i < len(reports)-1 {
+			assert.GreaterOrEqual(t, r[0]-reports[i-1][0], int64(40*KB))
+		}
+	}
+	assert.EqualValues(t, len(data), reports[len(reports)-1][0])
+}
+
+func TestProgressSkip(t *testing.T) {
+	var reports []int64
+	p := newProgress(func(transferred, total int64) { reports = append(reports, transferred) }, 30, 0)
+	p.skip(10)
+	p.add(10)
+	p.add(10)
+	assert.Equal(t, []int64{20, 30}, reports)
+	newProgress(nil, 0, 0).add(1)
+}
+
+func TestDownloadToFile(t *testing.T) {
+	data := []byte("0123456789")
+	var ranges []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "f", time.Time{}, bytes.NewReader(data))
+	}))
+	defer s.Close()
+
+	c := New()
+	dest := t.TempDir() + "/f"
+	info := &DownloadInfo{FileSize: StringInt64(len(data)), Url: FileDownloadUrl{Url: s.URL}, Header: http.Header{}}
+	sum := fmt.Sprintf("%X", sha1.Sum(data))
+
+	assert.NoError(t, os.WriteFile(dest+".part", data[:4], 0o644))
+	assert.NoError(t, c.downloadToFile(context.Background(), info, sum, dest, DefaultDownloadOptions()))
+	assert.Equal(t, []string{"bytes=4-"}, ranges)
+	b, err := os.ReadFile(dest)
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+	_, err = os.Stat(dest + ".part")
+	assert.True(t, os.IsNotExist(err))
+
+	err = c.downloadToFile(context.Background(), info, "0000", dest+"2", DefaultDownloadOptions())
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	_, err = os.Stat(dest + "2.part")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDownloadParallel(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	sum := fmt.Sprintf("%X", sha1.Sum(data))
+	for _, ranged := range []bool{true, false} {
+		var requests int32
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			if !ranged {
+				r.Header.Del("Range")
+			}
+			http.ServeContent(w, r, "f", time.Time{}, bytes.NewReader(data))
+		}))
+
+		var transferred int64
+		o := DefaultParallelOptions()
+		ParallelWithChunkSize(1000)(o)
+		ParallelWithProgress(func(n, total int64) { atomic.StoreInt64(&transferred, n) }, 0)(o)
+		dest := t.TempDir() + "/f"
+		info := &DownloadInfo{FileSize: StringInt64(len(data)), Url: FileDownloadUrl{Url: s.URL}, Header: http.Header{}}
+		assert.NoError(t, New().downloadParallel(context.Background(), info, sum, dest, o))
+		b, err := os.ReadFile(dest)
+		assert.NoError(t, err)
+		assert.Equal(t, data, b)
+		assert.EqualValues(t, len(data), atomic.LoadInt64(&transferred))
+		if ranged {
+			assert.EqualValues(t, 10, atomic.LoadInt32(&requests))
+		}
+		s.Close()
+	}
+}
+
+func TestBandwidthLimit(t *testing.T) {
+	c := New()
+	r := c.limitReader(context.Background(), bytes.NewReader(make([]byte, 10)))
+	_, ok := r.(*bytes.Reader)
+	assert.True(t, ok)
+
+	c.SetBandwidthLimit(100 * KB)
+	lim := c.bandwidth.Load()
+	assert.Equal(t, rate.Limit(100*KB), lim.Limit())
+	assert.Equal(t, 100*KB, lim.Burst())
+	lr, ok := c.limitReader(context.Background(), bytes.NewReader(make([]byte, MB))).(*limitedReader)
+	assert.True(t, ok)
+	assert.Same(t, lim, lr.lim)
+	// a read takes at most a burst and spends its tokens
+	n, err := lr.Read(make([]byte, MB))
+	assert.NoError(t, err)
+	assert.Equal(t, 100*KB, n)
+	assert.Less(t, lim.Tokens(), float64(50*KB))
+
+	// the limit is lifted for the reader in flight too
+	c.SetBandwidthLimit(0)
+	assert.Equal(t, rate.Inf, lim.Limit())
+	n, err = lr.Read(make([]byte, MB))
+	assert.NoError(t, err)
+	assert.Equal(t, MB-100*KB, n)
+}
+
+func TestCredentialRefresher(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if ck, err := r.Cookie(CookieNameSeid); err == nil && ck.Value == "new" {
+			_, _ = w.Write([]byte(`{"state":true}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"state":false,"errno":99}`))
+	}))
+	defer s.Close()
+
+	calls := int32(0)
+	c := New().ImportCredential(&Credential{UID: "1", CID: "2", SEID: "old"})
+	c.SetCredentialRefresher(func() (*Credential, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return &Credential{UID: "1", CID: "2", SEID: "new"}, nil
+	})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := BasicResp{}
+			resp, err := c.Client.R().SetResult(&result).Get(s.URL)
+			assert.NoError(t, CheckErr(err, &result, resp))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, "new", c.cookieValue(CookieNameSeid))
+}
+
+func TestSetCookiesConcurrent(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Len(t, r.Cookies(), 1)
+		_, _ = w.Write([]byte(`{"state":true}`))
+	}))
+	defer s.Close()
+
+	c := New().SetCookies(&http.Cookie{Name: CookieNameSeid, Value: "0"})
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := c.Client.R().Get(s.URL)
+			assert.NoError(t, err)
+		}()
+		go func(i int) {
+			defer wg.Done()
+			c.SetCookies(&http.Cookie{Name: CookieNameSeid, Value: strconv.Itoa(i)})
+		}(i)
+	}
+	wg.Wait()
+	assert.Len(t, c.Cookies(), 1)
+}
+
+func TestCredentialRefresherSetHttpClient(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if ck, err := r.Cookie(CookieNameSeid); err == nil && ck.Value == "new" {
+			_, _ = w.Write([]byte(`{"state":true}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"state":false,"errno":99}`))
+	}))
+	defer s.Close()
+
+	calls := 0
+	c := New().SetCredentialRefresher(func() (*Credential, error) {
+		calls++
+		return &Credential{UID: "1", CID: "2", SEID: "new"}, nil
+	})
+	c.SetHttpClient(&http.Client{})
+	c.ImportCredential(&Credential{UID: "1", CID: "2", SEID: "old"})
+
+	result := BasicResp{}
+	resp, err := c.Client.R().SetResult(&result).Get(s.URL)
+	assert.NoError(t, CheckErr(err, &result, resp))
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryPolicy(t *testing.T) {
+	calls := int32(0)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			_, _ = w.Write([]byte(`{"state":false,"errno":1234}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"state":true}`))
+	}))
+	defer s.Close()
+
+	c := New(WithRetryPolicy(RetryPolicy{
+		MaxRetries:     3,
+		BaseDelay:      time.Millisecond,
+		RetryableCodes: []int{1234},
+	}))
+	result := BasicResp{}
+	resp, err := c.NewRequest().SetResult(&result).Get(s.URL)
+	assert.NoError(t, CheckErr(err, &result, resp))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// the policy survives replacing the http client
+	atomic.StoreInt32(&calls, 0)
+	c.SetHttpClient(&http.Client{})
+	result = BasicResp{}
+	resp, err = c.NewRequest().SetResult(&result).Get(s.URL)
+	assert.NoError(t, CheckErr(err, &result, resp))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+type countingTransport struct {
+	count int32
+}
+
+func (t *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.count, 1)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestWithTransport(t *testing.T) {
+	s := newFileListServer(3)
+	defer s.Close()
+
+	rt := &countingTransport{}
+	c := New(WithTransport(rt))
+	req := c.NewRequest().ForceContentType("application/json;charset=UTF-8")
+	_, err := GetFiles(req, "1", WithApiURL(s.URL))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&rt.count))
+}
+
+func TestSetProxyURL(t *testing.T) {
+	c := New()
+	assert.ErrorIs(t, c.SetProxyURL("ftp://127.0.0.1:21"), ErrWrongParams)
+	assert.ErrorIs(t, c.SetProxyURL("socks5://"), ErrWrongParams)
+	assert.False(t, c.Client.IsProxySet())
+	assert.NoError(t, c.SetProxyURL("socks5://127.0.0.1:1080"))
+	assert.True(t, c.Client.IsProxySet())
+}
+
+// rewriteTransport sends all requests to the test server.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t *rewriteTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme = t.target.Scheme
+	r.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// newMockClient returns a client whose requests are all served by handler.
+func newMockClient(handler http.HandlerFunc) (*Pan115Client, func()) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		handler(w, r)
+	}))
+	u, _ := url.Parse(s.URL)
+	return New(WithTransport(&rewriteTransport{target: u})), s.Close
+}
+
+func TestDeleteFiles(t *testing.T) {
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rb/delete", r.URL.Path)
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "9", r.PostForm.Get("pid"))
+		if r.PostForm.Get("fid[1]") == "" {
+			_, _ = w.Write([]byte(`{"state":false,"errno":990002}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"state":true}`))
+	})
+	defer done()
+
+	ids := make([]string, MaxBatchSize+1)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i)
+	}
+	err := c.DeleteFiles("9", ids...)
+	var batchErr *BatchError
+	assert.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, []string{strconv.Itoa(MaxBatchSize)}, batchErr.Failed)
+	assert.ErrorIs(t, err, ErrWrongParams)
+	assert.NoError(t, c.DeleteFiles("9", "1", "2"))
+}
+
+func TestSearch(t *testing.T) {
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		assert.Equal(t, "/files/search", r.URL.Path)
+		assert.Equal(t, "movie", q.Get("search_value"))
+		assert.Equal(t, "5", q.Get("cid"))
+		assert.Equal(t, "4", q.Get("type"))
+		_, _ = w.Write([]byte(`{"state":true,"count":1,"data":[{"fid":"1","cid":"5","n":"movie.mkv"}]}`))
+	})
+	defer done()
+
+	result, err := c.Search("movie", SearchWithCID("5"), SearchWithFileType(FileTypeVideo))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Count)
+	assert.Equal(t, "movie.mkv", result.Files[0].Name)
+}
+
+func TestListRecent(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	start := time.Date(2024, 1, 2, 0, 0, 0, 0, loc)
+	entries := []string{`{"cid":"70","pid":"7","n":"dir","t":"1704124800"}`}
+	for i := 0; i < 100; i++ {
+		mtime := start.Add(-time.Duration(i) * time.Minute).Format("2006-01-02 15:04")
+		entries = append(entries, fmt.Sprintf(`{"fid":"%d","cid":"7","n":"f%d","t":"%s"}`, i, i, mtime))
+	}
+	var limits []string
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		assert.Equal(t, "/files/search", r.URL.Path)
+		assert.Equal(t, FileOrderByUpdateTime, q.Get("o"))
+		assert.Equal(t, "0", q.Get("asc"))
+		assert.Equal(t, "7", q.Get("cid"))
+		limits = append(limits, q.Get("limit"))
+		offset, _ := strconv.Atoi(q.Get("offset"))
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		end := offset + limit
+		if end > len(entries) {
+			end = len(entries)
+		}
+		_, _ = fmt.Fprintf(w, `{"state":true,"count":%d,"data":[%s]}`, len(entries), strings.Join(entries[offset:end], ","))
+	})
+	defer done()
+
+	files, err := c.ListRecent("7", start.Add(-50*time.Minute), 0)
+	assert.NoError(t, err)
+	assert.Len(t, files, 51)
+	assert.Equal(t, "0", files[0].FileID)
+	assert.Equal(t, "50", files[50].FileID)
+	assert.Equal(t, []string{"32", "64"}, limits)
+
+	files, err = c.ListRecent("7", time.Time{}, 10)
+	assert.NoError(t, err)
+	assert.Len(t, files, 10)
+}
+
+func TestEnableCache(t *testing.T) {
+	calls := map[string]int{}
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		calls[r.URL.Path]++
+		switch r.URL.Path {
+		case "/files":
+			_, _ = w.Write([]byte(`{"state":true,"cid":"5","count":1,"data":[{"fid":"1","cid":"5","n":"a"}]}`))
+		case "/category/get":
+			_, _ = w.Write([]byte(`{"file_name":"a","file_category":"1","paths":[{"file_id":5,"file_name":"d"}]}`))
+		case "/files/move", "/files/star", "/files/update", "/share/receive":
+			_, _ = w.Write([]byte(`{"state":true}`))
+		}
+	})
+	defer done()
+	c.UserID = 7
+
+	c.EnableCache(time.Minute)
+	for i := 0; i < 2; i++ {
+		files, err := c.List("5")
+		assert.NoError(t, err)
+		assert.Len(t, *files, 1)
+		info, err := c.Stat("1")
+		assert.NoError(t, err)
+		assert.Equal(t, "a", info.Name)
+	}
+	assert.Equal(t, 1, calls["/files"])
+	assert.Equal(t, 1, calls["/category/get"])
+
+	_, err := c.ListWithLimit("5", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls["/files"])
+
+	// moving the file out drops the listing of its old parent and its stat
+	assert.NoError(t, c.Move("9", "1"))
+	_, err = c.List("5")
+	assert.NoError(t, err)
+	_, err = c.Stat("1")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls["/files"])
+	assert.Equal(t, 2, calls["/category/get"])
+
+	c.InvalidateCID("5")
+	_, err = c.List("5")
+	assert.NoError(t, err)
+	_, err = c.Stat("1")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, calls["/files"])
+	assert.Equal(t, 3, calls["/category/get"])
+
+	// starring, touching and saving a share into the directory drop it too
+	for i, change := range []func() error{
+		func() error { return c.StarFile("1", true) },
+		func() error { return c.SetFileTime("1", time.Now()) },
+		func() error { return c.SaveShareToDir("sw", "pass", []string{"2"}, "5") },
+	} {
+		assert.NoError(t, change())
+		_, err = c.List("5")
+		assert.NoError(t, err)
+		assert.Equal(t, 5+i, calls["/files"])
+	}
+
+	c.EnableCache(0)
+	_, err = c.List("5")
+	assert.NoError(t, err)
+	assert.Equal(t, 8, calls["/files"])
+}
+
+func TestStatByPath(t *testing.T) {
+	var calls int32
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		switch r.URL.Path {
+		case "/files/getid":
+			assert.Equal(t, "Movies", r.URL.Query().Get("path"))
+			_, _ = w.Write([]byte(`{"state":true,"id":"10"}`))
+		default:
+			assert.Equal(t, "10", r.URL.Query().Get("cid"))
+			_, _ = w.Write([]byte(`{"state":true,"cid":"10","count":2,"data":[` +
+				`{"fid":"1","cid":"10","n":"a.mkv","pc":"pa"},` +
+				`{"fid":"2","cid":"10","n":"b.mkv","pc":"pb"},` +
+				`{"cid":"11","pid":"10","n":"extras"}]}`))
+		}
+	})
+	defer done()
+
+	f, err := c.StatByPath("/Movies/a.mkv")
+	assert.NoError(t, err)
+	assert.Equal(t, "pa", f.PickCode)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+
+	f, err = c.StatByPath("/Movies/b.mkv")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", f.FileID)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+
+	f, err = c.StatByPath("/Movies/extras")
+	assert.NoError(t, err)
+	assert.True(t, f.IsDirectory)
+	assert.Equal(t, "11", f.FileID)
+	_, err = c.StatByPath("/Movies/a.mkv/x")
+	assert.ErrorIs(t, err, ErrNotExist)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+
+	_, err = c.StatByPath("/Movies/c.mkv")
+	assert.ErrorIs(t, err, ErrNotExist)
+	assert.EqualValues(t, 3, atomic.LoadInt32(&calls))
+
+	c.InvalidatePathCache("/Movies")
+	_, err = c.StatByPath("/Movies/a.mkv")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, atomic.LoadInt32(&calls))
+}
+
+func TestMoveByPath(t *testing.T) {
+	dirs := map[string]string{"Movies": "10"}
+	var moved url.Values
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/files/getid":
+			_, _ = fmt.Fprintf(w, `{"state":true,"id":"%s"}`, dirs[r.URL.Query().Get("path")])
+		case "/files/add":
+			_ = r.ParseForm()
+			assert.Equal(t, "Archive", r.PostForm.Get("cname"))
+			assert.Equal(t, "10", r.PostForm.Get("pid"))
+			_, _ = w.Write([]byte(`{"state":true,"cid":"11","cname":"Archive"}`))
+		case "/files/move":
+			_ = r.ParseForm()
+			moved = r.PostForm
+			_, _ = w.Write([]byte(`{"state":true}`))
+		}
+	})
+	defer done()
+
+	err := c.MoveByPath("/Movies/Archive", []string{"1"})
+	assert.ErrorIs(t, err, ErrNotExist)
+	assert.Nil(t, moved)
+
+	err = c.MoveByPath("/Movies/Archive", []string{"1", "2"}, MoveByPathWithCreateMissing(true))
+	assert.NoError(t, err)
+	assert.Equal(t, "11", moved.Get("pid"))
+	assert.Equal(t, "2", moved.Get("fid[1]"))
+}
+
+func TestMkdirAll(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		dirs  = map[string]string{"a": "10"}
+		names = map[string]string{"10": "a"}
+		adds  int32
+	)
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/files/getid":
+			_, _ = fmt.Fprintf(w, `{"state":true,"id":"%s"}`, dirs[r.URL.Query().Get("path")])
+		case "/files/add":
+			atomic.AddInt32(&adds, 1)
+			_ = r.ParseForm()
+			p := names[r.PostForm.Get("pid")] + "/" + r.PostForm.Get("cname")
+			if _, ok := dirs[p]; ok {
+				_, _ = w.Write([]byte(`{"state":false,"errno":20004,"error":"exists"}`))
+				return
+			}
+			cid := strconv.Itoa(10 + len(dirs))
+			dirs[p], names[cid] = cid, p
+			_, _ = fmt.Fprintf(w, `{"state":true,"cid":"%s"}`, cid)
+		}
+	})
+	defer done()
+	c.SetPathCache(0, 0)
+
+	var wg sync.WaitGroup
+	cids := make([]string, 8)
+	for i := range cids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cid, err := c.MkdirAll("/a/b/c")
+			assert.NoError(t, err)
+			cids[i] = cid
+		}(i)
+	}
+	wg.Wait()
+	for _, cid := range cids {
+		assert.Equal(t, dirs["a/b/c"], cid)
+	}
+
+	cid, err := c.MkdirAll("a/b/c/")
+	assert.NoError(t, err)
+	assert.Equal(t, dirs["a/b/c"], cid)
+	assert.Len(t, dirs, 3)
+}
+
+func TestOfflineTaskResults(t *testing.T) {
+	body := `{"state":true,"result":[` +
+		`{"state":true,"info_hash":"h1","name":"a","url":"magnet:?xt=a"},` +
+		`{"state":false,"errcode":10008,"error_msg":"task existed","info_hash":"h2","url":"magnet:?xt=b"},` +
+		`{"state":false,"errno":"10004","url":"bad"}]}`
+	resp := OfflineAddUrlResponse{}
+	assert.NoError(t, json.Unmarshal([]byte(body), &resp))
+
+	results := offlineTaskResults([]string{"bad", "magnet:?xt=a", "magnet:?xt=b"}, resp.Result)
+	assert.Len(t, results, 3)
+	assert.True(t, results[0].IsInvalid())
+	assert.True(t, results[1].Accepted())
+	assert.Equal(t, "h1", results[1].InfoHash)
+	assert.True(t, results[2].IsDuplicate())
+	assert.Equal(t, "h2", results[2].InfoHash)
+}
+
+func TestWaitOfflineTask(t *testing.T) {
+	var polls int32
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		if page == "1" {
+			_, _ = w.Write([]byte(`{"state":true,"page_count":2,"count":2,"tasks":[{"info_hash":"other","status":2}]}`))
+			return
+		}
+		status := 1
+		if atomic.AddInt32(&polls, 1) >= 3 {
+			status = 2
+		}
+		_, _ = fmt.Fprintf(w, `{"state":true,"page_count":2,"count":2,"tasks":[{"info_hash":"h1","status":%d,"percentDone":50}]}`, status)
+	})
+	defer done()
+
+	result, err := c.ListOfflineTasks(1)
+	assert.NoError(t, err)
+	assert.True(t, result.HasNextPage())
+	assert.EqualValues(t, 2, result.Total)
+
+	assert.NoError(t, c.WaitOfflineTask("h1", time.Millisecond))
+	assert.EqualValues(t, 3, atomic.LoadInt32(&polls))
+	assert.ErrorIs(t, c.WaitOfflineTask("missing", time.Millisecond), ErrNotExist)
+	assert.ErrorIs(t, c.WaitOfflineTask("h1", 0), ErrWrongParams)
+
+	opt := DefaultOfflineOptions()
+	WithPollInterval(0)(&opt)
+	assert.Equal(t, DefaultOfflineOptions().pollInterval, opt.pollInterval)
+}
+
+func TestMoveOfflineResult(t *testing.T) {
+	var moved []string
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/lixian/":
+			_, _ = w.Write([]byte(`{"state":true,"page_count":1,"tasks":[` +
+				`{"info_hash":"h1","name":"movie","status":2,"file_id":"7","wp_path_id":"3"},` +
+				`{"info_hash":"h2","name":"single.mkv","status":2,"wp_path_id":"3"}]}`))
+		case "/files":
+			_, _ = w.Write([]byte(`{"state":true,"cid":"3","count":2,"data":[{"fid":"8","cid":"3","n":"other"},{"fid":"9","cid":"3","n":"single.mkv"}]}`))
+		case "/files/move":
+			_ = r.ParseForm()
+			moved = append(moved, r.PostForm.Get("pid")+"/"+r.PostForm.Get("fid[0]"))
+			_, _ = w.Write([]byte(`{"state":true}`))
+		}
+	})
+	defer done()
+
+	assert.NoError(t, c.moveOfflineResult(context.Background(), "h1", "100", time.Millisecond))
+	assert.NoError(t, c.moveOfflineResult(context.Background(), "h2", "100", time.Millisecond))
+	assert.Equal(t, []string{"100/7", "100/9"}, moved)
+}
+
+func TestReadOfflineURLs(t *testing.T) {
+	urls, err := readOfflineURLs(strings.NewReader("# magnets\n" +
+		"magnet:?xt=urn:btih:a\r\n" +
+		"\n" +
+		"  https://example.com/a.iso  \n" +
+		"magnet:?xt=urn:btih:a\n" +
+		"ed2k://|file|b|1|c|/"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"magnet:?xt=urn:btih:a", "https://example.com/a.iso", "ed2k://|file|b|1|c|/"}, urls)
+}
+
+func TestOfflineSaveDir(t *testing.T) {
+	saveDir := "5"
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/offine/downpath", r.URL.Path)
+		if r.Method == http.MethodPost {
+			_ = r.ParseForm()
+			saveDir = r.PostForm.Get("file_id")
+			_, _ = w.Write([]byte(`{"state":true}`))
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"state":true,"data":[{"id":"1","file_id":%q,"file_name":"云下载"}]}`, saveDir)
+	})
+	defer done()
+
+	cid, err := c.GetOfflineSaveDir()
+	assert.NoError(t, err)
+	assert.Equal(t, "5", cid)
+	assert.NoError(t, c.SetOfflineSaveDir("6"))
+	cid, err = c.GetOfflineSaveDir()
+	assert.NoError(t, err)
+	assert.Equal(t, "6", cid)
+}
+
+func TestClearOfflineTasks(t *testing.T) {
+	var flags []string
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		flags = append(flags, r.PostForm.Get("flag"))
+		_, _ = w.Write([]byte(`{"state":true}`))
+	})
+	defer done()
+
+	assert.NoError(t, c.ClearOfflineTasks(OfflineClearFailed))
+	assert.NoError(t, c.DeleteOfflineTasks([]string{"h1"}, true))
+	assert.Equal(t, []string{"2", "1"}, flags)
+}
+
+func TestCheckSpace(t *testing.T) {
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"state":true,"data":{"space_info":{` +
+			`"all_total":{"size":1000,"size_format":"1000B"},` +
+			`"all_remain":{"size":400,"size_format":"400B"},` +
+			`"all_use":{"size":600,"size_format":"600B"}}}}`))
+	})
+	defer done()
+
+	info, err := c.GetSpaceInfo()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1000, info.Total())
+	assert.EqualValues(t, 600, info.Used())
+	assert.EqualValues(t, 400, info.Remaining())
+
+	assert.NoError(t, c.CheckSpace(400))
+	assert.ErrorIs(t, c.CheckSpace(401), ErrInsufficientSpace)
+}
+
+func TestComputeUploadDigest(t *testing.T) {
+	sha1Hex, preIDHex, err := ComputeUploadDigest(strings.NewReader(""), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "DA39A3EE5E6B4B0D3255BFEF95601890AFD80709", sha1Hex)
+	assert.Equal(t, sha1Hex, preIDHex)
+
+	sha1Hex, preIDHex, err = ComputeUploadDigest(strings.NewReader("abc"), -1)
+	assert.NoError(t, err)
+	assert.Equal(t, "A9993E364706816ABA3E25717850C26C9CD0D89D", sha1Hex)
+	assert.Equal(t, sha1Hex, preIDHex)
+
+	data := bytes.Repeat([]byte("115driver"), 20000)
+	sha1Hex, preIDHex, err = ComputeUploadDigest(bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("%X", sha1.Sum(data)), sha1Hex)
+	assert.Equal(t, fmt.Sprintf("%X", sha1.Sum(data[:128*1024])), preIDHex)
+
+	_, _, err = ComputeUploadDigest(bytes.NewReader(data), 1)
+	assert.ErrorIs(t, err, ErrWrongParams)
+}
+
+func TestQRCodeLoginPoll(t *testing.T) {
+	var polls int32
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/1.0/web/1.0/token":
+			_, _ = w.Write([]byte(`{"state":1,"data":{"uid":"u1","time":1,"sign":"s","qrcode":"https://115.com/scan/u1"}}`))
+		case "/get/status/":
+			assert.Equal(t, "u1", r.URL.Query().Get("uid"))
+			_, _ = fmt.Fprintf(w, `{"state":1,"data":{"status":%d}}`, atomic.AddInt32(&polls, 1)-1)
+		case "/app/1.0/tv/1.0/login/qrcode":
+			_ = r.ParseForm()
+			assert.Equal(t, "u1", r.PostForm.Get("account"))
+			_, _ = w.Write([]byte(`{"state":1,"data":{"cookie":{"UID":"1","CID":"2","SEID":"3","KID":"4"}}}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+		}
+	})
+	defer done()
+
+	s, err := c.QRCodeLoginStart(LoginAppTV)
+	assert.NoError(t, err)
+	assert.Equal(t, LoginAppTV, s.App)
+
+	var cr *Credential
+	for i := 0; cr == nil && i < 3; i++ {
+		var status *QRCodeStatus
+		status, cr, err = c.QRCodeLoginPoll(s)
+		assert.NoError(t, err)
+		assert.Equal(t, i, status.Status)
+	}
+	assert.Equal(t, &Credential{UID: "1", CID: "2", SEID: "3", KID: "4"}, cr)
+}
+
+func TestLoginCheckDetail(t *testing.T) {
+	loggedIn := true
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case !loggedIn:
+			_, _ = w.Write([]byte(`{"state":1,"code":990001,"message":"not login"}`))
+		case r.Host == "passportapi.115.com":
+			_, _ = w.Write([]byte(`{"state":0,"data":{"user_id":42}}`))
+		default:
+			_, _ = w.Write([]byte(`{"state":true,"data":{"user_id":42,"user_name":"tester"}}`))
+		}
+	})
+	defer done()
+
+	user, err := c.LoginCheckDetail()
+	assert.NoError(t, err)
+	assert.Equal(t, "tester", user.UserName)
+	assert.EqualValues(t, 42, c.UserID)
+
+	loggedIn = false
+	_, err = c.LoginCheckDetail()
+	var loginErr *LoginError
+	assert.True(t, errors.As(err, &loginErr))
+	assert.Equal(t, LoginErrNotLoggedIn, loginErr.Kind)
+	assert.ErrorIs(t, err, ErrNotLogin)
+
+	done()
+	_, err = c.LoginCheckDetail()
+	assert.True(t, errors.As(err, &loginErr))
+	assert.Equal(t, LoginErrNetwork, loginErr.Kind)
+}
+
+func TestGetVIPInfo(t *testing.T) {
+	expire := time.Now().Add(time.Hour).Unix()
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"state":true,"data":{"vip":1,"mark":10,"expire":%d,"forever":0,"user_id":1}}`, expire)
+	})
+	defer done()
+
+	info, err := c.GetVIPInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, 10, info.Level)
+	assert.True(t, info.Active)
+	assert.False(t, info.Forever)
+	assert.Equal(t, expire, info.ExpiresAt.Unix())
+
+	info = newVIPInfo(&UserInfo{Vip: 1, Expire: 1}, time.Now())
+	assert.False(t, info.Active)
+	info = newVIPInfo(&UserInfo{Vip: 1, Forever: 1}, time.Now())
+	assert.True(t, info.Active)
+	assert.True(t, info.ExpiresAt.IsZero())
+	assert.False(t, newVIPInfo(&UserInfo{}, time.Now()).Active)
+}
+
+func TestPing(t *testing.T) {
+	loggedIn := true
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "ct=guide&ac=status", strings.SplitN(r.URL.RawQuery, "&_=", 2)[0])
+		if !loggedIn {
+			_, _ = w.Write([]byte(`{"state":false}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"state":true}`))
+	})
+	defer done()
+
+	assert.NoError(t, c.Ping())
+
+	loggedIn = false
+	err := c.Ping()
+	var loginErr *LoginError
+	assert.True(t, errors.As(err, &loginErr))
+	assert.Equal(t, LoginErrNotLoggedIn, loginErr.Kind)
+
+	done()
+	err = c.Ping()
+	assert.True(t, errors.As(err, &loginErr))
+	assert.Equal(t, LoginErrNetwork, loginErr.Kind)
+}
+
+func TestCreateShare(t *testing.T) {
+	var update url.Values
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		switch r.URL.Path {
+		case "/share/send":
+			assert.Equal(t, "1,2", r.PostForm.Get("file_ids"))
+			assert.Equal(t, "7", r.PostForm.Get("user_id"))
+			_, _ = w.Write([]byte(`{"state":true,"data":{"share_code":"swabc","receive_code":"rand","share_title":"t"}}`))
+		case "/share/updateshare":
+			update = r.PostForm
+			_, _ = w.Write([]byte(`{"state":true}`))
+		}
+	})
+	defer done()
+	c.UserID = 7
+
+	info, err := c.CreateShare([]string{"1", "2"}, ShareWithDuration(ShareDurationForever), ShareWithReceiveCode("pass"))
+	assert.NoError(t, err)
+	assert.Equal(t, "-1", update.Get("share_duration"))
+	assert.Equal(t, "pass", update.Get("receive_code"))
+	assert.Equal(t, "https://115.com/s/swabc?password=pass", info.URL)
+
+	shareCode, receiveCode, err := ParseShareURL(info.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "swabc", shareCode)
+	assert.Equal(t, "pass", receiveCode)
+	shareCode, receiveCode, err = ParseShareURL("https://115cdn.com/s/swabc#pass")
+	assert.NoError(t, err)
+	assert.Equal(t, "swabc", shareCode)
+	assert.Equal(t, "pass", receiveCode)
+	_, _, err = ParseShareURL("https://115.com/")
+	assert.ErrorIs(t, err, ErrWrongParams)
+}
+
+func TestCreateShareConcurrent(t *testing.T) {
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		switch r.URL.Path {
+		case "/":
+			_, _ = w.Write([]byte(`{"state":true,"data":{"user_id":7}}`))
+		case "/share/send":
+			assert.Equal(t, "7", r.PostForm.Get("user_id"))
+			_, _ = w.Write([]byte(`{"state":true,"data":{"share_code":"swabc","receive_code":"rand"}}`))
+		default:
+			_, _ = w.Write([]byte(`{"state":true}`))
+		}
+	})
+	defer done()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.CreateShare([]string{"1"})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.EqualValues(t, 7, c.UserID)
+}
+
+func TestGetShareList(t *testing.T) {
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("receive_code") != "pass" {
+			_, _ = w.Write([]byte(`{"state":false,"errno":4100012,"error":"wrong code"}`))
+			return
+		}
+		offset, _ := strconv.Atoi(q.Get("offset"))
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		list := []map[string]any{}
+		for i := offset; i < offset+limit && i < 1500; i++ {
+			list = append(list, map[string]any{"fid": strconv.Itoa(i), "n": "f" + strconv.Itoa(i)})
+		}
+		if offset == 0 {
+			list[0] = map[string]any{"cid": "99", "n": "dir"}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"state": true,
+			"data":  map[string]any{"count": 1500, "list": list, "shareinfo": map[string]any{"share_title": "t"}},
+		})
+	})
+	defer done()
+
+	list, err := c.GetShareList("swabc", "pass", "")
+	assert.NoError(t, err)
+	assert.Len(t, list.Files, 1500)
+	assert.True(t, list.Files[0].IsDirectory())
+	assert.Equal(t, "99", list.Files[0].ID())
+	assert.Equal(t, "1499", list.Files[1499].ID())
+
+	_, err = c.GetShareList("swabc", "nope", "")
+	assert.ErrorIs(t, err, ErrShareReceiveCodeIncorrect)
+}
+
+func TestSaveShareToDir(t *testing.T) {
+	var form url.Values
+	reply := `{"state":true}`
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		form = r.PostForm
+		_, _ = w.Write([]byte(reply))
+	})
+	defer done()
+	c.UserID = 7
+
+	assert.NoError(t, c.SaveShareToDir("swabc", "pass", []string{"1", "99"}, "5"))
+	assert.Equal(t, "1,99", form.Get("file_id"))
+	assert.Equal(t, "5", form.Get("cid"))
+	assert.Equal(t, "pass", form.Get("receive_code"))
+
+	reply = `{"state":false,"errno":4200045,"error":"文件已接收，无需重复接收！"}`
+	assert.ErrorIs(t, c.SaveShareToDir("swabc", "pass", []string{"1"}, "5"), ErrExist)
+	reply = `{"state":false,"errno":4100024,"error":"转存文件数超过上限"}`
+	assert.ErrorIs(t, c.SaveShareToDir("swabc", "pass", []string{"1"}, "5"), ErrShareSaveLimit)
+}
+
+func TestRestoreFromRecycle(t *testing.T) {
+	var reverted url.Values
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rb/revert":
+			_ = r.ParseForm()
+			reverted = r.PostForm
+			_, _ = w.Write([]byte(`{"state":true}`))
+		case "/files/get_info":
+			id := r.URL.Query().Get("file_id")
+			_, _ = fmt.Fprintf(w, `{"state":true,"data":[{"fid":"%s","cid":"p%s","n":"f"}]}`, id, id)
+		}
+	})
+	defer done()
+
+	restored, err := c.RestoreFromRecycle("1", "2")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", reverted.Get("rid[1]"))
+	assert.Equal(t, []RestoredFile{{FileID: "1", ParentID: "p1"}, {FileID: "2", ParentID: "p2"}}, restored)
+}
+
+func TestValidateFileName(t *testing.T) {
+	assert.NoError(t, ValidateFileName("movie.2024.mkv"))
+	assert.NoError(t, ValidateFileName(strings.Repeat("中", 255)))
+	for _, name := range []string{"", "  ", "a/b", `a\b`, "a:b", "a*", "a?", `a"`, "a<b>", "a|b", "a.", strings.Repeat("a", 256)} {
+		assert.ErrorIs(t, ValidateFileName(name), ErrInvalidFileName, name)
+	}
+}
+
+func TestRenameBatch(t *testing.T) {
+	var form url.Values
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		form = r.PostForm
+		_, _ = w.Write([]byte(`{"state":true}`))
+	})
+	defer done()
+
+	err := c.RenameBatch(map[string]string{"1": "a.mkv", "2": "b.mkv", "3": "bad/name"})
+	var batchErr *BatchError
+	assert.True(t, errors.As(err, &batchErr))
+	assert.Equal(t, []string{"3"}, batchErr.Failed)
+	assert.ErrorIs(t, err, ErrInvalidFileName)
+	assert.Equal(t, "a.mkv", form.Get("files_new_name[1]"))
+	assert.Equal(t, "b.mkv", form.Get("files_new_name[2]"))
+	assert.Empty(t, form.Get("files_new_name[3]"))
+}
+
+func TestCopyFiles(t *testing.T) {
+	var mu sync.Mutex
+	dest := []map[string]any{{"fid": "10", "cid": "5", "n": "a.mkv", "sha": "AA", "s": 1}}
+	var deleted, renamed url.Values
+	var calls []string
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, r.URL.Path)
+		switch r.URL.Path {
+		case "/files/get_info":
+			id := r.URL.Query().Get("file_id")
+			_, _ = fmt.Fprintf(w, `{"state":true,"data":[{"fid":"%s","cid":"1","n":"%s.mkv","sha":"%s","s":1}]}`,
+				id, map[string]string{"1": "a", "2": "b"}[id], map[string]string{"1": "AA", "2": "BB"}[id])
+		case "/rb/delete":
+			_ = r.ParseForm()
+			deleted = r.PostForm
+			dest = dest[1:]
+			_, _ = w.Write([]byte(`{"state":true}`))
+		case "/files/batch_rename":
+			_ = r.ParseForm()
+			renamed = r.PostForm
+			_, _ = w.Write([]byte(`{"state":true}`))
+		case "/files/copy":
+			dest = append(dest,
+				map[string]any{"fid": "21", "cid": "5", "n": "b.mkv", "sha": "BB", "s": 1},
+				map[string]any{"fid": "20", "cid": "5", "n": "a(1).mkv", "sha": "AA", "s": 1})
+			_, _ = w.Write([]byte(`{"state":true}`))
+		case "/files":
+			_ = json.NewEncoder(w).Encode(map[string]any{"state": true, "cid": "5", "count": len(dest), "data": dest})
+		}
+	})
+	defer done()
+
+	copies, err := c.CopyFiles("5", []string{"1", "2"}, CopyWithOverwrite(true))
+	assert.NoError(t, err)
+	assert.Equal(t, "10", deleted.Get("fid[0]"))
+	assert.Equal(t, "a.mkv", renamed.Get("files_new_name[20]"))
+	assert.Less(t, indexOf(calls, "/files/copy"), indexOf(calls, "/rb/delete"))
+	assert.Len(t, copies, 2)
+	assert.Equal(t, "20", copies[0].FileID)
+	assert.Equal(t, "a.mkv", copies[0].Name)
+	assert.Equal(t, "21", copies[1].FileID)
+}
+
+func TestCopyFilesOverwriteSameParent(t *testing.T) {
+	var mu sync.Mutex
+	dest := []map[string]any{{"fid": "1", "cid": "5", "n": "a.mkv", "sha": "AA", "s": 1}}
+	var calls []string
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, r.URL.Path)
+		switch r.URL.Path {
+		case "/files/get_info":
+			_, _ = w.Write([]byte(`{"state":true,"data":[{"fid":"1","cid":"5","n":"a.mkv","sha":"AA","s":1}]}`))
+		case "/files/copy":
+			dest = append(dest, map[string]any{"fid": "20", "cid": "5", "n": "a(1).mkv", "sha": "AA", "s": 1})
+			_, _ = w.Write([]byte(`{"state":true}`))
+		case "/files":
+			_ = json.NewEncoder(w).Encode(map[string]any{"state": true, "cid": "5", "count": len(dest), "data": dest})
+		default:
+			_, _ = w.Write([]byte(`{"state":true}`))
+		}
+	})
+	defer done()
+
+	copies, err := c.CopyFiles("5", []string{"1"}, CopyWithOverwrite(true))
+	assert.NoError(t, err)
+	assert.Len(t, copies, 1)
+	assert.Equal(t, "20", copies[0].FileID)
+	assert.Equal(t, "a(1).mkv", copies[0].Name)
+	assert.Equal(t, -1, indexOf(calls, "/rb/delete"))
+	assert.Equal(t, -1, indexOf(calls, "/files/batch_rename"))
+}
+
+func indexOf(s []string, v string) int {
+	for i := range s {
+		if s[i] == v {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestCopyTree(t *testing.T) {
+	var mu sync.Mutex
+	dest := []map[string]any{{"cid": "30", "pid": "5", "n": "archive"}}
+	copied := 0
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/files/get_info":
+			_, _ = w.Write([]byte(`{"state":true,"data":[{"cid":"7","pid":"1","n":"archive"}]}`))
+		case "/files/copy":
+			dest = append(dest, map[string]any{"cid": "31", "pid": "5", "n": "archive(1)"})
+			_, _ = w.Write([]byte(`{"state":true}`))
+		case "/files":
+			_ = json.NewEncoder(w).Encode(map[string]any{"state": true, "cid": "5", "count": len(dest), "data": dest})
+		case "/category/get":
+			count := 4
+			if r.URL.Query().Get("cid") == "31" {
+				copied += 2
+				count = copied
+			}
+			_, _ = fmt.Fprintf(w, `{"file_name":"archive","file_category":"0","count":"%d","folder_count":1,"size":"1KB"}`, count)
+		}
+	})
+	defer done()
+
+	var progress []int
+	dst, err := c.CopyTree("7", "5", CopyTreeWithPollInterval(time.Millisecond), CopyTreeWithProgress(func(n, total int) {
+		assert.Equal(t, 4, total)
+		progress = append(progress, n)
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, "31", dst.FileID)
+	assert.Equal(t, []int{2, 4}, progress)
+}
+
+func TestCopyTreeStalled(t *testing.T) {
+	var mu sync.Mutex
+	dest := []map[string]any{}
+	polls := 0
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/files/get_info":
+			_, _ = w.Write([]byte(`{"state":true,"data":[{"cid":"7","pid":"1","n":"archive"}]}`))
+		case "/files/copy":
+			dest = append(dest, map[string]any{"cid": "31", "pid": "5", "n": "archive"})
+			_, _ = w.Write([]byte(`{"state":true}`))
+		case "/files":
+			_ = json.NewEncoder(w).Encode(map[string]any{"state": true, "cid": "5", "count": len(dest), "data": dest})
+		case "/category/get":
+			count := 4
+			if r.URL.Query().Get("cid") == "31" {
+				polls++
+				count = 1
+			}
+			_, _ = fmt.Fprintf(w, `{"file_name":"archive","file_category":"0","count":"%d","folder_count":1,"size":"1KB"}`, count)
+		}
+	})
+	defer done()
+
+	_, err := c.CopyTree("7", "5", CopyTreeWithPollInterval(time.Millisecond), CopyTreeWithStallPolls(3))
+	assert.ErrorIs(t, err, ErrCopyStalled)
+	assert.Equal(t, 4, polls)
+}
+
+func TestSnapshotDir(t *testing.T) {
+	var mu sync.Mutex
+	var copied url.Values
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = r.ParseForm()
+		switch r.URL.Path {
+		case "/files/add":
+			if r.PostForm.Get("cname") == "snap" {
+				_, _ = w.Write([]byte(`{"state":false,"errno":20004}`))
+				return
+			}
+			assert.Equal(t, "snap (1)", r.PostForm.Get("cname"))
+			_, _ = w.Write([]byte(`{"state":true,"cid":"40"}`))
+		case "/files":
+			if cid := r.URL.Query().Get("cid"); cid == "5" {
+				_, _ = w.Write([]byte(`{"state":true,"cid":"5","count":1,"data":[{"cid":"30","pid":"5","n":"snap"}]}`))
+			} else {
+				_, _ = w.Write([]byte(`{"state":true,"cid":"7","count":2,"data":[{"fid":"1","cid":"7","n":"a"},{"cid":"2","pid":"7","n":"d"}]}`))
+			}
+		case "/files/copy":
+			copied = r.PostForm
+			_, _ = w.Write([]byte(`{"state":true}`))
+		case "/category/get":
+			_, _ = w.Write([]byte(`{"file_name":"d","file_category":"0","count":"3","folder_count":1,"size":"1KB"}`))
+		}
+	})
+	defer done()
+
+	cid, err := c.SnapshotDir("7", "5", "snap", CopyTreeWithPollInterval(time.Millisecond))
+	assert.NoError(t, err)
+	assert.Equal(t, "40", cid)
+	assert.Equal(t, "40", copied.Get("pid"))
+	assert.Equal(t, "1", copied.Get("fid[0]"))
+	assert.Equal(t, "2", copied.Get("fid[1]"))
+}
+
+func TestSnapshotDirCleanup(t *testing.T) {
+	var mu sync.Mutex
+	var deleted url.Values
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = r.ParseForm()
+		switch r.URL.Path {
+		case "/files/add":
+			_, _ = w.Write([]byte(`{"state":true,"cid":"40"}`))
+		case "/files":
+			_, _ = w.Write([]byte(`{"state":true,"cid":"7","count":1,"data":[{"fid":"1","cid":"7","n":"a"}]}`))
+		case "/files/copy":
+			_, _ = w.Write([]byte(`{"state":false,"errno":990002}`))
+		case "/rb/delete":
+			deleted = r.PostForm
+			_, _ = w.Write([]byte(`{"state":true}`))
+		case "/category/get":
+			_, _ = w.Write([]byte(`{"file_name":"d","file_category":"0","count":"1","folder_count":0,"size":"1KB"}`))
+		}
+	})
+	defer done()
+
+	cid, err := c.SnapshotDir("7", "5", "snap")
+	assert.Error(t, err)
+	assert.Empty(t, cid)
+	assert.Equal(t, "40", deleted.Get("fid[0]"))
+}
+
+func TestSignParams(t *testing.T) {
+	encoded, key, err := New().SignParams([]byte(`{"pickcode":"abc"}`))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, encoded)
+	assert.Len(t, key, 32)
+
+	_, err = DecodeParams(encoded, "bad")
+	assert.ErrorIs(t, err, ErrWrongParams)
+	_, err = DecodeParams("AAAA", key)
+	assert.ErrorIs(t, err, ErrUnexpected)
+}
+
+func TestDecodeEncryptedResponse(t *testing.T) {
+	key := strings.Repeat("0", 32)
+	_, err := DecodeEncryptedResponse([]byte(`{"state":false,"errno":50028,"error":"x"}`), key)
+	assert.ErrorIs(t, err, ErrDownloadFileTooBig)
+	_, err = DecodeEncryptedResponse([]byte(`{"state":true}`), key)
+	assert.ErrorIs(t, err, ErrUnexpected)
+	_, err = DecodeEncryptedResponse([]byte(`<html>`), key)
+	assert.ErrorIs(t, err, ErrUnexpected)
+}
+
+func TestCookies(t *testing.T) {
+	c := New()
+	assert.Empty(t, c.Cookies())
+	assert.Empty(t, c.CookieHeader())
+	c.ImportCredential(&Credential{UID: "u", CID: "c", SEID: "s", KID: "k"})
+	c.ImportCookies(map[string]string{CookieNameUid: "u"}, ".example.com")
+	assert.Len(t, c.Cookies(), 5)
+	header := c.CookieHeader()
+	for _, pair := range []string{"UID=u", "CID=c", "SEID=s", "KID=k"} {
+		assert.Contains(t, header, pair)
+	}
+	assert.Equal(t, 1, strings.Count(header, "UID="))
+
+	c.Cookies()[0].Value = "changed"
+	assert.NotEqual(t, "changed", c.Cookies()[0].Value)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			c.ImportCredential(&Credential{UID: "u", CID: "c", SEID: strconv.Itoa(i), KID: "k"})
+		}(i)
+		go func() {
+			defer wg.Done()
+			assert.Len(t, c.Cookies(), 5)
+			assert.Contains(t, c.CookieHeader(), "SEID=")
+		}()
+	}
+	wg.Wait()
+}
+
+func TestWithRequestHeaders(t *testing.T) {
+	var headers []http.Header
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header)
+		_, _ = w.Write([]byte(`{"state":true,"data":[{"fid":"1","cid":"5","n":"a"}]}`))
+	})
+	defer done()
+	c.SetUserAgent("ua")
+
+	ctx := WithRequestHeaders(context.Background(), map[string]string{"Referer": "https://115.com/"})
+	ctx = WithRequestHeaders(ctx, map[string]string{"Origin": "https://115.com", "User-Agent": "ua2"})
+	_, err := c.GetFileContext(ctx, "1")
+	assert.NoError(t, err)
+	_, err = c.GetFile("1")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://115.com/", headers[0].Get("Referer"))
+	assert.Equal(t, "https://115.com", headers[0].Get("Origin"))
+	assert.Equal(t, "ua2", headers[0].Get("User-Agent"))
+	assert.Empty(t, headers[1].Get("Referer"))
+	assert.Equal(t, "ua", headers[1].Get("User-Agent"))
+}
+
+func TestPlayURL(t *testing.T) {
+	c := New(UA("ua1"))
+	c.ImportCookies(map[string]string{CookieNameUid: "u"}, CookieDomain115)
+	p := c.playURL(&DownloadInfo{
+		Url:    FileDownloadUrl{Url: "https://cdnfhnfdfs.115.com/a?t=1700000000&u=1"},
+		Header: http.Header{"User-Agent": []string{"ua1"}},
+	})
+	assert.Equal(t, "https://cdnfhnfdfs.115.com/a?t=1700000000&u=1", p.URL)
+	assert.Equal(t, map[string]string{"User-Agent": "ua1", "Cookie": "UID=u"}, p.Headers)
+	assert.Equal(t, time.Unix(1700000000, 0), p.ExpiresAt)
+	p = c.playURL(&DownloadInfo{Header: http.Header{}})
+	assert.Equal(t, "ua1", p.Headers["User-Agent"])
+	assert.True(t, p.ExpiresAt.IsZero())
+
+	_, err := c.GetPlayURL("", "")
+	assert.ErrorIs(t, err, ErrPickCodeIsEmpty)
+}
+
+func TestDownloadApp(t *testing.T) {
+	_, err := New().DownloadApp("")
+	assert.ErrorIs(t, err, ErrPickCodeIsEmpty)
+}
+
+func TestFileIDToPickCode(t *testing.T) {
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("file_id") == "1" {
+			_, _ = w.Write([]byte(`{"state":true,"data":[{"fid":"1","cid":"0","n":"a","pc":"abc"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"state":true,"data":[{"fid":"2","cid":"0","n":"b"}]}`))
+	})
+	defer done()
+
+	pickCode, err := c.FileIDToPickCode("1")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", pickCode)
+	_, err = c.FileIDToPickCode("2")
+	assert.ErrorIs(t, err, ErrPickCodeIsEmpty)
+	_, err = c.PickCodeToFile("")
+	assert.ErrorIs(t, err, ErrPickCodeIsEmpty)
+}
+
+func TestNoOpPolicy(t *testing.T) {
+	var moved, renamed url.Values
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		switch r.URL.Path {
+		case "/files/get_info":
+			if r.URL.Query().Get("file_id") == "1" {
+				_, _ = w.Write([]byte(`{"state":true,"data":[{"fid":"1","cid":"9","n":"a"}]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"state":true,"data":[{"fid":"2","cid":"8","n":"b"}]}`))
+		case "/files/move":
+			moved = r.PostForm
+			_, _ = w.Write([]byte(`{"state":true}`))
+		case "/files/batch_rename":
+			renamed = r.PostForm
+			_, _ = w.Write([]byte(`{"state":true}`))
+		}
+	})
+	defer done()
+
+	c.SetNoOpPolicy(NoOpSkip)
+	assert.NoError(t, c.Move("9", "1"))
+	assert.Nil(t, moved)
+	assert.NoError(t, c.Rename("1", "a"))
+	assert.Nil(t, renamed)
+
+	c.SetNoOpPolicy(NoOpError)
+	assert.ErrorIs(t, c.Move("9", "1", "2"), ErrSameParent)
+	assert.Equal(t, "2", moved.Get("fid[0]"))
+	assert.Empty(t, moved.Get("fid[1]"))
+	assert.ErrorIs(t, c.Rename("1", "a"), ErrSameName)
+	assert.NoError(t, c.Rename("1", "c"))
+	assert.Equal(t, "c", renamed.Get("file_name"))
+}
+
+func TestSetFileTime(t *testing.T) {
+	var form url.Values
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/files/update", r.URL.Path)
+		_ = r.ParseForm()
+		form = r.PostForm
+		_, _ = w.Write([]byte(`{"state":true}`))
+	})
+	defer done()
+
+	assert.NoError(t, c.SetFileTime("1", time.Unix(1600000000, 0)))
+	assert.Equal(t, "1", form.Get("file_id"))
+	assert.Equal(t, "1600000000", form.Get("user_utime"))
+}
+
+func TestResolvePath(t *testing.T) {
+	var requests []string
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/files/get_info":
+			id := r.URL.Query().Get("file_id")
+			requests = append(requests, id)
+			switch id {
+			case "20":
+				_, _ = w.Write([]byte(`{"state":true,"data":[{"cid":"20","pid":"10","n":"b"}]}`))
+			case "10":
+				_, _ = w.Write([]byte(`{"state":true,"data":[{"cid":"10","pid":"0","n":"a"}]}`))
+			}
+		case "/files/batch_rename":
+			_, _ = w.Write([]byte(`{"state":true}`))
+		}
+	})
+	defer done()
+
+	p, err := c.ResolvePath(&File{FileID: "1", ParentID: "20", Name: "file.ext"})
+	assert.NoError(t, err)
+	assert.Equal(t, "/a/b/file.ext", p)
+	p, err = c.ResolvePath(&File{FileID: "2", ParentID: "20", Name: "other"})
+	assert.NoError(t, err)
+	assert.Equal(t, "/a/b/other", p)
+	assert.Equal(t, []string{"20", "10"}, requests)
+
+	assert.NoError(t, c.Rename("10", "c"))
+	_, err = c.ResolvePath(&File{FileID: "1", ParentID: "20", Name: "file.ext"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"20", "10", "10"}, requests)
+
+	p, err = c.ResolvePath(&File{FileID: "3", ParentID: "0", Name: "top"})
+	assert.NoError(t, err)
+	assert.Equal(t, "/top", p)
+}
+
+func TestStarFile(t *testing.T) {
+	var form url.Values
+	var query url.Values
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/files/star":
+			_ = r.ParseForm()
+			form = r.PostForm
+			_, _ = w.Write([]byte(`{"state":true}`))
+		case "/files":
+			query = r.URL.Query()
+			_, _ = w.Write([]byte(`{"state":true,"cid":"0","count":1,"data":[{"fid":"1","cid":"0","n":"a","m":1}]}`))
+		}
+	})
+	defer done()
+
+	assert.NoError(t, c.StarFile("1", true))
+	assert.Equal(t, "1", form.Get("file_id"))
+	assert.Equal(t, "1", form.Get("star"))
+	assert.NoError(t, c.StarFile("1", false))
+	assert.Equal(t, "0", form.Get("star"))
+
+	list, err := c.ListStarred(2)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", query.Get("star"))
+	assert.Equal(t, "56", query.Get("offset"))
+	assert.Len(t, list.Files, 1)
+	assert.True(t, (&File{}).from(&list.Files[0]).Star)
+}
+
+func TestLabels(t *testing.T) {
+	var forms []url.Values
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		switch r.URL.Path {
+		case "/label/list":
+			_, _ = w.Write([]byte(`{"state":true,"data":{"total":1,"list":[{"id":"7","name":"todo","color":"#ff4b30"}]}}`))
+		case "/label/add_multi":
+			forms = append(forms, r.PostForm)
+			_, _ = w.Write([]byte(`{"state":true,"data":[{"id":"8","name":"done","color":"#43BA80"}]}`))
+		default:
+			forms = append(forms, r.PostForm)
+			_, _ = w.Write([]byte(`{"state":true}`))
+		}
+	})
+	defer done()
+
+	labels, err := c.ListLabels()
+	assert.NoError(t, err)
+	assert.Equal(t, []Label{{ID: "7", Name: "todo", Color: 1}}, labels)
+	assert.Equal(t, "#FF4B30", labels[0].Color.Hex())
+
+	_, err = c.CreateLabel("done", "#123456")
+	assert.ErrorIs(t, err, ErrWrongParams)
+	label, err := c.CreateLabel("done", "#43ba80")
+	assert.NoError(t, err)
+	assert.Equal(t, &Label{ID: "8", Name: "done", Color: 4}, label)
+	assert.Equal(t, "done\x07#43BA80", forms[0].Get("name[]"))
+
+	assert.NoError(t, c.SetFileLabels("1", []string{"7", "8"}))
+	assert.Equal(t, "1", forms[1].Get("fid"))
+	assert.Equal(t, "7,8", forms[1].Get("file_label"))
+	assert.NoError(t, c.DeleteLabel("8"))
+	assert.Equal(t, "8", forms[2].Get("id"))
+}
+
+func TestGetVideoM3U8(t *testing.T) {
+	ready := false
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/files/video":
+			if !ready {
+				_, _ = w.Write([]byte(`{"state":true,"file_status":0}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"state":true,"file_status":1,"play_long":"90.5","width":"1920","height":1080,"video_url":"u"}`))
+		case "/api/video/m3u8/pc.m3u8":
+			if !ready {
+				return
+			}
+			_, _ = w.Write([]byte("#EXTM3U\n" +
+				"#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=1280x720,CODECS=\"avc1.4d401f,mp4a.40.2\",NAME=\"HD\"\n" +
+				"hd.m3u8\n" +
+				"#EXT-X-STREAM-INF:NAME=\"UD\",RESOLUTION=1920x1080,BANDWIDTH=2000000\n" +
+				"https://cdn.115.com/ud.m3u8\n"))
+		}
+	})
+	defer done()
+
+	status, err := c.GetTranscodeStatus("pc")
+	assert.NoError(t, err)
+	assert.False(t, status.Ready)
+	_, err = c.GetVideoM3U8("pc")
+	assert.ErrorIs(t, err, ErrVideoNotReady)
+
+	ready = true
+	status, err = c.GetTranscodeStatus("pc")
+	assert.NoError(t, err)
+	assert.Equal(t, &TranscodeStatus{Ready: true, FileStatus: 1, Duration: 90500 * time.Millisecond, Width: 1920, Height: 1080}, status)
+
+	playlist, err := c.GetVideoM3U8("pc")
+	assert.NoError(t, err)
+	assert.Equal(t, []Variant{
+		{Bandwidth: 800000, Width: 1280, Height: 720, Codecs: "avc1.4d401f,mp4a.40.2", Name: "HD", URL: "https://115.com/api/video/m3u8/hd.m3u8"},
+		{Bandwidth: 2000000, Width: 1920, Height: 1080, Name: "UD", URL: "https://cdn.115.com/ud.m3u8"},
+	}, playlist.Variants)
+}
+
+func TestParseMasterPlaylist(t *testing.T) {
+	variants, err := ParseMasterPlaylist("#EXTM3U\r\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=800000,\r\n" +
+		"  RESOLUTION=1280x720, CODECS=\"avc1.4d401f,\n" +
+		"mp4a.40.2\"\r\n" +
+		"hd.m3u8\r\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=2000000\\\n" +
+		",RESOLUTION=1920x1080\n" +
+		"https://cdn.115.com/ud.m3u8\n")
+	assert.NoError(t, err)
+	assert.Equal(t, []Variant{
+		{Bandwidth: 800000, Width: 1280, Height: 720, Codecs: "avc1.4d401f,mp4a.40.2", URL: "hd.m3u8"},
+		{Bandwidth: 2000000, Width: 1920, Height: 1080, URL: "https://cdn.115.com/ud.m3u8"},
+	}, variants)
+
+	_, err = ParseMasterPlaylist("")
+	assert.Error(t, err)
+}
+
+func TestGetPlayableStreams(t *testing.T) {
+	masters := 0
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		assert.NotEmpty(t, r.Header.Get("User-Agent"))
+		switch r.URL.Path {
+		case "/api/video/m3u8/pc.m3u8":
+			if masters++; masters == 1 {
+				return
+			}
+			_, _ = w.Write([]byte("#EXTM3U\n" +
+				"#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=1280x720,NAME=\"HD\"\n" +
+				"hd.m3u8\n" +
+				"#EXT-X-STREAM-INF:BANDWIDTH=2000000,RESOLUTION=1920x1080,NAME=\"UD\"\n" +
+				"ud.m3u8\n"))
+		case "/api/video/m3u8/hd.m3u8":
+			_, _ = w.Write([]byte("#EXTM3U\n#EXTINF:10.0,\n0.ts\n#EXTINF:2.5,\n1.ts\n#EXT-X-ENDLIST\n"))
+		case "/api/video/m3u8/ud.m3u8":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer done()
+
+	streams, err := c.GetPlayableStreams("pc")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, masters)
+	if assert.Len(t, streams, 1) {
+		assert.Equal(t, "HD", streams[0].Name)
+		assert.Equal(t, 2, streams[0].Segments)
+		assert.Equal(t, 12500*time.Millisecond, streams[0].Duration)
+		assert.NotEmpty(t, streams[0].Header.Get("User-Agent"))
+	}
+
+	_, err = c.GetPlayableStreams("")
+	assert.ErrorIs(t, err, ErrPickCodeIsEmpty)
+}
+
+func TestGetVideoSubtitles(t *testing.T) {
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "pc", r.URL.Query().Get("pickcode"))
+		_, _ = w.Write([]byte(`{"state":true,"data":{"list":[` +
+			`{"sid":"1","language":"chi","title":"a.zh.srt","url":"https://u/1","type":"SRT","file_id":"9","pick_code":"spc"},` +
+			`{"sid":"2","language":"eng","title":"English.ass","url":"https://u/2"}]}}`))
+	})
+	defer done()
+
+	subtitles, err := c.GetVideoSubtitles("pc")
+	assert.NoError(t, err)
+	assert.Equal(t, []Subtitle{
+		{Title: "a.zh.srt", Language: "chi", Format: "srt", URL: "https://u/1", FileID: "9", PickCode: "spc"},
+		{Title: "English.ass", Language: "eng", Format: "ass", URL: "https://u/2", Embedded: true},
+	}, subtitles)
+}
+
+func TestGetThumbnail(t *testing.T) {
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("pickcode") {
+		case "img":
+			_, _ = w.Write([]byte(`{"state":true,"data":{"url":"https://thumb.115.com/thumb/1/AB_100?s=1","origin_url":"https://o/AB"}}`))
+		default:
+			_, _ = w.Write([]byte(`{"state":true,"data":{"url":""}}`))
+		}
+	})
+	defer done()
+
+	thumb, err := c.GetThumbnail("img", ThumbSizeLarge)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://thumb.115.com/thumb/1/AB_800?s=1", thumb)
+	thumb, err = c.GetThumbnail("img", ThumbSizeOriginal)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://o/AB", thumb)
+	_, err = c.GetThumbnail("new", ThumbSizeSmall)
+	assert.ErrorIs(t, err, ErrNoThumbnail)
+
+	assert.Equal(t, "https://thumb.115.com/AB_480", ThumbnailURL("https://thumb.115.com/AB_100", ThumbSizeMedium))
+	assert.Equal(t, "https://thumb.115.com/AB_100", ThumbnailURL("https://thumb.115.com/AB_100", ThumbSizeOriginal))
+}
+
+func TestAPIError(t *testing.T) {
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("file_id") {
+		case "1":
+			_, _ = w.Write([]byte(`{"state":false,"errno":990001,"error":"\u8bf7\u91cd\u65b0\u767b\u5f55"}`))
+		case "2":
+			_, _ = w.Write([]byte(`{"state":false,"errno":12345,"error":"操作过于频繁"}`))
+		case "3":
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			_, _ = w.Write([]byte(`{"state":false,"errno":70005,"msg":"gone"}`))
+		}
+	})
+	defer done()
+
+	var apiErr *APIError
+	_, err := c.GetFile("1")
+	assert.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, 990001, apiErr.Code)
+	assert.Equal(t, "请重新登录", apiErr.Message)
+	assert.ErrorIs(t, err, ErrNotLogin)
+	assert.True(t, IsNotLoggedIn(err))
+	assert.False(t, IsNotFound(err))
+
+	_, err = c.GetFile("2")
+	assert.True(t, IsRateLimited(err))
+	_, err = c.GetFile("3")
+	assert.True(t, IsRateLimited(err))
+
+	_, err = c.GetFile("4")
+	assert.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "gone", apiErr.Message)
+	assert.True(t, IsNotFound(err))
+	assert.Contains(t, err.Error(), ErrDownloadFileNotExistOrHasDeleted.Error())
+}
+
+func TestVerifyRequired(t *testing.T) {
+	var submitted url.Values
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/files/get_info":
+			_, _ = w.Write([]byte(`{"state":false,"errno":911,"error":"请验证账号"}`))
+		case "/user/captcha":
+			_ = r.ParseForm()
+			submitted = r.PostForm
+			_, _ = w.Write([]byte(`{"state":true}`))
+		default:
+			_, _ = w.Write([]byte(`{"state":true,"sign":"s1"}`))
+		}
+	})
+	defer done()
+
+	_, err := c.GetFile("1")
+	var verifyErr *VerifyRequiredError
+	assert.ErrorAs(t, err, &verifyErr)
+	assert.Equal(t, ApiCaptchaPage, verifyErr.URL)
+	assert.ErrorIs(t, err, ErrVerifyRequired)
+	var apiErr *APIError
+	assert.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, 911, apiErr.Code)
+
+	assert.NoError(t, c.SubmitVerify("abcd"))
+	assert.Equal(t, "abcd", submitted.Get("code"))
+	assert.Equal(t, "s1", submitted.Get("sign"))
+}
+
+func TestGetFileInfo(t *testing.T) {
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("file_id") != "1" {
+			_, _ = w.Write([]byte(`{"state":true,"data":[]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"state":true,"data":[{"fid":"1","cid":"5","n":"a.mkv","s":"42","sha":"AB","pc":"pc1","tp":1700000000,"t":"2023-11-15 06:13"}]}`))
+	})
+	defer done()
+
+	f, err := c.GetFileInfo("1")
+	assert.NoError(t, err)
+	assert.Equal(t, "5", f.ParentID)
+	assert.Equal(t, "a.mkv", f.Name)
+	assert.Equal(t, int64(42), f.Size)
+	assert.Equal(t, "AB", f.Sha1)
+	assert.Equal(t, "pc1", f.PickCode)
+	assert.Equal(t, int64(1700000000), f.CreateTime.Unix())
+	assert.False(t, f.UpdateTime.IsZero())
+
+	_, err = c.GetFileInfo("2")
+	assert.ErrorIs(t, err, ErrNotExist)
+}
+
+func TestGetFilesByIDs(t *testing.T) {
+	var queries []string
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		ids := r.URL.Query().Get("file_id")
+		queries = append(queries, ids)
+		var data []string
+		for _, id := range strings.Split(ids, ",") {
+			if id != "404" {
+				data = append(data, fmt.Sprintf(`{"fid":"%s","cid":"5","n":"%s.mkv","pc":"pc%s"}`, id, id, id))
+			}
+		}
+		_, _ = fmt.Fprintf(w, `{"state":true,"data":[%s]}`, strings.Join(data, ","))
+	})
+	defer done()
+
+	ids := make([]string, MaxPickCodeBatchSize+1)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i + 1)
+	}
+	ids[1] = "404"
+	files, err := c.getFilesByIDs(context.Background(), ids)
+	assert.NoError(t, err)
+	assert.Len(t, queries, 2)
+	assert.Len(t, files, MaxPickCodeBatchSize)
+	assert.Equal(t, "1.mkv", files["1"].Name)
+	assert.Nil(t, files["404"])
+	assert.Equal(t, "pc101", files["101"].PickCode)
+}
+
+func TestGetDirInfo(t *testing.T) {
+	calls := 0
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Query().Get("cid") == "2" {
+			_, _ = w.Write([]byte(`{"file_name":"a.mkv","file_category":"1","size":"10B"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"file_name":"movies","file_category":"0","count":"12","folder_count":3,"size":"1.5GB"}`))
+	})
+	defer done()
+
+	detail, err := c.GetDirInfo("1")
+	assert.NoError(t, err)
+	assert.Equal(t, &DirDetail{Name: "movies", Size: 3 * GB / 2, FileCount: 12, DirCount: 3}, detail)
+	_, err = c.GetDirInfo("1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+
+	_, err = c.GetDirInfo("2")
+	assert.ErrorIs(t, err, ErrWrongParams)
+
+	for s, n := range map[string]int64{"": 0, "1024": 1024, "10 KB": 10 * KB, "2tb": 2 * TB} {
+		size, err := parseSize(s)
+		assert.NoError(t, err)
+		assert.Equal(t, n, size)
+	}
+}
+
+func TestListAll(t *testing.T) {
+	const total = 2500
+	var limits []string
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		limits = append(limits, q.Get("limit"))
+		offset, _ := strconv.Atoi(q.Get("offset"))
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		var data []string
+		for i := offset; i < offset+limit && i < total; i++ {
+			data = append(data, fmt.Sprintf(`{"fid":"%d","cid":"5","n":"f%d"}`, i, i))
+		}
+		_, _ = fmt.Fprintf(w, `{"state":true,"cid":"5","count":%d,"offset":%d,"data":[%s]}`, total, offset, strings.Join(data, ","))
+	})
+	defer done()
+
+	files, err := c.ListAll("5", 0)
+	assert.NoError(t, err)
+	assert.Len(t, files, total)
+	assert.Equal(t, "2499", files[total-1].FileID)
+	assert.Equal(t, []string{"1150", "1150", "1150"}, limits)
+
+	limits = nil
+	files, err = c.ListAll("5", 1200)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1200)
+	assert.Equal(t, "1199", files[1199].FileID)
+	assert.Equal(t, []string{"1150", "50"}, limits)
+
+	_, err = c.ListAll("5", -1)
+	assert.ErrorIs(t, err, ErrWrongParams)
+}
+
+func TestListWithOptions(t *testing.T) {
+	var queries []url.Values
+	var paths []string
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		queries = append(queries, r.URL.Query())
+		_, _ = w.Write([]byte(`{"state":true,"cid":"5","count":30,"data":[{"fid":"1","cid":"5","n":"a"}]}`))
+	})
+	defer done()
+
+	files, err := c.ListWithOptions("5", ListWithOrder(FileOrderBySize, false), ListWithShowDir(false), ListWithPage(20, 10))
+	assert.NoError(t, err)
+	assert.Len(t, *files, 1)
+	assert.Equal(t, "/files", paths[0])
+	assert.Equal(t, FileOrderBySize, queries[0].Get("o"))
+	assert.Equal(t, "0", queries[0].Get("asc"))
+	assert.Equal(t, "0", queries[0].Get("show_dir"))
+	assert.Equal(t, "20", queries[0].Get("offset"))
+	assert.Equal(t, "10", queries[0].Get("limit"))
+
+	assert.Empty(t, queries[0].Get("type"))
+
+	page, err := c.ListFilePage("5", ListWithPage(20, 10))
+	assert.NoError(t, err)
+	assert.Equal(t, 30, page.Count)
+	assert.Equal(t, int64(20), page.Offset)
+	assert.Equal(t, int64(10), page.PageSize)
+	assert.Equal(t, 3, page.Pages())
+	assert.True(t, page.HasNextPage())
+	paths, queries = paths[1:], queries[1:]
+
+	_, err = c.ListWithOptions("5", ListWithNaturalSort(true), ListWithFileType(FileTypeVideo))
+	assert.NoError(t, err)
+	assert.Equal(t, "/natsort/files.php", paths[1])
+	assert.Equal(t, FileOrderByName, queries[1].Get("o"))
+	assert.Equal(t, "1", queries[1].Get("natsort"))
+	assert.Equal(t, "56", queries[1].Get("limit"))
+	assert.Equal(t, "4", queries[1].Get("type"))
+
+	_, err = c.ListWithOptions("5", ListWithOrder(FileOrderByNone, true))
+	assert.NoError(t, err)
+	assert.Equal(t, "/files", paths[2])
+	assert.False(t, queries[2].Has("o"))
+	assert.False(t, queries[2].Has("asc"))
+	assert.Equal(t, "0", queries[2].Get("natsort"))
+}
+
+func TestDeletePermanent(t *testing.T) {
+	var cleaned url.Values
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		switch r.URL.Path {
+		case "/rb/delete":
+			if r.PostForm.Get("fid[1]") != "" {
+				_, _ = w.Write([]byte(`{"state":true}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"state":false,"errno":990002}`))
+		case "/rb/clean":
+			cleaned = r.PostForm
+			_, _ = w.Write([]byte(`{"state":true}`))
+		}
+	})
+	defer done()
+
+	assert.NoError(t, c.DeletePermanent("5", "1", "2"))
+	assert.Equal(t, "1", cleaned.Get("rid[0]"))
+	assert.Equal(t, "2", cleaned.Get("rid[1]"))
+
+	cleaned = nil
+	err := c.DeletePermanent("5", "3")
+	var batchErr *BatchError
+	assert.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, []string{"3"}, batchErr.Failed)
+	assert.Nil(t, cleaned)
+}
+
+func TestEmptyDir(t *testing.T) {
+	var deleted, cleaned url.Values
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		switch r.URL.Path {
+		case "/files":
+			_, _ = w.Write([]byte(`{"state":true,"cid":"5","count":2,"data":[{"fid":"1","cid":"5","n":"a"},{"cid":"6","pid":"5","n":"sub"}]}`))
+		case "/rb/delete":
+			deleted = r.PostForm
+			_, _ = w.Write([]byte(`{"state":true}`))
+		case "/rb/clean":
+			cleaned = r.PostForm
+			_, _ = w.Write([]byte(`{"state":true}`))
+		}
+	})
+	defer done()
+
+	n, err := c.EmptyDir("5", false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "5", deleted.Get("pid"))
+	assert.Equal(t, "1", deleted.Get("fid[0]"))
+	assert.Equal(t, "6", deleted.Get("fid[1]"))
+	assert.Nil(t, cleaned)
+
+	n, err = c.EmptyDir("5", true)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "6", cleaned.Get("rid[1]"))
+}
+
+func TestUploadDir(t *testing.T) {
+	local := t.TempDir()
+	assert.NoError(t, os.MkdirAll(local+"/sub", 0o755))
+	assert.NoError(t, os.MkdirAll(local+"/.git", 0o755))
+	assert.NoError(t, os.WriteFile(local+"/a.txt", []byte("abc"), 0o644))
+	assert.NoError(t, os.WriteFile(local+"/sub/b.txt", []byte("b"), 0o644))
+	assert.NoError(t, os.WriteFile(local+"/sub/skip.log", []byte("log"), 0o644))
+	assert.NoError(t, os.WriteFile(local+"/.git/config", []byte("x"), 0o644))
+
+	var mu sync.Mutex
+	var created []string
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = r.ParseForm()
+		switch r.URL.Path {
+		case "/files/getid":
+			_, _ = w.Write([]byte(`{"state":true,"id":0}`))
+		case "/files/add":
+			created = append(created, r.PostForm.Get("pid")+"/"+r.PostForm.Get("cname"))
+			_, _ = w.Write([]byte(`{"state":true,"cid":"20"}`))
+		case "/files":
+			if cid := r.URL.Query().Get("cid"); cid == "0" {
+				_, _ = fmt.Fprintf(w, `{"state":true,"cid":"0","count":1,"data":[{"fid":"1","cid":"0","n":"a.txt","s":3,"sha":"%X"}]}`, sha1.Sum([]byte("abc")))
+			} else {
+				_, _ = fmt.Fprintf(w, `{"state":true,"cid":"20","count":1,"data":[{"fid":"2","cid":"20","n":"b.txt","s":1,"sha":"%X"}]}`, sha1.Sum([]byte("b")))
+			}
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	})
+	defer done()
+
+	assert.NoError(t, c.UploadDir(local, "0", UploadDirWithIgnore(".git", "*.log")))
+	assert.Equal(t, []string{"0/sub"}, created)
+
+	err := c.UploadDir(local, "0", UploadDirWithIgnore("["))
+	assert.ErrorIs(t, err, ErrWrongParams)
+}
+
+func TestSyncPlan(t *testing.T) {
+	local := t.TempDir()
+	assert.NoError(t, os.MkdirAll(local+"/d", 0o755))
+	assert.NoError(t, os.WriteFile(local+"/same.txt", []byte("abc"), 0o644))
+	assert.NoError(t, os.WriteFile(local+"/changed.txt", []byte("abd"), 0o644))
+	assert.NoError(t, os.WriteFile(local+"/local.txt", []byte("l"), 0o644))
+
+	var deleted url.Values
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		switch r.URL.Path {
+		case "/natsort/files.php":
+			if r.URL.Query().Get("cid") == "5" {
+				_, _ = fmt.Fprintf(w, `{"state":true,"cid":"5","count":3,"data":[`+
+					`{"fid":"1","cid":"5","n":"same.txt","s":3,"sha":"%X"},`+
+					`{"fid":"2","cid":"5","n":"changed.txt","s":3,"sha":"%X"},`+
+					`{"cid":"6","pid":"5","n":"d"}]}`, sha1.Sum([]byte("abc")), sha1.Sum([]byte("xyz")))
+			} else {
+				_, _ = w.Write([]byte(`{"state":true,"cid":"6","count":1,"data":[{"fid":"9","cid":"6","n":"remote.txt","s":1,"sha":"AB"}]}`))
+			}
+		case "/rb/delete":
+			deleted = r.PostForm
+			_, _ = w.Write([]byte(`{"state":true}`))
+		}
+	})
+	defer done()
+
+	plan, err := c.DiffDir(local, "5")
+	assert.NoError(t, err)
+	paths := func(entries []SyncEntry) []string {
+		var ps []string
+		for _, e := range entries {
+			ps = append(ps, e.Path)
+		}
+		return ps
+	}
+	assert.Equal(t, []string{"changed.txt", "local.txt"}, paths(plan.Upload))
+	assert.Equal(t, []string{"changed.txt", "d/remote.txt"}, paths(plan.Download))
+	assert.Equal(t, []string{"d/remote.txt"}, paths(plan.DeleteRemote))
+	assert.Equal(t, []string{"local.txt"}, paths(plan.DeleteLocal))
+	assert.Equal(t, "2", plan.Download[0].Remote.FileID)
+
+	plan.Upload, plan.Download = nil, nil
+	assert.NoError(t, c.ApplySyncPlan(plan, SyncToRemote))
+	assert.Equal(t, "6", deleted.Get("pid"))
+	assert.Equal(t, "9", deleted.Get("fid[0]"))
+	assert.NoError(t, c.ApplySyncPlan(plan, SyncToLocal))
+	_, err = os.Stat(local + "/local.txt")
+	assert.True(t, os.IsNotExist(err))
+	assert.ErrorIs(t, c.ApplySyncPlan(plan, SyncDirection(9)), ErrWrongParams)
+}
+
+func TestSubClient(t *testing.T) {
+	var mu sync.Mutex
+	var listed []string
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = r.ParseForm()
+		switch r.URL.Path {
+		case "/files":
+			cid := r.URL.Query().Get("cid")
+			listed = append(listed, cid)
+			switch cid {
+			case "10":
+				_, _ = w.Write([]byte(`{"state":true,"cid":"10","count":2,"data":[{"cid":"11","pid":"10","n":"a"},{"fid":"1","cid":"10","n":"f.txt","s":3}]}`))
+			case "11":
+				_, _ = w.Write([]byte(`{"state":true,"cid":"11","count":1,"data":[{"fid":"2","cid":"11","n":"b.txt","s":1}]}`))
+			default:
+				_, _ = fmt.Fprintf(w, `{"state":true,"cid":"%s","count":0,"data":[]}`, cid)
+			}
+		case "/files/add":
+			assert.Equal(t, "11", r.PostForm.Get("pid"))
+			assert.Equal(t, "new", r.PostForm.Get("cname"))
+			_, _ = w.Write([]byte(`{"state":true,"cid":"12"}`))
+		}
+	})
+	defer done()
+
+	s := c.Sub("10")
+	f, err := s.Stat("a/b.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", f.FileID)
+	f, err = s.Stat("../../f.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", f.FileID)
+	assert.Equal(t, []string{"10", "11"}, listed)
+
+	files, err := s.List("/a")
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	_, err = s.List("f.txt")
+	assert.ErrorIs(t, err, ErrNotExist)
+	_, err = s.Stat("a/missing")
+	assert.ErrorIs(t, err, ErrNotExist)
+
+	cid, err := s.MkdirAll("a/new")
+	assert.NoError(t, err)
+	assert.Equal(t, "12", cid)
+	cid, err = s.MkdirAll("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "11", cid)
+	_, err = s.MkdirAll("f.txt")
+	assert.ErrorIs(t, err, ErrExist)
+	_, _, err = s.Open("a")
+	assert.ErrorIs(t, err, ErrDownloadDirectory)
+
+	_, err = c.Sub("0").Stat("a")
+	assert.ErrorIs(t, err, ErrWrongParams)
+}
+
+func TestDownloadDir(t *testing.T) {
+	data := []byte("hello")
+	sum := fmt.Sprintf("%X", sha1.Sum(data))
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("cid") {
+		case "1":
+			_, _ = fmt.Fprintf(w, `{"state":true,"cid":"1","count":2,"data":[`+
+				`{"cid":"2","pid":"1","n":"sub"},{"fid":"fa","cid":"1","n":"a.txt","s":5,"sha":"%s","pc":"pa"}]}`, sum)
+		case "2":
+			_, _ = w.Write([]byte(`{"state":true,"cid":"2","count":1,"data":[{"fid":"fb","cid":"2","n":"b.txt","s":5,"pc":"pb"}]}`))
+		default:
+			_, _ = w.Write([]byte(`{"state":false,"errno":50003}`))
+		}
+	})
+	defer done()
+
+	dest := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dest, "a.txt"), data, 0o644))
+	err := c.DownloadDir("1", dest, BatchDownloadWithConcurrency(2))
+	var batchErr *BatchError
+	assert.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, []string{"fb"}, batchErr.Failed)
+	assert.Equal(t, 2, batchErr.Total)
+	fi, err := os.Stat(filepath.Join(dest, "sub"))
+	assert.NoError(t, err)
+	assert.True(t, fi.IsDir())
+}
+
+func TestSetUserAgent(t *testing.T) {
+	var agents []string
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		agents = append(agents, r.UserAgent())
+		_, _ = w.Write([]byte(`{"state":true,"data":[{"fid":"1"}]}`))
+	})
+	defer done()
+
+	c.SetUserAgent("ua/1")
+	assert.Equal(t, "ua/1", c.UserAgent())
+	_, err := c.GetFile("1")
+	assert.NoError(t, err)
+
+	transport := c.Client.GetClient().Transport
+	c.SetHttpClient(&http.Client{Transport: transport})
+	_, err = c.GetFile("1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ua/1", "ua/1"}, agents)
+}
+
+func TestRangeReader(t *testing.T) {
+	data := []byte("0123456789")
+	var ranges []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "f", time.Time{}, bytes.NewReader(data))
+	}))
+	defer s.Close()
+
+	r := New().newRangeReader(context.Background(), "pc", int64(len(data)))
+	r.info = &DownloadInfo{FileSize: StringInt64(len(data)), Url: FileDownloadUrl{Url: s.URL}, Header: http.Header{}}
+	buf := make([]byte, 3)
+	_, err := io.ReadFull(r, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "012", string(buf))
+	_, err = r.Seek(-2, io.SeekEnd)
+	assert.NoError(t, err)
+	rest, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "89", string(rest))
+	assert.NoError(t, r.Close())
+	// the seek is in RangeSeekWindow so the connection is kept
+	assert.Equal(t, []string{""}, ranges)
+}
+
+func TestOpenSeeker(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), RangeSeekWindow/5)
+	var ranges []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "f", time.Time{}, bytes.NewReader(data))
+	}))
+	defer s.Close()
+
+	r := New().newRangeReader(context.Background(), "pc", int64(len(data)))
+	r.info = &DownloadInfo{FileSize: StringInt64(len(data)), Url: FileDownloadUrl{Url: s.URL}, Header: http.Header{}}
+	buf := make([]byte, 2)
+	read := func(offset int64) string {
+		_, err := r.Seek(offset, io.SeekStart)
+		assert.NoError(t, err)
+		_, err = io.ReadFull(r, buf)
+		assert.NoError(t, err)
+		return string(buf)
+	}
+	assert.Equal(t, "01", read(0))
+	assert.Equal(t, "56", read(5))
+	assert.Equal(t, string(data[RangeSeekWindow+7:RangeSeekWindow+9]), read(RangeSeekWindow+7))
+	assert.Equal(t, []string{""}, ranges)
+
+	assert.Equal(t, "12", read(1))
+	assert.Equal(t, "89", read(int64(len(data))-2))
+	assert.Equal(t, []string{"", "bytes=1-", fmt.Sprintf("bytes=%d-", len(data)-2)}, ranges)
+	assert.NoError(t, r.Close())
+
+	_, _, err := New().OpenSeeker("")
+	assert.ErrorIs(t, err, ErrPickCodeIsEmpty)
+}
+
+func TestWebDAVFS(t *testing.T) {
+	var mu sync.Mutex
+	forms := map[string]url.Values{}
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = r.ParseForm()
+		switch r.URL.Path {
+		case "/files":
+			switch r.URL.Query().Get("cid") {
+			case "0":
+				_, _ = w.Write([]byte(`{"state":true,"cid":"0","count":2,"data":[` +
+					`{"cid":"10","pid":"0","n":"docs"},{"fid":"1","cid":"0","n":"a.txt","s":3,"sha":"AB"}]}`))
+			case "10":
+				_, _ = w.Write([]byte(`{"state":true,"cid":"10","count":1,"data":[{"fid":"2","cid":"10","n":"b.txt","s":5}]}`))
+			}
+		default:
+			forms[r.URL.Path] = r.PostForm
+			_, _ = w.Write([]byte(`{"state":true,"cid":"11"}`))
+		}
+	})
+	defer done()
+
+	ctx := context.Background()
+	davFS := NewWebDAVFS(c)
+	fi, err := davFS.Stat(ctx, "/docs")
+	assert.NoError(t, err)
+	assert.True(t, fi.IsDir())
+	assert.Equal(t, "docs", fi.Name())
+	fi, err = davFS.Stat(ctx, "/a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), fi.Size())
+	etag, err := fi.(webdav.ETager).ETag(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, `"AB"`, etag)
+	_, err = davFS.Stat(ctx, "/nope")
+	assert.True(t, os.IsNotExist(err))
+
+	dir, err := davFS.OpenFile(ctx, "/docs", os.O_RDONLY, 0)
+	assert.NoError(t, err)
+	children, err := dir.Readdir(0)
+	assert.NoError(t, err)
+	assert.Len(t, children, 1)
+	assert.Equal(t, "b.txt", children[0].Name())
+	assert.NoError(t, dir.Close())
+
+	assert.NoError(t, davFS.Mkdir(ctx, "/docs/new", 0o755))
+	assert.Equal(t, "10", forms["/files/add"].Get("pid"))
+	assert.Equal(t, "new", forms["/files/add"].Get("cname"))
+	err = davFS.Mkdir(ctx, "/a.txt/x", 0o755)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, davFS.Rename(ctx, "/a.txt", "/docs/c.txt"))
+	assert.Equal(t, "10", forms["/files/move"].Get("pid"))
+	assert.Equal(t, "1", forms["/files/move"].Get("fid[0]"))
+	assert.Equal(t, "c.txt", forms["/files/batch_rename"].Get("files_new_name[1]"))
+
+	assert.NoError(t, davFS.RemoveAll(ctx, "/docs/b.txt"))
+	assert.Equal(t, "2", forms["/rb/delete"].Get("fid[0]"))
+}
+
+func TestFS(t *testing.T) {
+	lists := 0
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		lists++
+		switch r.URL.Query().Get("cid") {
+		case "5":
+			_, _ = w.Write([]byte(`{"state":true,"cid":"5","count":2,"data":[` +
+				`{"fid":"1","cid":"5","n":"z.txt","s":3},{"cid":"10","pid":"5","n":"docs"}]}`))
+		case "10":
+			_, _ = w.Write([]byte(`{"state":true,"cid":"10","count":1,"data":[{"fid":"2","cid":"10","n":"b.txt","s":5}]}`))
+		}
+	})
+	defer done()
+
+	fsys := c.FS("5")
+	var walked []string
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		walked = append(walked, p)
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{".", "docs", "docs/b.txt", "z.txt"}, walked)
+
+	fi, err := fs.Stat(fsys, "docs/b.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), fi.Size())
+	assert.Equal(t, "b.txt", fi.Name())
+	assert.Equal(t, 2, lists)
+
+	_, err = fs.Stat(fsys, "docs/nope")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = fsys.Open("../x")
+	assert.ErrorIs(t, err, fs.ErrInvalid)
+	_, err = fs.ReadDir(fsys, "z.txt")
+	assert.Error(t, err)
+	_, ok := fsys.(fs.ReadDirFS)
+	assert.True(t, ok)
+}
+
+func TestPreID(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 20*KB)
+	var ranges []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "f", time.Time{}, bytes.NewReader(data))
+	}))
+	defer s.Close()
+
+	c := New()
+	info := &DownloadInfo{FileSize: StringInt64(len(data)), Url: FileDownloadUrl{Url: s.URL}, Header: http.Header{}}
+	_, preID, err := ComputeUploadDigest(bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+	got, err := c.preID(context.Background(), info)
+	assert.NoError(t, err)
+	assert.Equal(t, preID, got)
+	assert.Equal(t, []string{"bytes=0-131071"}, ranges)
+
+	info.FileSize = 0
+	got, err = c.preID(context.Background(), info)
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("%X", sha1.Sum(nil)), got)
+}
+
+func TestConcurrentList(t *testing.T) {
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		cid := r.URL.Query().Get("cid")
+		_, _ = fmt.Fprintf(w, `{"state":true,"cid":%q,"count":1,"data":[{"fid":"f%s","cid":%q,"n":"n%s"}]}`, cid, cid, cid, cid)
+	})
+	defer done()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(cid string) {
+			defer wg.Done()
+			files, err := c.List(cid)
+			if err == nil && (len(*files) != 1 || (*files)[0].FileID != "f"+cid) {
+				err = fmt.Errorf("dir %s got %v", cid, *files)
+			}
+			errs <- err
+		}(strconv.Itoa(i + 1))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
+
+func TestComputePartSize(t *testing.T) {
+	assert.Equal(t, int64(MinPartSize), ComputePartSize(KB))
+	assert.Equal(t, int64(GB/1000+1), ComputePartSize(GB-1))
+	assert.Equal(t, int64(2*GB/3000+1), ComputePartSize(2*GB))
+	assert.Equal(t, int64(100*GB/MaxPartCount+1), ComputePartSize(100*GB))
+	for _, size := range []int64{1, MB, 9 * GB, 10 * GB, 500 * GB} {
+		assert.NoError(t, checkPartSize(ComputePartSize(size), size), size)
+	}
+	assert.ErrorIs(t, checkPartSize(KB, MB), ErrWrongParams)
+	assert.ErrorIs(t, checkPartSize(6*GB, 10*GB), ErrWrongParams)
+	assert.ErrorIs(t, checkPartSize(MinPartSize, 10*GB), ErrWrongParams)
+}
+
+func TestSplitEmptyFile(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "empty")
+	assert.NoError(t, err)
+	defer f.Close()
+	_, err = SplitFile(f.Name(), 0)
+	assert.ErrorIs(t, err, ErrWrongParams)
+
+	d, err := New().GetDigestResult(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "DA39A3EE5E6B4B0D3255BFEF95601890AFD80709", d.QuickID)
+	assert.Equal(t, d.QuickID, d.PreID)
+}
+
+func TestUploadMethod(t *testing.T) {
+	assert.Equal(t, "rapid", UploadMethodRapid.String())
+	assert.Equal(t, "oss", UploadMethodOSS.String())
+	assert.Equal(t, "multipart", UploadMethodMultipart.String())
+	assert.Equal(t, "unknown", UploadMethod(0).String())
+}
+
+func TestUploadByMultipartConcurrency(t *testing.T) {
+	var (
+		mu                  sync.Mutex
+		inFlight, maxFlight int
+		sequential          bool
+		completed           string
+	)
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case r.URL.Path == "/3.0/gettoken.php":
+			_, _ = fmt.Fprintf(w, `{"StatusCode":"200","AccessKeyID":"k","AccessKeySecret":"s","SecurityToken":"t","Expiration":%q}`,
+				time.Now().Add(time.Hour).Format(time.RFC3339))
+		case q.Has("uploads"):
+			sequential = q.Has("sequential")
+			_, _ = w.Write([]byte(`<InitiateMultipartUploadResult><Bucket>fhnfile</Bucket><Key>obj</Key><UploadId>u1</UploadId></InitiateMultipartUploadResult>`))
+		case q.Has("partNumber"):
+			mu.Lock()
+			inFlight++
+			if inFlight > maxFlight {
+				maxFlight = inFlight
+			}
+			mu.Unlock()
+			time.Sleep(50 * time.Millisecond)
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			_, _ = io.Copy(io.Discard, r.Body)
+			w.Header().Set("ETag", `"e`+q.Get("partNumber")+`"`)
+		case q.Has("uploadId"):
+			body, _ := io.ReadAll(r.Body)
+			completed = string(body)
+			_, _ = w.Write([]byte(`{"state":true,"data":{"file_id":"1"}}`))
+		}
+	})
+	defer done()
+
+	f, err := os.CreateTemp(t.TempDir(), "parts")
+	assert.NoError(t, err)
+	defer f.Close()
+	_, err = f.Write(make([]byte, 3*MinPartSize+1))
+	assert.NoError(t, err)
+
+	params := &UploadOSSParams{Bucket: "fhnfile", Object: "obj"}
+	assert.NoError(t, c.UploadByMultipart(params, 3*MinPartSize+1, f, "0",
+		UploadMultipartWithPartSize(MinPartSize), UploadMultipartWithPartConcurrency(3)))
+	assert.Equal(t, 3, maxFlight)
+	assert.False(t, sequential)
+	assert.Regexp(t, `(?s)<PartNumber>1</PartNumber>.*<PartNumber>2</PartNumber>.*<PartNumber>3</PartNumber>.*<PartNumber>4</PartNumber>`, completed)
+
+	maxFlight = 0
+	assert.NoError(t, c.UploadByMultipart(params, 3*MinPartSize+1, f, "0", UploadMultipartWithPartSize(MinPartSize)))
+	assert.Equal(t, 1, maxFlight)
+	assert.True(t, sequential)
+}
+
+// plainCipher stands in for the ECDH cipher of upload init, so that the fake
+// server reads the form and writes the JSON in plain text.
+type plainCipher struct{}
+
+func (plainCipher) EncodeToken(int64) (string, error) { return "token", nil }
+func (plainCipher) Encrypt(b []byte) ([]byte, error)  { return b, nil }
+func (plainCipher) Decrypt(b []byte) ([]byte, error)  { return b, nil }
+
+func usePlainUploadCipher(t *testing.T) {
+	orig := newUploadCipher
+	newUploadCipher = func() (uploadCipher, error) { return plainCipher{}, nil }
+	t.Cleanup(func() { newUploadCipher = orig })
+}
+
+// uploadMock serves the upload flow, onInit answers each upload init.
+type uploadMock struct {
+	mu     sync.Mutex
+	onInit func(form url.Values) string
+	inits  []url.Values
+	parts  []int
+	files  string
+}
+
+func (m *uploadMock) handle(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q := r.URL.Query()
+	switch {
+	case r.URL.Path == "/app/uploadinfo":
+		_, _ = w.Write([]byte(`{"state":true,"user_id":1,"userkey":"k","size_limit":1099511627776}`))
+	case r.URL.Path == "/4.0/initupload.php":
+		body, _ := io.ReadAll(r.Body)
+		form, _ := url.ParseQuery(string(body))
+		m.inits = append(m.inits, form)
+		_, _ = w.Write([]byte(m.onInit(form)))
+	case r.URL.Path == "/3.0/gettoken.php":
+		_, _ = fmt.Fprintf(w, `{"StatusCode":"200","AccessKeyID":"k","AccessKeySecret":"s","SecurityToken":"t","Expiration":%q}`,
+			time.Now().Add(time.Hour).Format(time.RFC3339))
+	case r.URL.Path == "/files":
+		_, _ = w.Write([]byte(m.files))
+	case q.Has("uploads"):
+		_, _ = w.Write([]byte(`<InitiateMultipartUploadResult><Bucket>fhnfile</Bucket><Key>obj</Key><UploadId>u1</UploadId></InitiateMultipartUploadResult>`))
+	case q.Has("partNumber"):
+		n, _ := io.Copy(io.Discard, r.Body)
+		m.parts = append(m.parts, int(n))
+		w.Header().Set("ETag", `"e`+q.Get("partNumber")+`"`)
+	case q.Has("uploadId"):
+		_, _ = w.Write([]byte(`{"state":true,"data":{"file_id":"99","file_name":"a.bin","cid":"5"}}`))
+	default:
+		panic("unexpected request " + r.URL.String())
+	}
+}
+
+func TestUploadStream(t *testing.T) {
+	usePlainUploadCipher(t)
+	m := &uploadMock{onInit: func(url.Values) string {
+		return `{"status":1,"bucket":"fhnfile","object":"obj"}`
+	}}
+	c, done := newMockClient(m.handle)
+	defer done()
+
+	// a reader of unknown size which can not seek is spooled
+	content := bytes.Repeat([]byte("a"), 2*StreamPartSize+3)
+	f, err := c.UploadStream(io.MultiReader(bytes.NewReader(content)), -1, "5", "a.bin")
+	assert.NoError(t, err)
+	assert.Equal(t, "99", f.FileID)
+	assert.Equal(t, []int{StreamPartSize, StreamPartSize, 3}, m.parts)
+	assert.Equal(t, strconv.Itoa(len(content)), m.inits[0].Get("filesize"))
+	assert.Equal(t, fmt.Sprintf("%X", sha1.Sum(content)), m.inits[0].Get("fileid"))
+
+	// an exact multiple of the part size sends no empty part
+	m.parts = nil
+	_, err = c.UploadStream(bytes.NewReader(content[:2*StreamPartSize]), 2*StreamPartSize, "5", "b.bin")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{StreamPartSize, StreamPartSize}, m.parts)
+
+	m.parts = nil
+	_, err = c.UploadStream(io.MultiReader(bytes.NewReader(content[:StreamPartSize])), -1, "5", "c.bin")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{StreamPartSize}, m.parts)
+
+	_, err = c.UploadStream(io.MultiReader(strings.NewReader("abc")), 4, "5", "d.txt")
+	assert.ErrorIs(t, err, ErrWrongParams)
+}
+
+func TestUploadByMultipartCancel(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		aborted bool
+		cancel  context.CancelFunc
+	)
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		q := r.URL.Query()
+		switch {
+		case r.URL.Path == "/3.0/gettoken.php":
+			_, _ = fmt.Fprintf(w, `{"StatusCode":"200","AccessKeyID":"k","AccessKeySecret":"s","SecurityToken":"t","Expiration":%q}`,
+				time.Now().Add(time.Hour).Format(time.RFC3339))
+		case q.Has("uploads"):
+			_, _ = w.Write([]byte(`<InitiateMultipartUploadResult><Bucket>fhnfile</Bucket><Key>obj</Key><UploadId>u1</UploadId></InitiateMultipartUploadResult>`))
+		case q.Has("partNumber"):
+			_, _ = io.Copy(io.Discard, r.Body)
+			cancel()
+			w.Header().Set("ETag", `"e`+q.Get("partNumber")+`"`)
+		case r.Method == http.MethodDelete && q.Has("uploadId"):
+			aborted = true
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	defer done()
+
+	f, err := os.CreateTemp(t.TempDir(), "parts")
+	assert.NoError(t, err)
+	defer f.Close()
+	_, err = f.Write(make([]byte, 2*MinPartSize))
+	assert.NoError(t, err)
+	params := &UploadOSSParams{Bucket: "fhnfile", Object: "obj"}
+
+	// the uploaded parts are kept to be resumed
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	cancel = stop
+	resume := &bytes.Buffer{}
+	err = c.UploadByMultipartContext(ctx, params, 2*MinPartSize, f, "0",
+		UploadMultipartWithPartSize(MinPartSize), UploadMultipartWithResume(resume))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, aborted)
+	state, err := LoadResumeState(resume)
+	assert.NoError(t, err)
+	assert.Equal(t, "u1", state.UploadID)
+
+	ctx, stop = context.WithCancel(context.Background())
+	defer stop()
+	cancel = stop
+	err = c.UploadByMultipartContext(ctx, params, 2*MinPartSize, f, "0", UploadMultipartWithPartSize(MinPartSize))
+	assert.ErrorIs(t, err, context.Canceled)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.True(t, aborted)
+}
+
+func TestTryRapidUpload(t *testing.T) {
+	usePlainUploadCipher(t)
+	content := []byte("0123456789")
+	sum := fmt.Sprintf("%X", sha1.Sum(content))
+	status := "2"
+	m := &uploadMock{onInit: func(form url.Values) string {
+		if status != "7" {
+			return `{"status":` + status + `,"pickcode":"pc"}`
+		}
+		if form.Get("sign_key") == "" {
+			return `{"status":7,"sign_key":"sk","sign_check":"2-5"}`
+		}
+		return `{"status":1}`
+	}}
+	m.files = fmt.Sprintf(`{"state":true,"cid":"5","count":1,"data":[{"fid":"1","cid":"5","n":"a.txt","s":10,"sha":"%s"}]}`, sum)
+	c, done := newMockClient(m.handle)
+	defer done()
+
+	hit, f, err := c.TryRapidUpload(10, sum, sum, "5", "a.txt", bytes.NewReader(content))
+	assert.NoError(t, err)
+	assert.True(t, hit)
+	assert.Equal(t, "1", f.FileID)
+
+	// the uploaded file is not found
+	m.files = `{"state":true,"cid":"5","count":0,"data":[]}`
+	hit, f, err = c.TryRapidUpload(10, sum, sum, "5", "a.txt", bytes.NewReader(content))
+	assert.ErrorIs(t, err, ErrUploadFailed)
+	assert.True(t, hit)
+	assert.Nil(t, f)
+
+	status = "1"
+	hit, f, err = c.TryRapidUpload(10, sum, sum, "5", "a.txt", bytes.NewReader(content))
+	assert.NoError(t, err)
+	assert.False(t, hit)
+	assert.Nil(t, f)
+
+	// the server checks a range of the content before answering
+	status = "7"
+	m.inits = nil
+	hit, _, err = c.TryRapidUpload(10, sum, sum, "5", "a.txt", bytes.NewReader(content))
+	assert.NoError(t, err)
+	assert.False(t, hit)
+	assert.Len(t, m.inits, 2)
+	assert.Equal(t, "sk", m.inits[1].Get("sign_key"))
+	assert.Equal(t, fmt.Sprintf("%X", sha1.Sum(content[2:6])), m.inits[1].Get("sign_val"))
+}
+
+func TestOSSSession(t *testing.T) {
+	var n int
+	expiration := time.Now().Add(time.Minute)
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		_, _ = fmt.Fprintf(w, `{"StatusCode":"200","AccessKeyID":"k%d","AccessKeySecret":"s","SecurityToken":"t%d","Expiration":%q}`,
+			n, n, expiration.Format(time.RFC3339))
+	})
+	defer done()
+
+	sess, err := c.newOSSSession(context.Background(), "fhnfile", time.Minute*5)
+	assert.NoError(t, err)
+	_, token, err := sess.get()
+	assert.NoError(t, err)
+	assert.Equal(t, "t2", token.SecurityToken)
+
+	expiration = time.Now().Add(time.Hour)
+	assert.NoError(t, sess.refresh())
+	bucket, token, err := sess.get()
+	assert.NoError(t, err)
+	assert.Equal(t, "t3", token.SecurityToken)
+	assert.Equal(t, "fhnfile", bucket.BucketName)
+
+	assert.True(t, isOSSTokenExpired(fmt.Errorf("part: %w", oss.ServiceError{Code: "SecurityTokenExpired"})))
+	assert.False(t, isOSSTokenExpired(oss.ServiceError{Code: "NoSuchUpload"}))
+	assert.False(t, isOSSTokenExpired(ErrUploadFailed))
+}
+
+func TestDryRun(t *testing.T) {
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s", r.URL.Path)
+	})
+	defer done()
+	c.SetDryRun(true)
+
+	assert.NoError(t, c.Delete("1", "2"))
+	assert.NoError(t, c.DeleteFiles("0", "3"))
+	assert.NoError(t, c.DeletePermanent("0", "4"))
+	assert.NoError(t, c.Move("9", "5"))
+	assert.NoError(t, c.Rename("6", "a.txt"))
+	assert.Error(t, c.RenameBatch(map[string]string{"7": "b.txt", "8": "c?"}))
+	assert.Equal(t, []PlannedOp{
+		{Op: PlannedOpDelete, FileIDs: []string{"1", "2"}},
+		{Op: PlannedOpDelete, DirID: "0", FileIDs: []string{"3"}},
+		{Op: PlannedOpDeletePermanent, DirID: "0", FileIDs: []string{"4"}},
+		{Op: PlannedOpMove, DirID: "9", FileIDs: []string{"5"}},
+		{Op: PlannedOpRename, FileIDs: []string{"6"}, NewName: "a.txt"},
+		{Op: PlannedOpRename, FileIDs: []string{"7"}, NewName: "b.txt"},
+	}, c.PlannedOps())
+	assert.Empty(t, c.PlannedOps())
+
+	c.SetDryRun(false)
+	assert.False(t, c.DryRun())
+	assert.Nil(t, c.PlannedOps())
+}
+
+func TestRequestLogger(t *testing.T) {
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rb/delete" {
+			_, _ = w.Write([]byte(`{"state":false,"errno":990002}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"state":true}`))
+	})
+	var logs []RequestLog
+	c.SetCookies(&http.Cookie{Name: CookieNameSeid, Value: "secret"})
+	c.SetRequestLogger(func(l RequestLog) { logs = append(logs, l) })
+
+	assert.NoError(t, c.Rename("1", "a"))
+	assert.Error(t, c.Delete("1"))
+	done()
+	assert.Error(t, c.Delete("1"))
+
+	assert.Len(t, logs, 3)
+	assert.Equal(t, http.MethodPost, logs[0].Method)
+	assert.Equal(t, ApiFileRename, logs[0].Endpoint)
+	assert.Equal(t, http.StatusOK, logs[0].StatusCode)
+	assert.Equal(t, 1, logs[0].Attempts)
+	assert.Zero(t, logs[0].Code)
+	assert.NoError(t, logs[0].Err)
+	assert.Equal(t, 990002, logs[1].Code)
+	assert.Zero(t, logs[2].StatusCode)
+	assert.Error(t, logs[2].Err)
+	assert.NotContains(t, fmt.Sprint(logs), "secret")
+}
+
+func TestSetTimeout(t *testing.T) {
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/files/batch_rename":
+			time.Sleep(100 * time.Millisecond)
+			_, _ = w.Write([]byte(`{"state":true}`))
+		default:
+			w.(http.Flusher).Flush()
+			time.Sleep(100 * time.Millisecond)
+			_, _ = w.Write([]byte("data"))
+		}
+	})
+	defer done()
+	c.SetTimeout(20 * time.Millisecond).SetTransferTimeout(time.Second)
+
+	assert.ErrorIs(t, c.Rename("1", "a"), context.DeadlineExceeded)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, c.RenameContext(ctx, "1", "a"))
+
+	body, _, err := c.downloadStream(context.Background(),
+		&DownloadInfo{Url: FileDownloadUrl{Url: "https://cdn.115.com/f"}, Header: http.Header{}}, DefaultDownloadOptions())
+	assert.NoError(t, err)
+	data, err := io.ReadAll(body)
+	assert.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+	assert.NoError(t, body.Close())
+}
+
+func TestMetrics(t *testing.T) {
+	data := []byte("hello metrics")
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rb/delete":
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"state":false,"errno":990002}`))
+		case "/f":
+			_, _ = w.Write(data)
+		default:
+			_, _ = w.Write([]byte(`{"state":true}`))
+		}
+	})
+	defer done()
+	WithMetrics()(c)
+
+	assert.NoError(t, c.Rename("1", "a"))
+	assert.NoError(t, c.Rename("2", "b"))
+	assert.Error(t, c.Delete("1"))
+	body, _, err := c.downloadStream(context.Background(),
+		&DownloadInfo{Url: FileDownloadUrl{Url: "https://cdn.115.com/f"}, Header: http.Header{}}, DefaultDownloadOptions())
+	assert.NoError(t, err)
+	_, _ = io.Copy(io.Discard, body)
+	body.Close()
+	_, _ = io.Copy(io.Discard, c.limitReader(context.Background(), bytes.NewReader(make([]byte, 10))))
+
+	m := c.Metrics()
+	assert.Equal(t, int64(2), m.Requests[ApiFileRename])
+	assert.Equal(t, int64(1), m.Requests[ApiFileDelete])
+	assert.Equal(t, int64(1), m.Errors[990002])
+	assert.Equal(t, int64(1), m.HTTPErrors[http.StatusTooManyRequests])
+	assert.Equal(t, int64(len(data)), m.BytesDownloaded)
+	assert.Equal(t, int64(10), m.BytesUploaded)
+}
+
+func TestUploadOnConflict(t *testing.T) {
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"state":true,"cid":"5","count":3,"data":[` +
+			`{"fid":"1","cid":"5","n":"a.txt","s":3},{"fid":"2","cid":"5","n":"a (1).txt"},{"cid":"9","pid":"5","n":"b.txt"}]}`))
+	})
+	defer done()
+	c.UserID, c.Userkey = 1, "key"
+	c.UploadMetaInfo = &UploadMetaInfo{SizeLimit: GB}
+
+	f, err := os.CreateTemp(t.TempDir(), "a")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	report, err := c.UploadFile("5", "a.txt", 0, f, UploadMultipartWithOnConflict(ConflictSkip))
+	assert.NoError(t, err)
+	assert.Equal(t, UploadMethodSkipped, report.Method)
+	assert.Equal(t, "1", report.File.FileID)
+	_, err = c.UploadFile("5", "a.txt", 0, f, UploadMultipartWithOnConflict(ConflictError))
+	assert.ErrorIs(t, err, ErrExist)
+
+	name, err := c.freeFileName(context.Background(), "5", "a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "a (2).txt", name)
+	existing, err := c.findFileByName(context.Background(), "5", "b.txt")
+	assert.NoError(t, err)
+	assert.Nil(t, existing)
+}
+
+func TestCheckExistsBySHA1(t *testing.T) {
+	var probes []string
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/files/shasearch", r.URL.Path)
+		sha1 := r.URL.Query().Get("sha1")
+		probes = append(probes, sha1)
+		if sha1 == "AA" {
+			_, _ = w.Write([]byte(`{"state":true,"data":{"file_id":"1","category_id":"2","file_name":"a","file_size":"3","pick_code":"pc","sha1":"AA"}}`))
+			return
+		}
+		switch sha1 {
+		case "CC":
+			_, _ = w.Write([]byte(`{"state":false,"error":"busy","errno":990001}`))
+		case "DD":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			_, _ = w.Write([]byte(`{"state":false,"error":"","errNo":0}`))
+		}
+	})
+	defer done()
+
+	found, err := c.CheckExistsBySHA1([]FileDigest{{SHA1: "aa", Size: 3}, {SHA1: "BB"}, {SHA1: "AA"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"AA", "BB"}, probes)
+	assert.Len(t, found, 2)
+	assert.Equal(t, "pc", found["AA"].PickCode)
+	assert.Equal(t, "2", found["AA"].ParentID)
+	assert.Nil(t, found["BB"])
+
+	found, err = c.CheckExistsBySHA1([]FileDigest{{SHA1: "AA", Size: 4}})
+	assert.NoError(t, err)
+	assert.Nil(t, found["AA"])
+
+	file, err := c.SearchBySHA1("CC")
+	assert.Error(t, err)
+	assert.Nil(t, file)
+	file, err = c.SearchBySHA1("DD")
+	assert.Error(t, err)
+	assert.Nil(t, file)
+}
+
+func TestFilterByExt(t *testing.T) {
+	files := []File{
+		{Name: "a.MKV"}, {Name: "b.mp4"}, {Name: "c.txt"}, {Name: "d.ts", IsDirectory: true}, {Name: "ts"}, {Name: "e.ts"},
+	}
+	assert.Equal(t, []File{{Name: "a.MKV"}, {Name: "b.mp4"}, {Name: "e.ts"}}, FilterByExt(files, ".mkv", "MP4", "ts"))
+	assert.Empty(t, FilterByExt(files))
+}
+
+func TestNaturalLess(t *testing.T) {
+	names := []string{
+		"第10集.mp4", "file10.txt", "File2.txt", "file1.txt", "第2集.mp4", "a", "10", "9",
+		"file01.txt", "B", "a1b2", "a1b10", "电影", "第1集.mp4",
+	}
+	sort.Slice(names, func(i, j int) bool { return NaturalLess(names[i], names[j]) })
+	assert.Equal(t, []string{
+		"9", "10", "a", "a1b2", "a1b10", "B",
+		"file01.txt", "file1.txt", "File2.txt", "file10.txt",
+		"电影", "第1集.mp4", "第2集.mp4", "第10集.mp4",
+	}, names)
+	assert.False(t, NaturalLess("a", "a"))
+	assert.True(t, NaturalLess("A", "a"))
+	assert.True(t, NaturalLess("a", "a1"))
+}
+
+func TestShowHidden(t *testing.T) {
+	var switches []url.Values
+	c, done := newMockClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ct") == "hiddenfiles" {
+			_ = r.ParseForm()
+			switches = append(switches, r.PostForm)
+			_, _ = w.Write([]byte(`{"state":true}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"state":true,"cid":"5","count":1,"data":[{"fid":"1","cid":"5","n":"a"}]}`))
+	})
+	defer done()
+	c.SetHiddenPassword("123456")
+
+	for i := 0; i < 2; i++ {
+		files, err := c.ListWithOptions("5", ListWithShowHidden(true))
+		assert.NoError(t, err)
+		assert.Len(t, *files, 1)
+	}
+	assert.Len(t, switches, 1)
+	assert.Equal(t, "1", switches[0].Get("show"))
+	assert.Equal(t, "123456", switches[0].Get("safe_pwd"))
+
+	assert.NoError(t, c.SwitchHiddenMode(false))
+	_, err := c.ListWithLimit("5", 10, ListWithShowHidden(true))
+	assert.NoError(t, err)
+	assert.Len(t, switches, 3)
+}
+
 func teardown(t *testing.T) func(t *testing.T) {
 	cr := &Credential{}
 	assert.Nil(t, cr.FromCookie(cookieStr))
@@ -0,0 +1,69 @@
+package driver
+
+import "sync"
+
+// Kinds of PlannedOp.
+const (
+	PlannedOpDelete          = "delete"
+	PlannedOpDeletePermanent = "delete_permanent"
+	PlannedOpMove            = "move"
+	PlannedOpRename          = "rename"
+)
+
+// PlannedOp is a delete, move or rename which a client in dry run mode skipped.
+type PlannedOp struct {
+	Op string
+	// DirID is the parent directory of a delete or the destination of a move.
+	DirID   string
+	FileIDs []string
+	// NewName is the new name of a rename.
+	NewName string
+}
+
+type dryRun struct {
+	mu  sync.Mutex
+	ops []PlannedOp
+}
+
+// SetDryRun turns on or off the dry run mode. In dry run mode Delete, DeleteFiles,
+// DeletePermanent, Move, MoveByPath, Rename and RenameBatch send nothing and
+// succeed, the operations are recorded instead, see PlannedOps.
+func (c *Pan115Client) SetDryRun(enable bool) *Pan115Client {
+	if !enable {
+		c.dryRun.Store(nil)
+	} else if c.dryRun.Load() == nil {
+		c.dryRun.Store(&dryRun{})
+	}
+	return c
+}
+
+// DryRun reports whether the client is in dry run mode.
+func (c *Pan115Client) DryRun() bool {
+	return c.dryRun.Load() != nil
+}
+
+// PlannedOps returns and clears the operations recorded in dry run mode.
+func (c *Pan115Client) PlannedOps() []PlannedOp {
+	d := c.dryRun.Load()
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ops := d.ops
+	d.ops = nil
+	return ops
+}
+
+// plan records op and reports whether the client is in dry run mode.
+func (c *Pan115Client) plan(op PlannedOp) bool {
+	d := c.dryRun.Load()
+	if d == nil {
+		return false
+	}
+	op.FileIDs = append([]string(nil), op.FileIDs...)
+	d.mu.Lock()
+	d.ops = append(d.ops, op)
+	d.mu.Unlock()
+	return true
+}
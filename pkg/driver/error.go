@@ -1,6 +1,11 @@
 package driver
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
 	"strconv"
 	"strings"
 
@@ -14,6 +19,9 @@ var (
 // cookie err
 var (
 	ErrBadCookie = errors.New("bad cookie")
+
+	// ErrCredentialNotFound means the source of LoadCredential has no credential.
+	ErrCredentialNotFound = errors.New("credential not found")
 )
 
 var (
@@ -22,6 +30,7 @@ var (
 	ErrOfflineNoTimes     = errors.New("offline download quota has been used up, you can purchase a VIP experience or upgrade to VIP service to get more quota")
 	ErrOfflineInvalidLink = errors.New("invalid download link")
 	ErrOfflineTaskExisted = errors.New("offline task existed")
+	ErrOfflineTaskFailed  = errors.New("offline task failed")
 
 	ErrOrderNotSupport = errors.New("file order not supported")
 
@@ -33,6 +42,10 @@ var (
 
 	ErrQrcodeExpired = errors.New("qrcode expired")
 
+	ErrRateLimited = errors.New("too many requests")
+
+	ErrVerifyRequired = errors.New("verification required")
+
 	// ErrUnexpected is the fall-back error whose code is not handled.
 	ErrUnexpected = errors.New("unexpected error")
 
@@ -45,6 +58,8 @@ var (
 
 	ErrUploadTooLarge = errors.New("upload reach the limit")
 
+	ErrInsufficientSpace = errors.New("insufficient space")
+
 	ErrUploadFailed = errors.New("upload failed")
 
 	ErrImportDirectory = errors.New("can not import directory")
@@ -57,18 +72,33 @@ var (
 
 	ErrDownloadFileTooBig = errors.New("target file is too big to download")
 
+	ErrRangeNotSupported = errors.New("range request not supported")
+
+	ErrDownloadURLExpired = errors.New("download URL expired")
+
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+
 	ErrCyclicCopy = errors.New("cyclic copy")
 
 	ErrCyclicMove = errors.New("cyclic move")
 
+	// ErrCopyStalled means a recursive copy stopped making progress.
+	ErrCopyStalled = errors.New("copy stalled")
+
 	ErrVideoNotReady = errors.New("video is not ready")
 
+	ErrNoThumbnail = errors.New("no thumbnail")
+
 	ErrWrongParams = errors.New("wrong parameters")
 
+	ErrInvalidFileName = errors.New("invalid file name")
+
 	ErrRepeatLogin = errors.New("repeat login")
 
 	ErrFailedToLogin = errors.New("failed to login")
 
+	ErrAccountFrozen = errors.New("account frozen")
+
 	ErrDoesLoggedOut = errors.New("you have been kicked out by multi-device login management")
 
 	ErrPickCodeNotExist = errors.New("pickcode does not exist")
@@ -77,8 +107,16 @@ var (
 
 	ErrSharedNotFound = errors.New("shared link not found")
 
+	ErrShareReceiveCodeIncorrect = errors.New("share receive code incorrect")
+
+	ErrShareSaveLimit = errors.New("share save reaches the limit")
+
 	ErrPickCodeIsEmpty = errors.New("empty pickcode")
 
+	ErrSameParent = errors.New("already in the target directory")
+
+	ErrSameName = errors.New("already has the name")
+
 	ErrUploadSH1Invalid = errors.New("userid/filesize/target/pickcode/ invalid")
 
 	ErrUploadSigInvalid = errors.New("sig invalid")
@@ -87,6 +125,7 @@ var (
 		// Normal errors
 		99:     ErrNotLogin,
 		990001: ErrNotLogin,
+		911:    ErrVerifyRequired,
 		// Offline errors
 		10010: ErrOfflineNoTimes,
 		10004: ErrOfflineInvalidLink,
@@ -122,6 +161,7 @@ var (
 		// share
 		4100009: ErrSharedInvalid,
 		4100026: ErrSharedNotFound,
+		4100012: ErrShareReceiveCodeIncorrect,
 		// pickCode
 		50003: ErrPickCodeNotExist,
 		50001: ErrPickCodeIsEmpty,
@@ -131,28 +171,208 @@ var (
 	}
 )
 
+// APIError is an error returned by 115, it wraps the sentinel error of Code,
+// or ErrUnexpected if Code is not handled, so errors.Is works on it.
+type APIError struct {
+	// Code is the errno of 115, 0 if unknown.
+	Code int
+	// Message is the error message of 115, or the response body if no
+	// message is found.
+	Message string
+
+	body string
+	err  error
+}
+
+func (e *APIError) Error() string {
+	if e.body == "" {
+		return e.err.Error()
+	}
+	return e.body + ": " + e.err.Error()
+}
+
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
 func GetErr(code int, respBody ...string) error {
-	errWithMsg := ErrUnexpected
+	apiErr := &APIError{Code: code, err: ErrUnexpected}
 	if err, found := errMap[code]; found {
-		errWithMsg = err
+		apiErr.err = err
 	}
-	// if len(respBody) > 0 && errors.Is(ErrUnexpected, errWithMsg) {
 	if len(respBody) > 0 {
 		bodyRaw := respBody[0]
 		readableBody, err := strconv.Unquote(strings.Replace(strconv.Quote(bodyRaw), `\\u`, `\u`, -1))
 		if err != nil {
-			return errors.Wrap(errWithMsg, bodyRaw)
+			readableBody = bodyRaw
+		}
+		apiErr.body = readableBody
+		apiErr.Message = errMessage(readableBody)
+		if apiErr.err == ErrUnexpected && strings.Contains(apiErr.Message, "频繁") {
+			apiErr.err = ErrRateLimited
+		}
+	}
+	if apiErr.err == ErrVerifyRequired {
+		return newVerifyRequiredError(apiErr)
+	}
+	return apiErr
+}
+
+// errMessage returns the error message in a response body, or body itself.
+func errMessage(body string) string {
+	result := struct {
+		Error    string `json:"error"`
+		ErrorMsg string `json:"error_msg"`
+		Message  string `json:"message"`
+		Msg      string `json:"msg"`
+	}{}
+	if json.Unmarshal([]byte(body), &result) != nil {
+		return body
+	}
+	for _, msg := range []string{result.Error, result.ErrorMsg, result.Message, result.Msg} {
+		if msg != "" {
+			return msg
+		}
+	}
+	return body
+}
+
+// IsNotFound reports whether err means the file, pickcode or share does not exist.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotExist) ||
+		errors.Is(err, ErrDownloadFileNotExistOrHasDeleted) ||
+		errors.Is(err, ErrPickCodeNotExist) ||
+		errors.Is(err, ErrSharedNotFound)
+}
+
+// IsRateLimited reports whether err means requests are too frequent.
+func IsRateLimited(err error) bool {
+	return errors.Is(err, ErrRateLimited)
+}
+
+// IsNotLoggedIn reports whether err means the credential is invalid or logged out.
+func IsNotLoggedIn(err error) bool {
+	return errors.Is(err, ErrNotLogin) ||
+		errors.Is(err, ErrBadCookie) ||
+		errors.Is(err, ErrCredentialInvalid) ||
+		errors.Is(err, ErrSessionExited) ||
+		errors.Is(err, ErrDoesLoggedOut)
+}
+
+// BatchError is returned by batch operations which partially failed.
+type BatchError struct {
+	// Failed ids, they can be retried.
+	Failed []string
+	// Total number of ids in the batch operation.
+	Total int
+	// Err is the last error occurred.
+	Err error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%d of %d items failed: %v", len(e.Failed), e.Total, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+func (e *BatchError) add(err error, ids ...string) {
+	e.Failed = append(e.Failed, ids...)
+	e.Err = err
+}
+
+func (e *BatchError) orNil() error {
+	if len(e.Failed) == 0 {
+		return nil
+	}
+	return e
+}
+
+// VerifyRequiredError is returned when 115 requires the user to pass a captcha,
+// usually after too many requests. Open URL in a browser logged in to the same
+// account, or solve the captcha and call SubmitVerify with the code.
+type VerifyRequiredError struct {
+	// URL is the page of the captcha.
+	URL string
+	// Token is the sign of the captcha if 115 returns one.
+	Token string
+	Err   *APIError
+}
+
+func (e *VerifyRequiredError) Error() string {
+	return fmt.Sprintf("%v, verify at %s", e.Err, e.URL)
+}
+
+func (e *VerifyRequiredError) Unwrap() error {
+	return e.Err
+}
+
+func newVerifyRequiredError(apiErr *APIError) *VerifyRequiredError {
+	e := &VerifyRequiredError{URL: ApiCaptchaPage, Err: apiErr}
+	result := struct {
+		URL  string `json:"url"`
+		Sign string `json:"sign"`
+	}{}
+	if json.Unmarshal([]byte(apiErr.body), &result) == nil {
+		if result.URL != "" {
+			e.URL = result.URL
 		}
-		return errors.Wrap(errWithMsg, readableBody)
+		e.Token = result.Sign
 	}
-	return errWithMsg
+	return e
+}
+
+// LoginErrorKind is the category of a LoginError.
+type LoginErrorKind int
+
+const (
+	LoginErrUnknown LoginErrorKind = iota
+	// LoginErrNotLoggedIn means the credential is invalid, login again.
+	LoginErrNotLoggedIn
+	// LoginErrAccountFrozen means the account can not be used.
+	LoginErrAccountFrozen
+	// LoginErrNetwork means the check did not reach 115, back off and retry.
+	LoginErrNetwork
+)
+
+// LoginError is returned by LoginCheckDetail.
+type LoginError struct {
+	Kind LoginErrorKind
+	Err  error
+}
+
+func (e *LoginError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LoginError) Unwrap() error {
+	return e.Err
+}
+
+func newLoginError(err error) *LoginError {
+	var netErr net.Error
+	kind := LoginErrUnknown
+	switch {
+	case errors.Is(err, ErrAccountFrozen) || strings.Contains(err.Error(), "冻结"):
+		kind = LoginErrAccountFrozen
+	case IsNotLoggedIn(err):
+		kind = LoginErrNotLoggedIn
+	case errors.As(err, &netErr), errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		kind = LoginErrNetwork
+	}
+	return &LoginError{Kind: kind, Err: err}
 }
 
 type ResultWithErr interface {
 	Err(respBody ...string) error
 }
 
+// CheckErr returns the request error, or the *APIError of the response.
 func CheckErr(err error, result ResultWithErr, restyResp *resty.Response) error {
+	if err == nil && restyResp.StatusCode() == http.StatusTooManyRequests {
+		return &APIError{Code: http.StatusTooManyRequests, Message: restyResp.Status(), err: ErrRateLimited}
+	}
 	if err == nil {
 		err = result.Err(restyResp.String())
 	}
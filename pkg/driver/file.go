@@ -21,6 +21,8 @@ type File struct {
 	PickCode string
 	// SHA1 hash of file content, in HEX format.
 	Sha1 string
+	// Thumbnail URL of an image or video, empty if not generated yet.
+	Thumbnail string
 
 	// Is file stared
 	Star bool
@@ -64,15 +66,12 @@ func (f *File) from(fileInfo *FileInfo) *File {
 	f.Size = int64(fileInfo.Size)
 	f.PickCode = fileInfo.PickCode
 	f.Sha1 = fileInfo.Sha1
+	f.Thumbnail = fileInfo.Thumb
 
 	f.Star = fileInfo.IsStar != 0
 	f.Labels = make([]*Label, len(fileInfo.Labels))
 	for i, l := range fileInfo.Labels {
-		f.Labels[i] = &Label{
-			ID:    l.ID,
-			Name:  l.Name,
-			Color: LabelColor(LabelColorMap[l.Color]),
-		}
+		f.Labels[i] = l.label()
 	}
 
 	f.CreateTime = time.Unix(int64(fileInfo.CreateTime), 0)
@@ -0,0 +1,36 @@
+package driver
+
+import (
+	"io/fs"
+	"time"
+)
+
+// fileInfo adapts File to fs.FileInfo, name is the base name in the path,
+// which is "/" for the root.
+type fileInfo struct {
+	name string
+	file *File
+}
+
+func (fi *fileInfo) Name() string { return fi.name }
+
+func (fi *fileInfo) Size() int64 {
+	if fi.file.IsDirectory {
+		return 0
+	}
+	return fi.file.Size
+}
+
+func (fi *fileInfo) Mode() fs.FileMode {
+	if fi.file.IsDirectory {
+		return fs.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+func (fi *fileInfo) ModTime() time.Time { return fi.file.UpdateTime }
+
+func (fi *fileInfo) IsDir() bool { return fi.file.IsDirectory }
+
+// Sys returns the *File.
+func (fi *fileInfo) Sys() any { return fi.file }
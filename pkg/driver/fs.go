@@ -0,0 +1,164 @@
+package driver
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// dirFS implements fs.FS, fs.ReadDirFS and fs.StatFS for a directory tree.
+type dirFS struct {
+	c     *Pan115Client
+	root  string
+	cache *pathCache
+}
+
+// FS returns a read-only fs.FS of the directory tree rooted at rootCID, which
+// also implements fs.ReadDirFS and fs.StatFS. Files are read by range requests
+// and Seek is supported. Lookups are cached for DefaultPathCacheTTL.
+func (c *Pan115Client) FS(rootCID string) fs.FS {
+	if rootCID == "" {
+		rootCID = "0"
+	}
+	return &dirFS{c: c, root: rootCID, cache: newPathCache(DefaultPathCacheSize, DefaultPathCacheTTL)}
+}
+
+func (d *dirFS) Open(name string) (fs.File, error) {
+	f, err := d.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if f.IsDirectory {
+		return &fsDir{d: d, name: name, file: f}, nil
+	}
+	return &fsFile{rangeReader: d.c.newRangeReader(context.Background(), f.PickCode, f.Size), name: name, file: f}, nil
+}
+
+func (d *dirFS) Stat(name string) (fs.FileInfo, error) {
+	f, err := d.stat("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return &fileInfo{name: path.Base(name), file: f}, nil
+}
+
+func (d *dirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, err := d.stat("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !f.IsDirectory {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	return d.readDir(name, f)
+}
+
+func (d *dirFS) stat(op, name string) (*File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	f, err := d.resolve(name)
+	if err != nil {
+		return nil, pathError(op, name, err)
+	}
+	return f, nil
+}
+
+// resolve gets the file at name by listing its parents.
+func (d *dirFS) resolve(name string) (*File, error) {
+	if name == "." {
+		return &File{IsDirectory: true, FileID: d.root}, nil
+	}
+	if e, ok := d.cache.get(name); ok {
+		return e.file, nil
+	}
+	parent, err := d.resolve(path.Dir(name))
+	if err != nil {
+		return nil, err
+	}
+	if !parent.IsDirectory {
+		return nil, ErrNotExist
+	}
+	if _, err = d.readDir(path.Dir(name), parent); err != nil {
+		return nil, err
+	}
+	if e, ok := d.cache.get(name); ok {
+		return e.file, nil
+	}
+	return nil, ErrNotExist
+}
+
+// readDir lists the directory dir and caches its children.
+func (d *dirFS) readDir(dir string, f *File) ([]fs.DirEntry, error) {
+	files, err := d.c.listAllFiles(context.Background(), f.FileID)
+	if err != nil {
+		return nil, pathError("readdir", dir, err)
+	}
+	entries := make([]fs.DirEntry, 0, len(files))
+	for i := range files {
+		child := &files[i]
+		d.cache.put(path.Join(dir, child.Name), child.FileID, child)
+		entries = append(entries, fs.FileInfoToDirEntry(&fileInfo{name: child.Name, file: child}))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// fsFile is an opened file of dirFS.
+type fsFile struct {
+	*rangeReader
+	name string
+	file *File
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) {
+	return &fileInfo{name: path.Base(f.name), file: f.file}, nil
+}
+
+// fsDir is an opened directory of dirFS.
+type fsDir struct {
+	d    *dirFS
+	name string
+	file *File
+
+	entries []fs.DirEntry
+	listed  bool
+}
+
+func (f *fsDir) Stat() (fs.FileInfo, error) {
+	return &fileInfo{name: path.Base(f.name), file: f.file}, nil
+}
+
+func (f *fsDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrInvalid}
+}
+
+func (f *fsDir) Close() error {
+	return nil
+}
+
+func (f *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.listed {
+		entries, err := f.d.readDir(f.name, f.file)
+		if err != nil {
+			return nil, err
+		}
+		f.entries, f.listed = entries, true
+	}
+	if n <= 0 {
+		entries := f.entries
+		f.entries = nil
+		return entries, nil
+	}
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(f.entries) {
+		n = len(f.entries)
+	}
+	entries := f.entries[:n]
+	f.entries = f.entries[n:]
+	return entries, nil
+}
@@ -0,0 +1,42 @@
+package driver
+
+import (
+	"context"
+
+	"github.com/go-resty/resty/v2"
+)
+
+type requestHeadersKey struct{}
+
+// WithRequestHeaders returns a copy of ctx whose requests carry headers, e.g.
+// Referer or Origin for a single call of a Context method. They override the
+// headers of the client and the ones set by the method, and add to those of
+// an outer WithRequestHeaders.
+func WithRequestHeaders(ctx context.Context, headers map[string]string) context.Context {
+	merged := map[string]string{}
+	if outer, ok := ctx.Value(requestHeadersKey{}).(map[string]string); ok {
+		for k, v := range outer {
+			merged[k] = v
+		}
+	}
+	for k, v := range headers {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, requestHeadersKey{}, merged)
+}
+
+// hookRequestHeaders adds the hook applying WithRequestHeaders to the resty
+// client once.
+func (c *Pan115Client) hookRequestHeaders() {
+	if c.headersHooked == c.Client {
+		return
+	}
+	c.headersHooked = c.Client
+	c.Client.OnBeforeRequest(func(_ *resty.Client, req *resty.Request) error {
+		headers, _ := req.Context().Value(requestHeadersKey{}).(map[string]string)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,56 @@
+package driver
+
+import "context"
+
+// SetHiddenPassword sets the password of the hidden mode, which is required
+// to show hidden files if the account has one.
+func (c *Pan115Client) SetHiddenPassword(pwd string) *Pan115Client {
+	c.hiddenPassword = pwd
+	return c
+}
+
+// SwitchHiddenMode turns on or off the hidden mode of the session, in which
+// hidden files are included in listings.
+func (c *Pan115Client) SwitchHiddenMode(show bool) error {
+	return c.SwitchHiddenModeContext(context.Background(), show)
+}
+
+// SwitchHiddenModeContext is like SwitchHiddenMode but with a context.
+func (c *Pan115Client) SwitchHiddenModeContext(ctx context.Context, show bool) error {
+	form := map[string]string{
+		"show":       "0",
+		"valid_type": "1",
+		"safe_pwd":   c.hiddenPassword,
+	}
+	if show {
+		form["show"] = "1"
+	}
+	result := BasicResp{}
+	req := c.NewRequest().
+		SetContext(ctx).
+		SetFormData(form).
+		ForceContentType("application/json;charset=UTF-8").
+		SetResult(&result)
+	resp, err := req.Post(ApiHiddenSwitch)
+	if err = CheckErr(err, &result, resp); err != nil {
+		return err
+	}
+	c.hiddenShown.Store(show)
+	return nil
+}
+
+// showHidden turns on the hidden mode unless it is on already.
+func (c *Pan115Client) showHidden(ctx context.Context) error {
+	if c.hiddenShown.Load() {
+		return nil
+	}
+	return c.SwitchHiddenModeContext(ctx, true)
+}
+
+// prepareList applies the ListOptions which are not parameters of listing.
+func (c *Pan115Client) prepareList(ctx context.Context, o *ListOptions) error {
+	if o.ShowHidden {
+		return c.showHidden(ctx)
+	}
+	return nil
+}
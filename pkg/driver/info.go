@@ -1,9 +1,21 @@
 package driver
 
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
 // GetInfo get space info and login device info.
 func (c *Pan115Client) GetInfo() (InfoData, error) {
+	return c.GetInfoContext(context.Background())
+}
+
+// GetInfoContext is like GetInfo but with a context.
+func (c *Pan115Client) GetInfoContext(ctx context.Context) (InfoData, error) {
 	result := InfoResponse{}
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetResult(&result).
 		ForceContentType("application/json;charset=UTF-8")
 
@@ -15,6 +27,38 @@ func (c *Pan115Client) GetInfo() (InfoData, error) {
 	return result.Data, nil
 }
 
+// GetSpaceInfo gets the storage space usage, the offline download quota is
+// reported by ListOfflineTasks.
+func (c *Pan115Client) GetSpaceInfo() (*SpaceInfo, error) {
+	return c.GetSpaceInfoContext(context.Background())
+}
+
+// GetSpaceInfoContext is like GetSpaceInfo but with a context.
+func (c *Pan115Client) GetSpaceInfoContext(ctx context.Context) (*SpaceInfo, error) {
+	info, err := c.GetInfoContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &info.SpaceInfo, nil
+}
+
+// CheckSpace returns ErrInsufficientSpace if the remaining space is less than size.
+func (c *Pan115Client) CheckSpace(size int64) error {
+	return c.CheckSpaceContext(context.Background(), size)
+}
+
+// CheckSpaceContext is like CheckSpace but with a context.
+func (c *Pan115Client) CheckSpaceContext(ctx context.Context, size int64) error {
+	info, err := c.GetSpaceInfoContext(ctx)
+	if err != nil {
+		return err
+	}
+	if info.Remaining() < size {
+		return errors.Wrapf(ErrInsufficientSpace, "need %d bytes, %d bytes remaining", size, info.Remaining())
+	}
+	return nil
+}
+
 type InfoResponse struct {
 	BasicResp
 	Data InfoData `json:"data"`
@@ -47,6 +91,21 @@ type SpaceInfo struct {
 	AllUse    UseSize    `json:"all_use"`
 }
 
+// Total returns the total space in bytes.
+func (s *SpaceInfo) Total() int64 {
+	return s.AllTotal.Size
+}
+
+// Used returns the used space in bytes.
+func (s *SpaceInfo) Used() int64 {
+	return s.AllUse.Size
+}
+
+// Remaining returns the remaining space in bytes.
+func (s *SpaceInfo) Remaining() int64 {
+	return s.AllRemain.Size
+}
+
 type LastDevice struct {
 	IP       string `json:"ip"`
 	Device   string `json:"device"`
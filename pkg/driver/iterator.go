@@ -0,0 +1,93 @@
+package driver
+
+import (
+	"context"
+)
+
+// FileIterator iterates over files of a directory, fetching pages on demand.
+type FileIterator struct {
+	ctx    context.Context
+	c      *Pan115Client
+	dirID  string
+	opts   []GetFileOptions
+	limit  int64
+	offset int64
+
+	files []File
+	index int
+	done  bool
+	err   error
+}
+
+// ListIterator returns an iterator over all files and directories in dirID.
+// Ordering, page size and start offset can be set by GetFileOptions.
+func (c *Pan115Client) ListIterator(dirID string, opts ...GetFileOptions) *FileIterator {
+	return c.ListIteratorContext(context.Background(), dirID, opts...)
+}
+
+// ListIteratorContext is like ListIterator but with a context.
+func (c *Pan115Client) ListIteratorContext(ctx context.Context, dirID string, opts ...GetFileOptions) *FileIterator {
+	o := DefaultGetFileOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	limit := o.pageSize
+	if limit <= 0 {
+		limit = FileListLimit
+	}
+	if limit > MaxDirPageLimit {
+		limit = MaxDirPageLimit
+	}
+	return &FileIterator{
+		ctx:    ctx,
+		c:      c,
+		dirID:  dirID,
+		opts:   opts,
+		limit:  limit,
+		offset: o.offset,
+	}
+}
+
+// Next returns the next file, false will be returned when there are no more
+// files or an error occurred, check Err for the latter.
+func (it *FileIterator) Next() (*File, bool) {
+	for it.index >= len(it.files) {
+		if it.done || it.err != nil {
+			return nil, false
+		}
+		it.fetch()
+	}
+	f := &it.files[it.index]
+	it.index++
+	return f, true
+}
+
+// Err returns the error which terminated the iteration.
+func (it *FileIterator) Err() error {
+	return it.err
+}
+
+func (it *FileIterator) fetch() {
+	if isCalledByAlistV3() {
+		it.err = ErrorNotSupportAlist
+		return
+	}
+	req := it.c.NewRequest().SetContext(it.ctx).ForceContentType("application/json;charset=UTF-8")
+	opts := append(it.opts[:len(it.opts):len(it.opts)], WithLimit(it.limit), WithOffset(it.offset))
+	result, err := GetFiles(req, it.dirID, opts...)
+	if err != nil {
+		it.err = err
+		return
+	}
+	// a new slice for each page, the files of the earlier pages are still
+	// referenced by the pointers returned by Next
+	it.files = make([]File, 0, len(result.Files))
+	it.index = 0
+	for _, fileInfo := range result.Files {
+		it.files = append(it.files, *(&File{}).from(&fileInfo))
+	}
+	it.offset += int64(len(result.Files))
+	if len(result.Files) == 0 || it.offset >= int64(result.Count) {
+		it.done = true
+	}
+}
@@ -1,5 +1,12 @@
 package driver
 
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
 var (
 	LabelColors = []string{
 		// No Color
@@ -39,3 +46,118 @@ type Label struct {
 }
 
 type LabelColor int
+
+// Hex returns the hex code of the color, e.g. "#FF4B30".
+func (c LabelColor) Hex() string {
+	if c < 0 || int(c) >= len(LabelColors) {
+		return LabelColors[0]
+	}
+	return LabelColors[c]
+}
+
+func (l *LabelInfo) label() *Label {
+	return &Label{
+		ID:    l.ID,
+		Name:  l.Name,
+		Color: LabelColor(LabelColorMap[strings.ToUpper(l.Color)]),
+	}
+}
+
+// ListLabels lists all labels.
+func (c *Pan115Client) ListLabels() ([]Label, error) {
+	return c.ListLabelsContext(context.Background())
+}
+
+// ListLabelsContext is like ListLabels but with a context.
+func (c *Pan115Client) ListLabelsContext(ctx context.Context) ([]Label, error) {
+	result := LabelListResp{}
+	req := c.NewRequest().
+		SetContext(ctx).
+		SetQueryParams(map[string]string{
+			"offset": "0",
+			"limit":  "11500",
+			"sort":   "create_time",
+			"order":  "asc",
+		}).
+		ForceContentType("application/json;charset=UTF-8").
+		SetResult(&result)
+	resp, err := req.Get(ApiLabelList)
+	if err = CheckErr(err, &result, resp); err != nil {
+		return nil, err
+	}
+	labels := make([]Label, len(result.Data.List))
+	for i, l := range result.Data.List {
+		labels[i] = *l.label()
+	}
+	return labels, nil
+}
+
+// CreateLabel creates a label, color is one of LabelColors.
+func (c *Pan115Client) CreateLabel(name, color string) (*Label, error) {
+	return c.CreateLabelContext(context.Background(), name, color)
+}
+
+// CreateLabelContext is like CreateLabel but with a context.
+func (c *Pan115Client) CreateLabelContext(ctx context.Context, name, color string) (*Label, error) {
+	if name == "" {
+		return nil, errors.Wrap(ErrWrongParams, "empty label name")
+	}
+	if color == "" {
+		color = LabelColors[0]
+	}
+	color = strings.ToUpper(color)
+	if _, ok := LabelColorMap[color]; !ok {
+		return nil, errors.Wrapf(ErrWrongParams, "unknown label color %s", color)
+	}
+	result := LabelAddResp{}
+	req := c.NewRequest().
+		SetContext(ctx).
+		SetFormData(map[string]string{"name[]": name + "\x07" + color}).
+		ForceContentType("application/json;charset=UTF-8").
+		SetResult(&result)
+	resp, err := req.Post(ApiLabelAdd)
+	if err = CheckErr(err, &result, resp); err != nil {
+		return nil, err
+	}
+	if len(result.Data) == 0 {
+		return nil, errors.Wrap(ErrUnexpected, "no label created")
+	}
+	return result.Data[0].label(), nil
+}
+
+// DeleteLabel deletes a label, files with the label are not affected.
+func (c *Pan115Client) DeleteLabel(labelID string) error {
+	return c.DeleteLabelContext(context.Background(), labelID)
+}
+
+// DeleteLabelContext is like DeleteLabel but with a context.
+func (c *Pan115Client) DeleteLabelContext(ctx context.Context, labelID string) error {
+	result := BasicResp{}
+	req := c.NewRequest().
+		SetContext(ctx).
+		SetFormData(map[string]string{"id": labelID}).
+		ForceContentType("application/json;charset=UTF-8").
+		SetResult(&result)
+	resp, err := req.Post(ApiLabelDelete)
+	return CheckErr(err, &result, resp)
+}
+
+// SetFileLabels replaces the labels of a file, no labelIDs clears them.
+func (c *Pan115Client) SetFileLabels(fileID string, labelIDs []string) error {
+	return c.SetFileLabelsContext(context.Background(), fileID, labelIDs)
+}
+
+// SetFileLabelsContext is like SetFileLabels but with a context.
+func (c *Pan115Client) SetFileLabelsContext(ctx context.Context, fileID string, labelIDs []string) error {
+	result := BasicResp{}
+	req := c.NewRequest().
+		SetContext(ctx).
+		SetFormData(map[string]string{
+			"fid":        fileID,
+			"file_label": strings.Join(labelIDs, ","),
+		}).
+		ForceContentType("application/json;charset=UTF-8").
+		SetResult(&result)
+	resp, err := req.Post(ApiFileEdit)
+	return CheckErr(err, &result, resp)
+}
@@ -0,0 +1,86 @@
+package driver
+
+import (
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+)
+
+// RequestLog describes a finished request of the client. Headers, cookies and
+// bodies are not included.
+type RequestLog struct {
+	Method string
+	// Endpoint is the URL without the query.
+	Endpoint   string
+	StatusCode int
+	// Duration is the time of the last attempt.
+	Duration time.Duration
+	Attempts int
+	// Code is the error code returned by 115, 0 if none.
+	Code int
+	// Err is the error of sending the request or reading the response.
+	Err error
+}
+
+// SetRequestLogger sets fn to be called after every request of the client,
+// including retried and streamed ones but not OSS uploads. nil disables it.
+func (c *Pan115Client) SetRequestLogger(fn func(RequestLog)) *Pan115Client {
+	c.requestLogger = fn
+	c.hookRequestLogger()
+	return c
+}
+
+// hookRequestLogger adds the hooks calling the request logger and the metrics
+// recorder to the resty client once.
+func (c *Pan115Client) hookRequestLogger() {
+	if (c.requestLogger == nil && c.metrics == nil) || c.loggerHooked == c.Client {
+		return
+	}
+	c.loggerHooked = c.Client
+	c.Client.OnSuccess(func(_ *resty.Client, resp *resty.Response) {
+		c.logRequest(resp.Request, resp, nil)
+	})
+	c.Client.OnError(func(req *resty.Request, err error) {
+		var respErr *resty.ResponseError
+		if errors.As(err, &respErr) {
+			c.logRequest(req, respErr.Response, respErr.Err)
+			return
+		}
+		c.logRequest(req, nil, err)
+	})
+}
+
+func (c *Pan115Client) logRequest(req *resty.Request, resp *resty.Response, err error) {
+	fn, m := c.requestLogger, c.metrics
+	if fn == nil && m == nil {
+		return
+	}
+	log := RequestLog{
+		Method:   req.Method,
+		Endpoint: req.URL,
+		Attempts: req.Attempt,
+		Err:      err,
+	}
+	if raw := req.RawRequest; raw != nil {
+		u := *raw.URL
+		u.RawQuery, u.Fragment, u.User = "", "", nil
+		log.Endpoint = u.String()
+	}
+	if !req.Time.IsZero() {
+		log.Duration = time.Since(req.Time)
+	}
+	if resp != nil && resp.RawResponse != nil {
+		log.StatusCode = resp.StatusCode()
+		log.Duration = resp.Time()
+		if codes := respCodes(resp.Body()); len(codes) > 0 {
+			log.Code = codes[0]
+		}
+	}
+	if m != nil {
+		m.RecordRequest(log)
+	}
+	if fn != nil {
+		fn(log)
+	}
+}
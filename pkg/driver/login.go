@@ -1,20 +1,28 @@
 package driver
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	neturl "net/url"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
 // CookieCheck checks the cookie status and will not logout of other devices.
 func (c *Pan115Client) CookieCheck() error {
+	return c.CookieCheckContext(context.Background())
+}
+
+// CookieCheckContext is like CookieCheck but with a context.
+func (c *Pan115Client) CookieCheckContext(ctx context.Context) error {
 	result := struct {
 		State bool `json:"state"`
 	}{}
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetQueryParam("_", NowMilli().String()).
 		SetResult(&result)
 
@@ -26,15 +34,115 @@ func (c *Pan115Client) CookieCheck() error {
 
 // LoginCheck checks the login status and will logout of other devices.
 func (c *Pan115Client) LoginCheck() error {
+	return c.LoginCheckContext(context.Background())
+}
+
+// LoginCheckContext is like LoginCheck but with a context.
+func (c *Pan115Client) LoginCheckContext(ctx context.Context) error {
 	result := LoginResp{}
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetQueryParam("_", NowMilli().String()).
 		SetResult(&result)
 	resp, err := req.Get(ApiLoginCheck)
 	if err = CheckErr(err, &result, resp); err != nil {
 		return err
 	}
+	c.userMu.Lock()
 	c.UserID = result.Data.UserID
+	c.userMu.Unlock()
+	return nil
+}
+
+// LoginCheckDetail is like LoginCheck but returns the logged-in user, and the
+// error is a *LoginError which tells whether to login again or to back off.
+func (c *Pan115Client) LoginCheckDetail() (*UserInfo, error) {
+	return c.LoginCheckDetailContext(context.Background())
+}
+
+// LoginCheckDetailContext is like LoginCheckDetail but with a context.
+func (c *Pan115Client) LoginCheckDetailContext(ctx context.Context) (*UserInfo, error) {
+	if err := c.LoginCheckContext(ctx); err != nil {
+		return nil, newLoginError(err)
+	}
+	user, err := c.GetUserContext(ctx)
+	if err != nil {
+		return nil, newLoginError(err)
+	}
+	return user, nil
+}
+
+// SubmitVerify submits code, the answer to the captcha of a VerifyRequiredError,
+// after which the requests can be retried.
+func (c *Pan115Client) SubmitVerify(code string) error {
+	return c.SubmitVerifyContext(context.Background(), code)
+}
+
+// SubmitVerifyContext is like SubmitVerify but with a context.
+func (c *Pan115Client) SubmitVerifyContext(ctx context.Context, code string) error {
+	sign := struct {
+		State bool   `json:"state"`
+		Sign  string `json:"sign"`
+	}{}
+	resp, err := c.NewRequest().
+		SetContext(ctx).
+		ForceContentType("application/json;charset=UTF-8").
+		SetResult(&sign).
+		Get(ApiCaptchaSign)
+	if err != nil {
+		return err
+	}
+	if sign.Sign == "" {
+		return errors.Wrapf(ErrUnexpected, "no captcha sign in %s", resp.String())
+	}
+	result := BasicResp{}
+	req := c.NewRequest().
+		SetContext(ctx).
+		SetFormData(map[string]string{
+			"ac":   "security_code",
+			"type": "web",
+			"code": code,
+			"sign": sign.Sign,
+		}).
+		ForceContentType("application/json;charset=UTF-8").
+		SetResult(&result)
+	resp, err = req.Post(ApiCaptchaSubmit)
+	return CheckErr(err, &result, resp)
+}
+
+// PingTimeout bounds Ping unless the context has an earlier deadline.
+const PingTimeout = 5 * time.Second
+
+// Ping checks the connectivity to 115 and the login status with a cheap request,
+// it will not logout of other devices. The error is a *LoginError whose Kind is
+// LoginErrNetwork if 115 is not reached and LoginErrNotLoggedIn if the
+// credential is expired.
+func (c *Pan115Client) Ping() error {
+	return c.PingContext(context.Background())
+}
+
+// PingContext is like Ping but with a context.
+func (c *Pan115Client) PingContext(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, PingTimeout)
+	defer cancel()
+	result := struct {
+		State bool `json:"state"`
+	}{}
+	resp, err := c.NewRequest().
+		SetContext(ctx).
+		SetQueryParam("_", NowMilli().String()).
+		ForceContentType("application/json;charset=UTF-8").
+		SetResult(&result).
+		Get(ApiStatusCheck)
+	if err != nil {
+		return newLoginError(err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return newLoginError(errors.Wrapf(ErrUnexpected, "status %s", resp.Status()))
+	}
+	if !result.State {
+		return newLoginError(ErrBadCookie)
+	}
 	return nil
 }
 
@@ -83,6 +191,9 @@ func (c *Pan115Client) importCookies(cookies map[string]string, domain string, p
 	c.SetCookies(cks...)
 }
 
+// Credential is the login session of 115, it can be saved by encoding/json
+// and restored by json.Unmarshal, or converted from/to cookie string by
+// FromCookie and Cookie.
 type Credential struct {
 	UID  string `json:"UID"`
 	CID  string `json:"CID"`
@@ -99,7 +210,7 @@ func (cr *Credential) FromCookie(cookie string) error {
 
 	cookieMap := map[string]string{}
 	for _, item := range items {
-		pairs := strings.Split(strings.TrimSpace(item), "=")
+		pairs := strings.SplitN(strings.TrimSpace(item), "=", 2)
 		if len(pairs) != 2 {
 			return ErrBadCookie
 		}
@@ -118,6 +229,50 @@ func (cr *Credential) FromCookie(cookie string) error {
 	return nil
 }
 
+// Validate checks that UID, CID and SEID are present and well-formed so that a
+// truncated cookie fails before any request, the error wraps ErrBadCookie.
+// UID looks like "<user id>_<login app>_<timestamp>", the others are made of
+// letters and digits. KID is optional.
+func (cr *Credential) Validate() error {
+	for _, field := range []struct{ name, value string }{
+		{"UID", cr.UID}, {"CID", cr.CID}, {"SEID", cr.SEID},
+	} {
+		if field.value == "" {
+			return errors.Wrapf(ErrBadCookie, "missing %s", field.name)
+		}
+	}
+	parts := strings.Split(cr.UID, "_")
+	if len(parts) != 3 || !isDigits(parts[0]) || parts[1] == "" || !isAlnum(parts[1]) || !isDigits(parts[2]) {
+		return errors.Wrap(ErrBadCookie, "malformed UID")
+	}
+	for _, field := range []struct{ name, value string }{
+		{"CID", cr.CID}, {"SEID", cr.SEID}, {"KID", cr.KID},
+	} {
+		if !isAlnum(field.value) {
+			return errors.Wrapf(ErrBadCookie, "malformed %s", field.name)
+		}
+	}
+	return nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isAlnum(s string) bool {
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
 // Cookie return cookie format
 func (cr *Credential) Cookie() string {
 	return fmt.Sprintf("UID=%s;CID=%s;SEID=%s;KID=%s", cr.UID, cr.CID, cr.SEID, cr.KID)
@@ -125,10 +280,75 @@ func (cr *Credential) Cookie() string {
 
 // GetUser get user information
 func (c *Pan115Client) GetUser() (*UserInfo, error) {
+	return c.GetUserContext(context.Background())
+}
+
+// GetUserContext is like GetUser but with a context.
+func (c *Pan115Client) GetUserContext(ctx context.Context) (*UserInfo, error) {
 	result := UserInfoResp{}
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetQueryParam("_", Now().String()).
 		SetResult(&result)
 	resp, err := req.Get(ApiUserInfo)
 	return &result.UserInfo, CheckErr(err, &result, resp)
 }
+
+// userID returns the id of the logged-in user, it is fetched if no login
+// check or upload has set it yet.
+func (c *Pan115Client) userID(ctx context.Context) (int64, error) {
+	c.userMu.RLock()
+	id := c.UserID
+	c.userMu.RUnlock()
+	if id > 0 {
+		return id, nil
+	}
+	userInfo, err := c.GetUserContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	c.userMu.Lock()
+	defer c.userMu.Unlock()
+	if c.UserID <= 0 {
+		c.UserID = userInfo.UserID
+	}
+	return c.UserID, nil
+}
+
+// VIPInfo is the membership of the logged-in user.
+type VIPInfo struct {
+	// Level is the mark of the membership, 0 if not a VIP.
+	Level int
+	// Active reports whether the membership has not expired.
+	Active bool
+	// Forever reports whether it is a lifetime membership, ExpiresAt is
+	// meaningless then.
+	Forever   bool
+	ExpiresAt time.Time
+}
+
+// GetVIPInfo gets the membership of the logged-in user.
+func (c *Pan115Client) GetVIPInfo() (*VIPInfo, error) {
+	return c.GetVIPInfoContext(context.Background())
+}
+
+// GetVIPInfoContext is like GetVIPInfo but with a context.
+func (c *Pan115Client) GetVIPInfoContext(ctx context.Context) (*VIPInfo, error) {
+	user, err := c.GetUserContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newVIPInfo(user, time.Now()), nil
+}
+
+func newVIPInfo(user *UserInfo, now time.Time) *VIPInfo {
+	info := &VIPInfo{
+		Level:   user.Mark,
+		Forever: user.Forever == 1,
+	}
+	if user.Expire > 0 {
+		info.ExpiresAt = time.Unix(int64(user.Expire), 0)
+	}
+	info.Active = user.Vip > 0 && (info.Forever || info.ExpiresAt.After(now))
+	return info
+}
@@ -0,0 +1,145 @@
+package driver
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// MetricsRecorder records the requests and transfers of a client, it must be
+// safe for concurrent use.
+type MetricsRecorder interface {
+	RecordRequest(log RequestLog)
+	RecordUpload(n int64)
+	RecordDownload(n int64)
+}
+
+// Metrics is a MetricsRecorder counting in memory.
+type Metrics struct {
+	mu         sync.Mutex
+	requests   map[string]int64
+	errors     map[int]int64
+	httpErrors map[int]int64
+
+	transportErrors atomic.Int64
+	uploaded        atomic.Int64
+	downloaded      atomic.Int64
+}
+
+// MetricsSnapshot is the counters of Metrics at a moment.
+type MetricsSnapshot struct {
+	// Requests counts the requests by endpoint.
+	Requests map[string]int64
+	// Errors counts the error codes returned by 115.
+	Errors map[int]int64
+	// HTTPErrors counts the HTTP status codes not less than 400.
+	HTTPErrors map[int]int64
+	// TransportErrors counts the requests got no response.
+	TransportErrors int64
+	BytesUploaded   int64
+	BytesDownloaded int64
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests:   map[string]int64{},
+		errors:     map[int]int64{},
+		httpErrors: map[int]int64{},
+	}
+}
+
+func (m *Metrics) RecordRequest(log RequestLog) {
+	if log.StatusCode == 0 && log.Err != nil {
+		m.transportErrors.Add(1)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[log.Endpoint]++
+	if log.Code != 0 {
+		m.errors[log.Code]++
+	}
+	if log.StatusCode >= 400 {
+		m.httpErrors[log.StatusCode]++
+	}
+}
+
+func (m *Metrics) RecordUpload(n int64) {
+	m.uploaded.Add(n)
+}
+
+func (m *Metrics) RecordDownload(n int64) {
+	m.downloaded.Add(n)
+}
+
+// Snapshot returns a copy of the counters.
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := MetricsSnapshot{
+		Requests:        make(map[string]int64, len(m.requests)),
+		Errors:          make(map[int]int64, len(m.errors)),
+		HTTPErrors:      make(map[int]int64, len(m.httpErrors)),
+		TransportErrors: m.transportErrors.Load(),
+		BytesUploaded:   m.uploaded.Load(),
+		BytesDownloaded: m.downloaded.Load(),
+	}
+	for k, v := range m.requests {
+		s.Requests[k] = v
+	}
+	for k, v := range m.errors {
+		s.Errors[k] = v
+	}
+	for k, v := range m.httpErrors {
+		s.HTTPErrors[k] = v
+	}
+	return s
+}
+
+// SetMetricsRecorder sets r to record every request of the client and the
+// bytes of uploads and downloads, nil disables it.
+func (c *Pan115Client) SetMetricsRecorder(r MetricsRecorder) *Pan115Client {
+	c.metrics = r
+	c.hookRequestLogger()
+	return c
+}
+
+// Metrics returns the counters of the client if the recorder is a *Metrics,
+// see SetMetricsRecorder and WithMetrics.
+func (c *Pan115Client) Metrics() MetricsSnapshot {
+	if m, ok := c.metrics.(*Metrics); ok {
+		return m.Snapshot()
+	}
+	return MetricsSnapshot{}
+}
+
+// countUpload counts the bytes read from r as uploaded.
+func (c *Pan115Client) countUpload(r io.Reader) io.Reader {
+	if c.metrics == nil {
+		return r
+	}
+	return &countReader{r: r, add: c.metrics.RecordUpload}
+}
+
+// countDownload counts the bytes read from rc as downloaded.
+func (c *Pan115Client) countDownload(rc io.ReadCloser) io.ReadCloser {
+	if c.metrics == nil {
+		return rc
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{&countReader{r: rc, add: c.metrics.RecordDownload}, rc}
+}
+
+type countReader struct {
+	r   io.Reader
+	add func(n int64)
+}
+
+func (r *countReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.add(int64(n))
+	}
+	return n, err
+}
@@ -1,12 +1,18 @@
 package driver
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	crypto "github.com/SheltonZhu/115driver/pkg/crypto/m115"
+	"github.com/pkg/errors"
 )
 
 // OfflineTask describe an offline downloading task.
@@ -62,11 +68,17 @@ func (t *OfflineTask) GetStatus() string {
 
 // ListOfflineTask list tasks
 func (c *Pan115Client) ListOfflineTask(page int64) (OfflineTaskResp, error) {
+	return c.ListOfflineTaskContext(context.Background(), page)
+}
+
+// ListOfflineTaskContext is like ListOfflineTask but with a context.
+func (c *Pan115Client) ListOfflineTaskContext(ctx context.Context, page int64) (OfflineTaskResp, error) {
 	result := OfflineTaskResp{}
 	if isCalledByAlistV3() {
 		return result, ErrorNotSupportAlist
 	}
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetQueryParam("page", strconv.FormatInt(page, 10)).
 		SetResult(&result).
 		ForceContentType("application/json;charset=UTF-8")
@@ -79,28 +91,326 @@ func (c *Pan115Client) ListOfflineTask(page int64) (OfflineTaskResp, error) {
 	return result, nil
 }
 
+// OfflineTaskListResp is a page of offline tasks.
+type OfflineTaskListResp struct {
+	Page      int64
+	PageCount int64
+	// Total number of tasks.
+	Total int64
+	// Quota is the remaining offline download quota.
+	Quota int64
+	Tasks []*OfflineTask
+}
+
+// HasNextPage reports whether there are more tasks after this page.
+func (r *OfflineTaskListResp) HasNextPage() bool {
+	return r.Page < r.PageCount
+}
+
+// ListOfflineTasks lists offline tasks of page, which starts from 1.
+func (c *Pan115Client) ListOfflineTasks(page int) (*OfflineTaskListResp, error) {
+	return c.ListOfflineTasksContext(context.Background(), page)
+}
+
+// ListOfflineTasksContext is like ListOfflineTasks but with a context.
+func (c *Pan115Client) ListOfflineTasksContext(ctx context.Context, page int) (*OfflineTaskListResp, error) {
+	if page < 1 {
+		page = 1
+	}
+	result, err := c.ListOfflineTaskContext(ctx, int64(page))
+	if err != nil {
+		return nil, err
+	}
+	return &OfflineTaskListResp{
+		Page:      int64(page),
+		PageCount: result.PageCount,
+		Total:     result.Count,
+		Quota:     result.Quota,
+		Tasks:     result.Tasks,
+	}, nil
+}
+
+// WaitOfflineTask polls the task of infoHash every pollInterval until it is done,
+// ErrOfflineTaskFailed is returned if the task failed. The pollInterval must be
+// positive.
+func (c *Pan115Client) WaitOfflineTask(infoHash string, pollInterval time.Duration) error {
+	return c.WaitOfflineTaskContext(context.Background(), infoHash, pollInterval)
+}
+
+// WaitOfflineTaskContext is like WaitOfflineTask but with a context.
+func (c *Pan115Client) WaitOfflineTaskContext(ctx context.Context, infoHash string, pollInterval time.Duration) error {
+	_, err := c.waitOfflineTask(ctx, infoHash, pollInterval)
+	return err
+}
+
+// waitOfflineTask is like WaitOfflineTaskContext but returns the done task.
+func (c *Pan115Client) waitOfflineTask(ctx context.Context, infoHash string, pollInterval time.Duration) (*OfflineTask, error) {
+	if pollInterval <= 0 {
+		return nil, errors.Wrapf(ErrWrongParams, "poll interval %s", pollInterval)
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		task, err := c.findOfflineTask(ctx, infoHash)
+		if err != nil {
+			return nil, err
+		}
+		if task.IsDone() {
+			return task, nil
+		}
+		if task.IsFailed() {
+			return nil, errors.Wrap(ErrOfflineTaskFailed, task.Name)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// AddOfflineTaskAndMove adds an offline task by url into the default folder,
+// waits until it is done and moves the result into finalCID. The result of a
+// multi-file torrent is its directory, which is moved as a whole.
+func (c *Pan115Client) AddOfflineTaskAndMove(url, finalCID string, opts ...OfflineOption) (infoHash string, err error) {
+	return c.AddOfflineTaskAndMoveContext(context.Background(), url, finalCID, opts...)
+}
+
+// AddOfflineTaskAndMoveContext is like AddOfflineTaskAndMove but with a context.
+func (c *Pan115Client) AddOfflineTaskAndMoveContext(ctx context.Context, url, finalCID string, opts ...OfflineOption) (infoHash string, err error) {
+	results, err := c.AddOfflineTaskContext(ctx, []string{url}, "", opts...)
+	if err != nil {
+		return "", err
+	}
+	// a duplicate task is waited as well
+	if err = results[0].Err; err != nil && (!results[0].IsDuplicate() || results[0].InfoHash == "") {
+		return results[0].InfoHash, err
+	}
+	opt := DefaultOfflineOptions()
+	for _, o := range opts {
+		o(&opt)
+	}
+	infoHash = results[0].InfoHash
+	return infoHash, c.moveOfflineResult(ctx, infoHash, finalCID, opt.pollInterval)
+}
+
+// moveOfflineResult waits for the task of infoHash and moves its result into dirID.
+func (c *Pan115Client) moveOfflineResult(ctx context.Context, infoHash, dirID string, pollInterval time.Duration) error {
+	task, err := c.waitOfflineTask(ctx, infoHash, pollInterval)
+	if err != nil {
+		return err
+	}
+	// the result was added to the save directory
+	c.invalidateCache(task.DirId)
+	fileID := task.FileId
+	if fileID == "" {
+		// the result is not linked, find it by name in the save directory
+		files, err := c.listAllFiles(ctx, task.DirId)
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			if f.Name == task.Name {
+				fileID = f.FileID
+				break
+			}
+		}
+		if fileID == "" {
+			return errors.Wrapf(ErrNotExist, "result of offline task %s", infoHash)
+		}
+	}
+	if err = c.MoveContext(ctx, dirID, fileID); err != nil {
+		return err
+	}
+	c.invalidatePathIDs(fileID)
+	return nil
+}
+
+func (c *Pan115Client) findOfflineTask(ctx context.Context, infoHash string) (*OfflineTask, error) {
+	for page := 1; ; page++ {
+		result, err := c.ListOfflineTasksContext(ctx, page)
+		if err != nil {
+			return nil, err
+		}
+		for _, task := range result.Tasks {
+			if task.InfoHash == infoHash {
+				return task, nil
+			}
+		}
+		if !result.HasNextPage() || len(result.Tasks) == 0 {
+			return nil, errors.Wrapf(ErrNotExist, "offline task %s", infoHash)
+		}
+	}
+}
+
 // AddOfflineTaskURIs adds offline tasks by download URIs.
 // supports http, ed2k, magent
 func (c *Pan115Client) AddOfflineTaskURIs(uris []string, saveDirID string, opts ...OfflineOption) (hashes []string, err error) {
+	return c.AddOfflineTaskURIsContext(context.Background(), uris, saveDirID, opts...)
+}
+
+// AddOfflineTaskURIsContext is like AddOfflineTaskURIs but with a context.
+func (c *Pan115Client) AddOfflineTaskURIsContext(ctx context.Context, uris []string, saveDirID string, opts ...OfflineOption) (hashes []string, err error) {
 	if isCalledByAlistV3() {
 		return nil, ErrorNotSupportAlist
 	}
-	opt := DefaultOfflineOptions()
+	if len(uris) == 0 {
+		return
+	}
+	taskInfos, err := c.addOfflineTasks(ctx, uris, saveDirID, opts...)
+	if err != nil {
+		return nil, err
+	}
+	hashes = make([]string, len(uris))
+	for i, task := range taskInfos.Result {
+		hashes[i] = task.InfoHash
+	}
+	return hashes, nil
+}
 
-	for _, o := range opts {
-		o(&opt)
+// OfflineTaskResult is the result of adding an offline task by URL.
+type OfflineTaskResult struct {
+	URL      string
+	InfoHash string
+	Name     string
+	// Err is nil if the task is accepted, otherwise it tells why the task is
+	// rejected, e.g. ErrOfflineTaskExisted or ErrOfflineInvalidLink.
+	Err error
+}
+
+// Accepted reports whether the task is created.
+func (r *OfflineTaskResult) Accepted() bool {
+	return r.Err == nil
+}
+
+// IsDuplicate reports whether the task is rejected as it already exists.
+func (r *OfflineTaskResult) IsDuplicate() bool {
+	return errors.Is(r.Err, ErrOfflineTaskExisted)
+}
+
+// IsInvalid reports whether the task is rejected due to an invalid link.
+func (r *OfflineTaskResult) IsInvalid() bool {
+	return errors.Is(r.Err, ErrOfflineInvalidLink)
+}
+
+// AddOfflineTask adds offline tasks by http, ed2k or magnet URLs, and returns
+// the result of each URL in the same order.
+func (c *Pan115Client) AddOfflineTask(urls []string, saveDirID string, opts ...OfflineOption) ([]OfflineTaskResult, error) {
+	return c.AddOfflineTaskContext(context.Background(), urls, saveDirID, opts...)
+}
+
+// AddOfflineTaskContext is like AddOfflineTask but with a context.
+func (c *Pan115Client) AddOfflineTaskContext(ctx context.Context, urls []string, saveDirID string, opts ...OfflineOption) ([]OfflineTaskResult, error) {
+	if isCalledByAlistV3() {
+		return nil, ErrorNotSupportAlist
 	}
-	count := len(uris)
-	if count == 0 {
-		return
+	if len(urls) == 0 {
+		return nil, nil
 	}
+	taskInfos, err := c.addOfflineTasks(ctx, urls, saveDirID, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return offlineTaskResults(urls, taskInfos.Result), nil
+}
+
+// MaxOfflineBatchSize is the max number of URLs which one request of adding
+// offline tasks accepts.
+const MaxOfflineBatchSize = 15
+
+// AddOfflineTasksFromReader adds offline tasks by the URLs read from r, one URL
+// per line. Blank lines and lines starting with # are skipped and duplicate
+// URLs are added once. The URLs are added in batches of MaxOfflineBatchSize and
+// the result of each URL is returned in the order read, the URLs of a failed
+// batch have the error of the batch.
+func (c *Pan115Client) AddOfflineTasksFromReader(r io.Reader, saveCID string, opts ...OfflineOption) ([]OfflineTaskResult, error) {
+	return c.AddOfflineTasksFromReaderContext(context.Background(), r, saveCID, opts...)
+}
 
-	if c.UserID <= 0 {
-		userInfo, err := c.GetUser()
+// AddOfflineTasksFromReaderContext is like AddOfflineTasksFromReader but with a context.
+func (c *Pan115Client) AddOfflineTasksFromReaderContext(ctx context.Context, r io.Reader, saveCID string, opts ...OfflineOption) ([]OfflineTaskResult, error) {
+	if isCalledByAlistV3() {
+		return nil, ErrorNotSupportAlist
+	}
+	urls, err := readOfflineURLs(r)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]OfflineTaskResult, 0, len(urls))
+	for start := 0; start < len(urls); start += MaxOfflineBatchSize {
+		end := start + MaxOfflineBatchSize
+		if end > len(urls) {
+			end = len(urls)
+		}
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		batch := urls[start:end]
+		taskInfos, err := c.addOfflineTasks(ctx, batch, saveCID, opts...)
 		if err != nil {
-			return nil, err
+			for _, u := range batch {
+				results = append(results, OfflineTaskResult{URL: u, Err: err})
+			}
+			continue
 		}
-		c.UserID = userInfo.UserID
+		results = append(results, offlineTaskResults(batch, taskInfos.Result)...)
+	}
+	return results, nil
+}
+
+// readOfflineURLs reads the distinct URLs of r, one per line.
+func readOfflineURLs(r io.Reader) ([]string, error) {
+	var urls []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	// magnet links with many trackers are long
+	scanner.Buffer(make([]byte, 64*KB), MB)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || seen[line] {
+			continue
+		}
+		seen[line] = true
+		urls = append(urls, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+func offlineTaskResults(urls []string, tasks []OfflineTaskResponse) []OfflineTaskResult {
+	byURL := make(map[string]*OfflineTaskResponse, len(tasks))
+	for i := range tasks {
+		byURL[tasks[i].Url] = &tasks[i]
+	}
+	results := make([]OfflineTaskResult, len(urls))
+	for i, u := range urls {
+		results[i].URL = u
+		task, ok := byURL[u]
+		if !ok && i < len(tasks) {
+			task, ok = &tasks[i], true
+		}
+		if !ok {
+			results[i].Err = errors.Wrap(ErrUnexpected, "no result")
+			continue
+		}
+		results[i].InfoHash = task.InfoHash
+		results[i].Name = task.Name
+		results[i].Err = task.Err()
+	}
+	return results
+}
+
+func (c *Pan115Client) addOfflineTasks(ctx context.Context, uris []string, saveDirID string, opts ...OfflineOption) (*OfflineAddUrlResponse, error) {
+	opt := DefaultOfflineOptions()
+
+	for _, o := range opts {
+		o(&opt)
+	}
+	userID, err := c.userID(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	key := crypto.GenerateKey()
@@ -111,7 +421,7 @@ func (c *Pan115Client) AddOfflineTaskURIs(uris []string, saveDirID string, opts
 		"ac":         "add_task_urls",
 		"wp_path_id": saveDirID,
 		"app_ver":    opt.appVer,
-		"uid":        strconv.FormatInt(c.UserID, 10),
+		"uid":        strconv.FormatInt(userID, 10),
 	}
 	for i, uri := range uris {
 		key := fmt.Sprintf("url[%d]", i)
@@ -124,6 +434,7 @@ func (c *Pan115Client) AddOfflineTaskURIs(uris []string, saveDirID string, opts
 
 	data := crypto.Encode(paramsBytes, key)
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetQueryParam("t", Now().String()).
 		SetFormData(map[string]string{"data": data}).
 		ForceContentType("application/json").
@@ -144,16 +455,66 @@ func (c *Pan115Client) AddOfflineTaskURIs(uris []string, saveDirID string, opts
 	if err := json.Unmarshal(bytes, &taskInfos); err != nil {
 		return nil, err
 	}
+	return &taskInfos, nil
+}
 
-	hashes = make([]string, count)
-	for i, task := range taskInfos.Result {
-		hashes[i] = task.InfoHash
+// OfflineSaveDirResp is the response of the default save directory of offline tasks.
+type OfflineSaveDirResp struct {
+	BasicResp
+	Data []struct {
+		ID       string `json:"id"`
+		FileID   string `json:"file_id"`
+		FileName string `json:"file_name"`
+	} `json:"data"`
+}
+
+// GetOfflineSaveDir gets the id of the directory which offline tasks are saved
+// into when no save directory is given.
+func (c *Pan115Client) GetOfflineSaveDir() (cid string, err error) {
+	return c.GetOfflineSaveDirContext(context.Background())
+}
+
+// GetOfflineSaveDirContext is like GetOfflineSaveDir but with a context.
+func (c *Pan115Client) GetOfflineSaveDirContext(ctx context.Context) (cid string, err error) {
+	result := OfflineSaveDirResp{}
+	req := c.NewRequest().
+		SetContext(ctx).
+		ForceContentType("application/json;charset=UTF-8").
+		SetResult(&result)
+	resp, err := req.Get(ApiOfflineSaveDir)
+	if err = CheckErr(err, &result, resp); err != nil {
+		return "", err
 	}
-	return hashes, nil
+	if len(result.Data) == 0 {
+		return "", errors.Wrap(ErrNotExist, "offline save directory")
+	}
+	return result.Data[0].FileID, nil
+}
+
+// SetOfflineSaveDir sets the directory of cid as the default save directory of offline tasks.
+func (c *Pan115Client) SetOfflineSaveDir(cid string) error {
+	return c.SetOfflineSaveDirContext(context.Background(), cid)
+}
+
+// SetOfflineSaveDirContext is like SetOfflineSaveDir but with a context.
+func (c *Pan115Client) SetOfflineSaveDirContext(ctx context.Context, cid string) error {
+	result := BasicResp{}
+	req := c.NewRequest().
+		SetContext(ctx).
+		SetFormData(map[string]string{"file_id": cid}).
+		ForceContentType("application/json;charset=UTF-8").
+		SetResult(&result)
+	resp, err := req.Post(ApiOfflineSaveDir)
+	return CheckErr(err, &result, resp)
 }
 
 // DeleteOfflineTasks deletes tasks.
 func (c *Pan115Client) DeleteOfflineTasks(hashes []string, deleteFiles bool) error {
+	return c.DeleteOfflineTasksContext(context.Background(), hashes, deleteFiles)
+}
+
+// DeleteOfflineTasksContext is like DeleteOfflineTasks but with a context.
+func (c *Pan115Client) DeleteOfflineTasksContext(ctx context.Context, hashes []string, deleteFiles bool) error {
 	if isCalledByAlistV3() {
 		return ErrorNotSupportAlist
 	}
@@ -169,6 +530,7 @@ func (c *Pan115Client) DeleteOfflineTasks(hashes []string, deleteFiles bool) err
 
 	result := MkdirResp{}
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetFormDataFromValues(form).
 		SetResult(&result).
 		ForceContentType("application/json;charset=UTF-8")
@@ -177,13 +539,31 @@ func (c *Pan115Client) DeleteOfflineTasks(hashes []string, deleteFiles bool) err
 	return CheckErr(err, &result, resp)
 }
 
-// ClearOfflineTasks deletes tasks.
+// Clear flags of ClearOfflineTasks.
+const (
+	OfflineClearDone int64 = iota
+	OfflineClearAll
+	OfflineClearFailed
+	OfflineClearRunning
+	// OfflineClearDoneAndFiles also deletes the downloaded files.
+	OfflineClearDoneAndFiles
+	// OfflineClearAllAndFiles also deletes the downloaded files.
+	OfflineClearAllAndFiles
+)
+
+// ClearOfflineTasks deletes tasks by clearFlag, see OfflineClearDone and others.
 func (c *Pan115Client) ClearOfflineTasks(clearFlag int64) error {
+	return c.ClearOfflineTasksContext(context.Background(), clearFlag)
+}
+
+// ClearOfflineTasksContext is like ClearOfflineTasks but with a context.
+func (c *Pan115Client) ClearOfflineTasksContext(ctx context.Context, clearFlag int64) error {
 	form := url.Values{}
 	form.Set("flag", strconv.FormatInt(int64(clearFlag), 10))
 
 	result := MkdirResp{}
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetFormDataFromValues(form).
 		SetResult(&result).
 		ForceContentType("application/json;charset=UTF-8")
@@ -197,8 +577,24 @@ type OfflineAddUrlResponse struct {
 	Result []OfflineTaskResponse `json:"result"`
 }
 type OfflineTaskResponse struct {
-	InfoHash string `json:"info_hash"`
-	Url      string `json:"url"`
+	State    bool      `json:"state"`
+	Errno    StringInt `json:"errno"`
+	ErrCode  StringInt `json:"errcode"`
+	ErrorMsg string    `json:"error_msg"`
+	InfoHash string    `json:"info_hash"`
+	Name     string    `json:"name"`
+	Url      string    `json:"url"`
+}
+
+func (resp *OfflineTaskResponse) Err() error {
+	if resp.State {
+		return nil
+	}
+	err := GetErr(findNonZero(int(resp.ErrCode), int(resp.Errno)))
+	if resp.ErrorMsg != "" {
+		return errors.Wrap(err, resp.ErrorMsg)
+	}
+	return err
 }
 
 type OfflineTaskResp struct {
@@ -1,16 +1,31 @@
 package driver
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
 )
 
-// Delete delete files or directory from file ids
+// Delete delete files or directory from file ids, they are moved to the
+// recycle bin and can be restored, see DeletePermanent.
 func (c *Pan115Client) Delete(fileIDs ...string) error {
+	return c.DeleteContext(context.Background(), fileIDs...)
+}
+
+// DeleteContext is like Delete but with a context.
+func (c *Pan115Client) DeleteContext(ctx context.Context, fileIDs ...string) error {
 	if len(fileIDs) == 0 {
 		return nil
 	}
+	if c.plan(PlannedOp{Op: PlannedOpDelete, FileIDs: fileIDs}) {
+		return nil
+	}
 	form := map[string]string{}
 	for i, value := range fileIDs {
 		key := fmt.Sprintf("%s[%d]", "fid", i)
@@ -19,18 +34,189 @@ func (c *Pan115Client) Delete(fileIDs ...string) error {
 
 	result := BasicResp{}
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetFormData(form).
 		ForceContentType("application/json;charset=UTF-8").
 		SetResult(&result)
 	resp, err := req.Post(ApiFileDelete)
-	return CheckErr(err, &result, resp)
+	if err = CheckErr(err, &result, resp); err != nil {
+		return err
+	}
+	c.invalidateCache(fileIDs...)
+	return nil
+}
+
+// MaxBatchSize is the max number of files which one batch request accepts.
+const MaxBatchSize = 1150
+
+// DeleteFiles deletes files or directories under parentCID into the recycle bin
+// in batches, a *BatchError listing the failed ids is returned if some batches failed.
+func (c *Pan115Client) DeleteFiles(parentCID string, fileIDs ...string) error {
+	return c.DeleteFilesContext(context.Background(), parentCID, fileIDs...)
+}
+
+// DeleteFilesContext is like DeleteFiles but with a context.
+func (c *Pan115Client) DeleteFilesContext(ctx context.Context, parentCID string, fileIDs ...string) error {
+	if len(fileIDs) > 0 && c.plan(PlannedOp{Op: PlannedOpDelete, DirID: parentCID, FileIDs: fileIDs}) {
+		return nil
+	}
+	batchErr := &BatchError{Total: len(fileIDs)}
+	for start := 0; start < len(fileIDs); start += MaxBatchSize {
+		end := start + MaxBatchSize
+		if end > len(fileIDs) {
+			end = len(fileIDs)
+		}
+		batch := fileIDs[start:end]
+		form := map[string]string{
+			"pid":         parentCID,
+			"ignore_warn": "1",
+		}
+		for i, value := range batch {
+			form[fmt.Sprintf("%s[%d]", "fid", i)] = value
+		}
+
+		result := BasicResp{}
+		req := c.NewRequest().
+			SetContext(ctx).
+			SetFormData(form).
+			ForceContentType("application/json;charset=UTF-8").
+			SetResult(&result)
+		resp, err := req.Post(ApiFileDelete)
+		if err = CheckErr(err, &result, resp); err != nil {
+			batchErr.add(err, batch...)
+			continue
+		}
+		c.invalidateCache(batch...)
+		c.invalidateCache(parentCID)
+	}
+	return batchErr.orNil()
+}
+
+// DeletePermanent deletes files or directories under parentCID and purges them
+// from the recycle bin, they can not be restored. It fails if the recycle bin is
+// protected by a password, use DeleteFiles and CleanRecycleBin instead.
+func (c *Pan115Client) DeletePermanent(parentCID string, fileIDs ...string) error {
+	return c.DeletePermanentContext(context.Background(), parentCID, fileIDs...)
+}
+
+// DeletePermanentContext is like DeletePermanent but with a context.
+func (c *Pan115Client) DeletePermanentContext(ctx context.Context, parentCID string, fileIDs ...string) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	if c.plan(PlannedOp{Op: PlannedOpDeletePermanent, DirID: parentCID, FileIDs: fileIDs}) {
+		return nil
+	}
+	deleteErr := c.DeleteFilesContext(ctx, parentCID, fileIDs...)
+	deleted := fileIDs
+	var batchErr *BatchError
+	if errors.As(deleteErr, &batchErr) {
+		failed := make(map[string]bool, len(batchErr.Failed))
+		for _, id := range batchErr.Failed {
+			failed[id] = true
+		}
+		deleted = nil
+		for _, id := range fileIDs {
+			if !failed[id] {
+				deleted = append(deleted, id)
+			}
+		}
+	} else if deleteErr != nil {
+		return deleteErr
+	}
+	// the recycle bin item has the same id as the deleted file
+	for start := 0; start < len(deleted); start += MaxBatchSize {
+		end := start + MaxBatchSize
+		if end > len(deleted) {
+			end = len(deleted)
+		}
+		if err := c.CleanRecycleBinContext(ctx, "", deleted[start:end]...); err != nil {
+			return errors.Wrap(err, "files are deleted into the recycle bin but not purged")
+		}
+	}
+	c.invalidatePathIDs(deleted...)
+	return deleteErr
+}
+
+// EmptyDir deletes all files and directories in cid and keeps cid itself, the
+// contents of subdirectories go with them. The items are purged from the recycle
+// bin if permanent is set, see DeletePermanent. It returns the number of deleted
+// items, a *BatchError lists the failed ids.
+func (c *Pan115Client) EmptyDir(cid string, permanent bool) (int, error) {
+	return c.EmptyDirContext(context.Background(), cid, permanent)
+}
+
+// EmptyDirContext is like EmptyDir but with a context.
+func (c *Pan115Client) EmptyDirContext(ctx context.Context, cid string, permanent bool) (int, error) {
+	children, err := c.listAllFiles(ctx, cid)
+	if err != nil {
+		return 0, err
+	}
+	ids := make([]string, len(children))
+	for i, f := range children {
+		ids[i] = f.FileID
+	}
+	if permanent {
+		err = c.DeletePermanentContext(ctx, cid, ids...)
+	} else {
+		err = c.DeleteFilesContext(ctx, cid, ids...)
+		c.invalidatePathIDs(ids...)
+	}
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		return len(ids) - len(batchErr.Failed), err
+	} else if err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// NoOpPolicy is how Move and Rename handle a file already in the target
+// directory or already having the new name.
+type NoOpPolicy int
+
+const (
+	// NoOpSend sends no-ops to 115 as other operations, 115 may fail or
+	// succeed on them.
+	NoOpSend NoOpPolicy = iota
+	// NoOpSkip skips no-ops and succeeds.
+	NoOpSkip
+	// NoOpError skips no-ops and fails with ErrSameParent or ErrSameName.
+	NoOpError
+)
+
+// SetNoOpPolicy sets how Move and Rename handle no-ops, the default is NoOpSend.
+// Other policies get the parent or name of each file first.
+func (c *Pan115Client) SetNoOpPolicy(p NoOpPolicy) *Pan115Client {
+	c.noOpPolicy = p
+	return c
 }
 
 // Rename rename a file or directory with file id and name
 func (c *Pan115Client) Rename(fileID, newName string) error {
+	return c.RenameContext(context.Background(), fileID, newName)
+}
+
+// RenameContext is like Rename but with a context.
+func (c *Pan115Client) RenameContext(ctx context.Context, fileID, newName string) error {
 	if isCalledByAlistV3() {
 		return ErrorNotSupportAlist
 	}
+	if c.noOpPolicy != NoOpSend {
+		f, err := c.GetFileContext(ctx, fileID)
+		if err != nil {
+			return err
+		}
+		if f.Name == newName {
+			if c.noOpPolicy == NoOpError {
+				return errors.Wrapf(ErrSameName, "%s is named %s", fileID, newName)
+			}
+			return nil
+		}
+	}
+	if c.plan(PlannedOp{Op: PlannedOpRename, FileIDs: []string{fileID}, NewName: newName}) {
+		return nil
+	}
 	form := map[string]string{
 		"fid":       fileID,
 		"file_name": newName,
@@ -39,21 +225,211 @@ func (c *Pan115Client) Rename(fileID, newName string) error {
 
 	result := BasicResp{}
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetFormData(form).
 		ForceContentType("application/json;charset=UTF-8").
 		SetResult(&result)
 	resp, err := req.Post(ApiFileRename)
-	return CheckErr(err, &result, resp)
+	if err = CheckErr(err, &result, resp); err != nil {
+		return err
+	}
+	c.forgetDirNodes(fileID)
+	c.invalidateCache(fileID)
+	return nil
+}
+
+// StarFile stars or unstars a file or directory.
+func (c *Pan115Client) StarFile(fileID string, starred bool) error {
+	return c.StarFileContext(context.Background(), fileID, starred)
+}
+
+// StarFileContext is like StarFile but with a context.
+func (c *Pan115Client) StarFileContext(ctx context.Context, fileID string, starred bool) error {
+	star := "0"
+	if starred {
+		star = "1"
+	}
+	result := BasicResp{}
+	req := c.NewRequest().
+		SetContext(ctx).
+		SetFormData(map[string]string{"file_id": fileID, "star": star}).
+		ForceContentType("application/json;charset=UTF-8").
+		SetResult(&result)
+	resp, err := req.Post(ApiFileStar)
+	if err = CheckErr(err, &result, resp); err != nil {
+		return err
+	}
+	c.invalidateCache(fileID)
+	return nil
+}
+
+// SetFileTime sets the modification time of a file or directory, which is
+// the UpdateTime when listed.
+func (c *Pan115Client) SetFileTime(fileID string, modTime time.Time) error {
+	return c.SetFileTimeContext(context.Background(), fileID, modTime)
+}
+
+// SetFileTimeContext is like SetFileTime but with a context.
+func (c *Pan115Client) SetFileTimeContext(ctx context.Context, fileID string, modTime time.Time) error {
+	result := BasicResp{}
+	req := c.NewRequest().
+		SetContext(ctx).
+		SetFormData(map[string]string{
+			"file_id":    fileID,
+			"user_utime": strconv.FormatInt(modTime.Unix(), 10),
+		}).
+		ForceContentType("application/json;charset=UTF-8").
+		SetResult(&result)
+	resp, err := req.Post(ApiFileUpdate)
+	if err = CheckErr(err, &result, resp); err != nil {
+		return err
+	}
+	c.invalidateCache(fileID)
+	return nil
+}
+
+// ListStarred lists starred files and directories of page, which starts from 1.
+func (c *Pan115Client) ListStarred(page int) (*FileListResp, error) {
+	return c.ListStarredContext(context.Background(), page)
+}
+
+// ListStarredContext is like ListStarred but with a context.
+func (c *Pan115Client) ListStarredContext(ctx context.Context, page int) (*FileListResp, error) {
+	if page < 1 {
+		page = 1
+	}
+	req := c.NewRequest().SetContext(ctx).ForceContentType("application/json;charset=UTF-8")
+	return GetFiles(req, "0",
+		WithStar(true),
+		WithLimit(FileListLimit),
+		WithOffset(int64(page-1)*FileListLimit),
+	)
+}
+
+// RenameBatch renames files or directories by a map of file id to new name in
+// batches. Invalid names are not sent, a *BatchError lists the failed ids.
+func (c *Pan115Client) RenameBatch(renames map[string]string) error {
+	return c.RenameBatchContext(context.Background(), renames)
+}
+
+// RenameBatchContext is like RenameBatch but with a context.
+func (c *Pan115Client) RenameBatchContext(ctx context.Context, renames map[string]string) error {
+	if isCalledByAlistV3() {
+		return ErrorNotSupportAlist
+	}
+	batchErr := &BatchError{Total: len(renames)}
+	fileIDs := make([]string, 0, len(renames))
+	for fileID, name := range renames {
+		if err := ValidateFileName(name); err != nil {
+			batchErr.add(err, fileID)
+			continue
+		}
+		fileIDs = append(fileIDs, fileID)
+	}
+	sort.Strings(fileIDs)
+	if c.DryRun() {
+		for _, fileID := range fileIDs {
+			c.plan(PlannedOp{Op: PlannedOpRename, FileIDs: []string{fileID}, NewName: renames[fileID]})
+		}
+		return batchErr.orNil()
+	}
+
+	for start := 0; start < len(fileIDs); start += MaxBatchSize {
+		end := start + MaxBatchSize
+		if end > len(fileIDs) {
+			end = len(fileIDs)
+		}
+		batch := fileIDs[start:end]
+		form := map[string]string{}
+		for _, fileID := range batch {
+			form[fmt.Sprintf("files_new_name[%s]", fileID)] = renames[fileID]
+		}
+
+		result := BasicResp{}
+		req := c.NewRequest().
+			SetContext(ctx).
+			SetFormData(form).
+			ForceContentType("application/json;charset=UTF-8").
+			SetResult(&result)
+		resp, err := req.Post(ApiFileRename)
+		if err = CheckErr(err, &result, resp); err != nil {
+			batchErr.add(err, batch...)
+			continue
+		}
+		c.invalidateCache(batch...)
+	}
+	return batchErr.orNil()
+}
+
+// ValidateFileName checks name against the rules of 115, which rejects empty
+// names, names longer than 255 characters, names ending with a dot and
+// characters \ / : * ? " < > |.
+func ValidateFileName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.Wrap(ErrInvalidFileName, "empty name")
+	}
+	if utf8.RuneCountInString(name) > 255 {
+		return errors.Wrapf(ErrInvalidFileName, "%q is longer than 255 characters", name)
+	}
+	if i := strings.IndexAny(name, `\/:*?"<>|`); i >= 0 {
+		return errors.Wrapf(ErrInvalidFileName, "%q contains %q", name, name[i])
+	}
+	if strings.HasSuffix(name, ".") {
+		return errors.Wrapf(ErrInvalidFileName, "%q ends with a dot", name)
+	}
+	return nil
 }
 
 // Move move files or directory into another directory with directroy id
 func (c *Pan115Client) Move(dirID string, fileIDs ...string) error {
+	return c.MoveContext(context.Background(), dirID, fileIDs...)
+}
+
+// MoveContext is like Move but with a context.
+func (c *Pan115Client) MoveContext(ctx context.Context, dirID string, fileIDs ...string) error {
 	if isCalledByAlistV3() {
 		return ErrorNotSupportAlist
 	}
+	var noOps []string
+	if c.noOpPolicy != NoOpSend {
+		var err error
+		if fileIDs, noOps, err = c.splitByParent(ctx, dirID, fileIDs); err != nil {
+			return err
+		}
+	}
+	if err := c.move(ctx, dirID, fileIDs...); err != nil {
+		return err
+	}
+	if len(noOps) > 0 && c.noOpPolicy == NoOpError {
+		return errors.Wrapf(ErrSameParent, "%s in %s", strings.Join(noOps, ","), dirID)
+	}
+	return nil
+}
+
+// splitByParent splits fileIDs into the ones to move into dirID and the ones
+// already in it.
+func (c *Pan115Client) splitByParent(ctx context.Context, dirID string, fileIDs []string) (moves, noOps []string, err error) {
+	for _, id := range fileIDs {
+		var f *File
+		if f, err = c.GetFileContext(ctx, id); err != nil {
+			return nil, nil, err
+		}
+		if f.ParentID == dirID {
+			noOps = append(noOps, id)
+		} else {
+			moves = append(moves, id)
+		}
+	}
+	return moves, noOps, nil
+}
+
+func (c *Pan115Client) move(ctx context.Context, dirID string, fileIDs ...string) error {
 	if len(fileIDs) == 0 {
 		return nil
 	}
+	if c.plan(PlannedOp{Op: PlannedOpMove, DirID: dirID, FileIDs: fileIDs}) {
+		return nil
+	}
 	form := map[string]string{
 		"pid": dirID,
 	}
@@ -63,15 +439,57 @@ func (c *Pan115Client) Move(dirID string, fileIDs ...string) error {
 	}
 	result := BasicResp{}
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetFormData(form).
 		ForceContentType("application/json;charset=UTF-8").
 		SetResult(&result)
 	resp, err := req.Post(ApiFileMove)
-	return CheckErr(err, &result, resp)
+	if err = CheckErr(err, &result, resp); err != nil {
+		return err
+	}
+	c.forgetDirNodes(fileIDs...)
+	c.invalidateCache(fileIDs...)
+	c.invalidateCache(dirID)
+	return nil
+}
+
+// MoveByPath moves files or directories into the directory at destPath.
+func (c *Pan115Client) MoveByPath(destPath string, fileIDs []string, opts ...MoveByPathOption) error {
+	return c.MoveByPathContext(context.Background(), destPath, fileIDs, opts...)
+}
+
+// MoveByPathContext is like MoveByPath but with a context.
+func (c *Pan115Client) MoveByPathContext(ctx context.Context, destPath string, fileIDs []string, opts ...MoveByPathOption) error {
+	o := DefaultMoveByPathOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	var (
+		dirID string
+		err   error
+	)
+	if o.CreateMissing {
+		dirID, err = c.mkdirAll(ctx, destPath)
+	} else {
+		dirID, err = c.dirCID(ctx, cleanPath(destPath))
+	}
+	if err != nil {
+		return err
+	}
+	if err = c.MoveContext(ctx, dirID, fileIDs...); err != nil {
+		return err
+	}
+	c.invalidatePathIDs(fileIDs...)
+	return nil
 }
 
 // Copy copy files or directory into another directory with directroy id
 func (c *Pan115Client) Copy(dirID string, fileIDs ...string) error {
+	return c.CopyContext(context.Background(), dirID, fileIDs...)
+}
+
+// CopyContext is like Copy but with a context.
+func (c *Pan115Client) CopyContext(ctx context.Context, dirID string, fileIDs ...string) error {
 	if isCalledByAlistV3() {
 		return ErrorNotSupportAlist
 	}
@@ -87,11 +505,273 @@ func (c *Pan115Client) Copy(dirID string, fileIDs ...string) error {
 	}
 	result := BasicResp{}
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetFormData(form).
 		ForceContentType("application/json;charset=UTF-8").
 		SetResult(&result)
 	resp, err := req.Post(ApiFileCopy)
-	return CheckErr(err, &result, resp)
+	if err = CheckErr(err, &result, resp); err != nil {
+		return err
+	}
+	c.invalidateCache(dirID)
+	return nil
+}
+
+// CopyFiles copies files or directories into dirID like Copy, and returns the
+// created copies in the order of fileIDs. 115 renames a copy on name conflict,
+// unless CopyWithOverwrite is set, in which case the conflicting items in
+// dirID are deleted once the copy succeeds and the copies take their names.
+// The sources themselves are never overwritten, copying into their own parent
+// makes renamed copies.
+func (c *Pan115Client) CopyFiles(dirID string, fileIDs []string, opts ...CopyOption) ([]File, error) {
+	return c.CopyFilesContext(context.Background(), dirID, fileIDs, opts...)
+}
+
+// CopyFilesContext is like CopyFiles but with a context.
+func (c *Pan115Client) CopyFilesContext(ctx context.Context, dirID string, fileIDs []string, opts ...CopyOption) ([]File, error) {
+	o := DefaultCopyOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	if len(fileIDs) == 0 {
+		return nil, nil
+	}
+	sources := make([]*File, len(fileIDs))
+	names := make(map[string]bool, len(fileIDs))
+	isSource := make(map[string]bool, len(fileIDs))
+	for i, fileID := range fileIDs {
+		f, err := c.GetFileContext(ctx, fileID)
+		if err != nil {
+			return nil, err
+		}
+		sources[i] = f
+		names[f.Name] = true
+		isSource[fileID] = true
+	}
+
+	existing, err := c.listAllFiles(ctx, dirID)
+	if err != nil {
+		return nil, err
+	}
+	before := make(map[string]bool, len(existing))
+	var conflicts []string
+	replaced := make(map[string]bool)
+	for _, f := range existing {
+		before[f.FileID] = true
+		if o.Overwrite && names[f.Name] && !isSource[f.FileID] {
+			conflicts = append(conflicts, f.FileID)
+			replaced[f.Name] = true
+		}
+	}
+	if err = c.CopyContext(ctx, dirID, fileIDs...); err != nil {
+		return nil, err
+	}
+
+	after, err := c.listAllFiles(ctx, dirID)
+	if err != nil {
+		return nil, err
+	}
+	var created []File
+	for _, f := range after {
+		if !before[f.FileID] {
+			created = append(created, f)
+		}
+	}
+	copies := make([]File, len(sources))
+	used := make([]bool, len(created))
+	for i, src := range sources {
+		j := matchCopy(src, created, used)
+		if j < 0 {
+			return nil, errors.Wrapf(ErrNotExist, "copy of %s", src.Name)
+		}
+		used[j] = true
+		copies[i] = created[j]
+	}
+	if len(conflicts) == 0 {
+		return copies, nil
+	}
+	// the old items go only after the copies exist, which 115 has renamed
+	if err = c.DeleteContext(ctx, conflicts...); err != nil {
+		return nil, err
+	}
+	for i, src := range sources {
+		if copies[i].Name == src.Name || !replaced[src.Name] {
+			continue
+		}
+		if err = c.RenameContext(ctx, copies[i].FileID, src.Name); err != nil {
+			return nil, err
+		}
+		copies[i].Name = src.Name
+	}
+	return copies, nil
+}
+
+// matchCopy finds the copy of src in created by name, or by SHA1 and size
+// if it is renamed.
+func matchCopy(src *File, created []File, used []bool) int {
+	for j := range created {
+		if !used[j] && created[j].IsDirectory == src.IsDirectory && created[j].Name == src.Name {
+			return j
+		}
+	}
+	for j := range created {
+		if !used[j] && !src.IsDirectory && src.Sha1 != "" &&
+			strings.EqualFold(created[j].Sha1, src.Sha1) && created[j].Size == src.Size {
+			return j
+		}
+	}
+	// a renamed directory keeps the name as prefix, e.g. "name(1)"
+	for j := range created {
+		if !used[j] && src.IsDirectory && created[j].IsDirectory && strings.HasPrefix(created[j].Name, src.Name) {
+			return j
+		}
+	}
+	return -1
+}
+
+// CopyTree copies the directory srcCID with all its content into destCID by
+// the recursive copy of 115 and waits until all files are copied, the copy is
+// returned. Canceling ctx stops waiting, 115 keeps copying in background.
+func (c *Pan115Client) CopyTree(srcCID, destCID string, opts ...CopyTreeOption) (*File, error) {
+	return c.CopyTreeContext(context.Background(), srcCID, destCID, opts...)
+}
+
+// CopyTreeContext is like CopyTree but with a context.
+func (c *Pan115Client) CopyTreeContext(ctx context.Context, srcCID, destCID string, opts ...CopyTreeOption) (*File, error) {
+	o := DefaultCopyTreeOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	src, err := c.GetDirInfoContext(ctx, srcCID)
+	if err != nil {
+		return nil, err
+	}
+	copies, err := c.CopyFilesContext(ctx, destCID, []string{srcCID}, CopyWithOverwrite(o.Overwrite))
+	if err != nil {
+		return nil, err
+	}
+	dst := &copies[0]
+	if err = c.waitCopied(ctx, dst.FileID, src.FileCount, o); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// waitCopied polls the directory dirID until it has total files, or fails with
+// ErrCopyStalled once StallPolls polls in a row see no new file.
+func (c *Pan115Client) waitCopied(ctx context.Context, dirID string, total int, o *CopyTreeOptions) error {
+	ticker := time.NewTicker(o.PollInterval)
+	defer ticker.Stop()
+	reported, stalled := -1, 0
+	for {
+		c.dirInfos.Delete(dirID)
+		info, err := c.GetDirInfoContext(ctx, dirID)
+		if err != nil {
+			return err
+		}
+		if info.FileCount > reported {
+			reported, stalled = info.FileCount, 0
+			if o.OnProgress != nil {
+				o.OnProgress(info.FileCount, total)
+			}
+		} else if stalled++; o.StallPolls > 0 && stalled >= o.StallPolls {
+			return errors.Wrapf(ErrCopyStalled, "%d of %d files in %s", info.FileCount, total, dirID)
+		}
+		if info.FileCount >= total {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// SnapshotDir creates the directory name under destParentCID, and copies the
+// content of srcCID into it by the recursive copy of 115, then waits like
+// CopyTree. If name exists in destParentCID, name (1), name (2) and so on is
+// used instead. The id of the new directory is returned.
+//
+// If a copy request fails, the new directory is deleted into the recycle bin
+// and no id is returned. If the wait fails, like on ErrCopyStalled or when ctx
+// is canceled, the id is returned along with the error and the directory is
+// left in place, as 115 may still be copying into it.
+func (c *Pan115Client) SnapshotDir(srcCID, destParentCID, name string, opts ...CopyTreeOption) (string, error) {
+	return c.SnapshotDirContext(context.Background(), srcCID, destParentCID, name, opts...)
+}
+
+// SnapshotDirContext is like SnapshotDir but with a context.
+func (c *Pan115Client) SnapshotDirContext(ctx context.Context, srcCID, destParentCID, name string, opts ...CopyTreeOption) (string, error) {
+	o := DefaultCopyTreeOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	src, err := c.GetDirInfoContext(ctx, srcCID)
+	if err != nil {
+		return "", err
+	}
+	children, err := c.listAllFiles(ctx, srcCID)
+	if err != nil {
+		return "", err
+	}
+	cid, err := c.MkdirContext(ctx, destParentCID, name)
+	if errors.Is(err, ErrExist) {
+		if name, err = c.freeDirName(ctx, destParentCID, name); err != nil {
+			return "", err
+		}
+		cid, err = c.MkdirContext(ctx, destParentCID, name)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	ids := make([]string, len(children))
+	for i, f := range children {
+		ids[i] = f.FileID
+	}
+	for start := 0; start < len(ids); start += MaxBatchSize {
+		end := start + MaxBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		if err = c.CopyContext(ctx, cid, ids[start:end]...); err != nil {
+			// ctx may be done, the partial snapshot is removed anyway
+			if delErr := c.DeleteContext(context.Background(), cid); delErr != nil {
+				return cid, errors.Wrapf(err, "partial snapshot %s is left: %v", cid, delErr)
+			}
+			return "", err
+		}
+	}
+	return cid, c.waitCopied(ctx, cid, src.FileCount, o)
+}
+
+// freeDirName returns name with the smallest numeric suffix not used in dirID,
+// unlike freeFileName the extension is not kept apart.
+func (c *Pan115Client) freeDirName(ctx context.Context, dirID, name string) (string, error) {
+	files, err := c.listAllFiles(ctx, dirID)
+	if err != nil {
+		return "", err
+	}
+	used := make(map[string]bool, len(files))
+	for _, f := range files {
+		used[f.Name] = true
+	}
+	for i := 1; ; i++ {
+		if n := fmt.Sprintf("%s (%d)", name, i); !used[n] {
+			return n, nil
+		}
+	}
+}
+
+// listAllFiles lists all files and directories of dirID.
+func (c *Pan115Client) listAllFiles(ctx context.Context, dirID string) ([]File, error) {
+	var files []File
+	it := c.ListIteratorContext(ctx, dirID, WithLimit(MaxDirPageLimit))
+	for f, ok := it.Next(); ok; f, ok = it.Next() {
+		files = append(files, *f)
+	}
+	return files, it.Err()
 }
 
 type FileStatInfo struct {
@@ -129,8 +809,22 @@ type DirInfo struct {
 
 // Stat get statistic information of a file or directory
 func (c *Pan115Client) Stat(fileID string) (*FileStatInfo, error) {
+	return c.StatContext(context.Background(), fileID)
+}
+
+// StatContext is like Stat but with a context.
+func (c *Pan115Client) StatContext(ctx context.Context, fileID string) (*FileStatInfo, error) {
+	rc := c.respCache.Load()
+	if rc != nil {
+		if v, ok := rc.get("stat:" + fileID); ok {
+			info := *v.(*FileStatInfo)
+			info.Parents = append([]*DirInfo(nil), info.Parents...)
+			return &info, nil
+		}
+	}
 	result := FileStatResponse{}
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetQueryParam("cid", fileID).
 		ForceContentType("application/json;charset=UTF-8").
 		SetResult(&result)
@@ -160,13 +854,28 @@ func (c *Pan115Client) Stat(fileID string) (*FileStatInfo, error) {
 		info.FileCount = int(result.FileCount)
 		info.DirCount = int(result.FolderCount)
 	}
+	if rc != nil {
+		ids := []string{fileID}
+		for _, p := range info.Parents {
+			ids = append(ids, p.ID)
+		}
+		cached := *info
+		cached.Parents = append([]*DirInfo(nil), info.Parents...)
+		rc.put("stat:"+fileID, &cached, ids...)
+	}
 	return info, nil
 }
 
 // GetFile gets information of a file or directory by its ID.
 func (c *Pan115Client) GetFile(fileID string) (*File, error) {
+	return c.GetFileContext(context.Background(), fileID)
+}
+
+// GetFileContext is like GetFile but with a context.
+func (c *Pan115Client) GetFileContext(ctx context.Context, fileID string) (*File, error) {
 	result := GetFileInfoResponse{}
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetQueryParam("file_id", fileID).
 		ForceContentType("application/json;charset=UTF-8").
 		SetResult(&result)
@@ -174,11 +883,159 @@ func (c *Pan115Client) GetFile(fileID string) (*File, error) {
 	if err := CheckErr(err, &result, resp); err != nil {
 		return nil, err
 	}
-	fileInfo := &FileInfo{}
-	if len(result.Files) > 0 {
-		fileInfo = result.Files[0]
+	if len(result.Files) == 0 {
+		return nil, errors.Wrapf(ErrNotExist, "file %s", fileID)
 	}
 	f := &File{}
-	f.from(fileInfo)
+	f.from(result.Files[0])
 	return f, nil
 }
+
+// GetFileInfo is an alias of GetFile, it returns ErrNotExist if fileID does not exist.
+func (c *Pan115Client) GetFileInfo(fileID string) (*File, error) {
+	return c.GetFileContext(context.Background(), fileID)
+}
+
+// GetFileInfoContext is like GetFileInfo but with a context.
+func (c *Pan115Client) GetFileInfoContext(ctx context.Context, fileID string) (*File, error) {
+	return c.GetFileContext(ctx, fileID)
+}
+
+// FileIDToPickCode gets the pickcode of the file or directory of fileID.
+func (c *Pan115Client) FileIDToPickCode(fileID string) (string, error) {
+	return c.FileIDToPickCodeContext(context.Background(), fileID)
+}
+
+// FileIDToPickCodeContext is like FileIDToPickCode but with a context.
+func (c *Pan115Client) FileIDToPickCodeContext(ctx context.Context, fileID string) (string, error) {
+	f, err := c.GetFileContext(ctx, fileID)
+	if err != nil {
+		return "", err
+	}
+	if f.PickCode == "" {
+		return "", errors.Wrapf(ErrPickCodeIsEmpty, "file %s", fileID)
+	}
+	return f.PickCode, nil
+}
+
+// PickCodeToFile gets the file of pickCode, it does not work for directories
+// as the file id is got by the download API.
+func (c *Pan115Client) PickCodeToFile(pickCode string) (*File, error) {
+	return c.PickCodeToFileContext(context.Background(), pickCode)
+}
+
+// PickCodeToFileContext is like PickCodeToFile but with a context.
+func (c *Pan115Client) PickCodeToFileContext(ctx context.Context, pickCode string) (*File, error) {
+	if pickCode == "" {
+		return nil, ErrPickCodeIsEmpty
+	}
+	info, err := c.DownloadWithUAContext(ctx, pickCode, "")
+	if err != nil {
+		return nil, err
+	}
+	return c.GetFileContext(ctx, info.FileID)
+}
+
+// MaxPickCodeBatchSize is the max number of pickcodes GetFilesByPickCodes
+// looks up in one request.
+const MaxPickCodeBatchSize = 100
+
+// GetFilesByPickCodes gets the files of pickCodes in batches, the result is in
+// the order of pickCodes and is nil for those which do not exist. Like
+// PickCodeToFile, it does not work for directories.
+func (c *Pan115Client) GetFilesByPickCodes(pickCodes []string) ([]*File, error) {
+	return c.GetFilesByPickCodesContext(context.Background(), pickCodes)
+}
+
+// GetFilesByPickCodesContext is like GetFilesByPickCodes but with a context.
+func (c *Pan115Client) GetFilesByPickCodesContext(ctx context.Context, pickCodes []string) ([]*File, error) {
+	fileIDs := make(map[string]string, len(pickCodes))
+	for start := 0; start < len(pickCodes); start += MaxPickCodeBatchSize {
+		end := start + MaxPickCodeBatchSize
+		if end > len(pickCodes) {
+			end = len(pickCodes)
+		}
+		if err := c.pickCodesToFileIDs(ctx, pickCodes[start:end], fileIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	ids := make([]string, 0, len(fileIDs))
+	for _, id := range fileIDs {
+		ids = append(ids, id)
+	}
+	files, err := c.getFilesByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*File, len(pickCodes))
+	for i, pickCode := range pickCodes {
+		if id, ok := fileIDs[pickCode]; ok {
+			result[i] = files[id]
+		}
+	}
+	return result, nil
+}
+
+// pickCodesToFileIDs puts the file ids of pickCodes into fileIDs, a batch which
+// fails for a missing pickcode is retried one by one to skip it.
+func (c *Pan115Client) pickCodesToFileIDs(ctx context.Context, pickCodes []string, fileIDs map[string]string) error {
+	var codes []string
+	for _, pickCode := range pickCodes {
+		if pickCode != "" {
+			codes = append(codes, pickCode)
+		}
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	data, err := c.downloadData(ctx, strings.Join(codes, ","), "")
+	if IsNotFound(err) && len(codes) > 1 {
+		for _, pickCode := range codes {
+			if err := c.pickCodesToFileIDs(ctx, []string{pickCode}, fileIDs); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for fileID, info := range data {
+		if info.FileSize >= 0 && info.PickCode != "" {
+			fileIDs[info.PickCode] = fileID
+		}
+	}
+	return nil
+}
+
+// getFilesByIDs gets the files of fileIDs in batches keyed by their ids, the
+// missing ones are left out.
+func (c *Pan115Client) getFilesByIDs(ctx context.Context, fileIDs []string) (map[string]*File, error) {
+	files := make(map[string]*File, len(fileIDs))
+	for start := 0; start < len(fileIDs); start += MaxPickCodeBatchSize {
+		end := start + MaxPickCodeBatchSize
+		if end > len(fileIDs) {
+			end = len(fileIDs)
+		}
+		result := GetFileInfoResponse{}
+		req := c.NewRequest().
+			SetContext(ctx).
+			SetQueryParam("file_id", strings.Join(fileIDs[start:end], ",")).
+			ForceContentType("application/json;charset=UTF-8").
+			SetResult(&result)
+		resp, err := req.Get(ApiFileInfo)
+		if err := CheckErr(err, &result, resp); err != nil {
+			return nil, err
+		}
+		for _, info := range result.Files {
+			f := &File{}
+			f.from(info)
+			files[f.FileID] = f
+		}
+	}
+	return files, nil
+}
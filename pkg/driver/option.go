@@ -2,6 +2,8 @@ package driver
 
 import (
 	"crypto/tls"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
@@ -31,6 +33,13 @@ func WithClient(hc *http.Client) Option {
 func WithRestyClient(resty *resty.Client) Option {
 	return func(c *Pan115Client) {
 		c.Client = resty
+		c.customTransport = true
+	}
+}
+
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Pan115Client) {
+		c.SetTransport(transport)
 	}
 }
 
@@ -52,6 +61,46 @@ func WithProxy(proxy string) Option {
 	}
 }
 
+func WithCredentialRefresher(fn CredentialRefresher) Option {
+	return func(c *Pan115Client) {
+		c.SetCredentialRefresher(fn)
+	}
+}
+
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Pan115Client) {
+		c.SetRetryPolicy(p)
+	}
+}
+
+// WithPathCache sets the size and TTL of the path cache used by StatByPath.
+func WithPathCache(size int, ttl time.Duration) Option {
+	return func(c *Pan115Client) {
+		c.SetPathCache(size, ttl)
+	}
+}
+
+// WithMetrics counts the requests and transfers of the client, see Metrics.
+func WithMetrics() Option {
+	return func(c *Pan115Client) {
+		c.SetMetricsRecorder(NewMetrics())
+	}
+}
+
+// WithNoOpPolicy sets how Move and Rename handle no-ops, see SetNoOpPolicy.
+func WithNoOpPolicy(p NoOpPolicy) Option {
+	return func(c *Pan115Client) {
+		c.SetNoOpPolicy(p)
+	}
+}
+
+// WithDryRun turns on the dry run mode, see SetDryRun.
+func WithDryRun() Option {
+	return func(c *Pan115Client) {
+		c.SetDryRun(true)
+	}
+}
+
 func InsecureSkipVerify(insecureSkipVerify bool) Option {
 	return func(c *Pan115Client) {
 		c.Client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: insecureSkipVerify})
@@ -63,10 +112,28 @@ const (
 	FileOrderByType = "file_type"
 	FileOrderBySize = "file_size"
 	FileOrderByName = "file_name"
+	// FileOrderByUpdateTime sorts by the modification time, it works with
+	// Search only.
+	FileOrderByUpdateTime = "user_utime"
+	// FileOrderByNone sends no order so that 115 lists in its raw order.
+	FileOrderByNone = ""
 
 	FileListLimit = int64(56)
 )
 
+// FileType is the category of file content.
+type FileType int
+
+const (
+	FileTypeAll FileType = iota
+	FileTypeDoc
+	FileTypeImage
+	FileTypeAudio
+	FileTypeVideo
+	FileTypeArchive
+	FileTypeApp
+)
+
 // GetFileOption get file options
 type GetFileOption struct {
 	order    string
@@ -74,7 +141,10 @@ type GetFileOption struct {
 	pageSize int64
 	offset   int64
 	showDir  string
+	natsort  string
 	apiURL   string
+	star     string
+	fileType FileType
 }
 
 type GetFileOptions func(o *GetFileOption)
@@ -114,13 +184,40 @@ func WithShowDirEnable(e bool) GetFileOptions {
 
 func WithAsc(d bool) GetFileOptions {
 	return func(o *GetFileOption) {
-		o.showDir = "0"
+		o.asc = "0"
 		if d {
-			o.showDir = "1"
+			o.asc = "1"
+		}
+	}
+}
+
+// WithNatsort enables natural sort, only works with ApiFileListByName.
+func WithNatsort(e bool) GetFileOptions {
+	return func(o *GetFileOption) {
+		o.natsort = "0"
+		if e {
+			o.natsort = "1"
 		}
 	}
 }
 
+// WithStar lists only starred files and directories if e is true.
+func WithStar(e bool) GetFileOptions {
+	return func(o *GetFileOption) {
+		o.star = ""
+		if e {
+			o.star = "1"
+		}
+	}
+}
+
+// WithFileType lists only files of t, FileTypeAll lists all.
+func WithFileType(t FileType) GetFileOptions {
+	return func(o *GetFileOption) {
+		o.fileType = t
+	}
+}
+
 func (o *GetFileOption) GetApiURL() string {
 	return o.apiURL
 }
@@ -145,6 +242,18 @@ func (o *GetFileOption) GetshowDir() string {
 	return o.showDir
 }
 
+func (o *GetFileOption) GetNatsort() string {
+	return o.natsort
+}
+
+func (o *GetFileOption) GetStar() string {
+	return o.star
+}
+
+func (o *GetFileOption) GetFileType() FileType {
+	return o.fileType
+}
+
 func DefaultGetFileOptions() *GetFileOption {
 	return &GetFileOption{
 		order:    FileOrderByTime,
@@ -152,22 +261,47 @@ func DefaultGetFileOptions() *GetFileOption {
 		pageSize: int64(56),
 		offset:   int64(0),
 		showDir:  "1",
+		natsort:  "0",
 		apiURL:   ApiFileList,
 	}
 }
 
 type UploadMultipartOptions struct {
+	// Deprecated: ThreadsNum is ignored, use PartConcurrency.
 	ThreadsNum       int
 	Timeout          time.Duration
 	TokenRefreshTime time.Duration
+	// TokenRefreshMargin refreshes the OSS token before a part is uploaded if
+	// the token expires within it.
+	TokenRefreshMargin time.Duration
+	// OnConflict is what to do when a file of the same name exists.
+	OnConflict ConflictPolicy
+	// PartConcurrency is the number of parts uploaded at once, only as many
+	// parts are buffered. OSS does not compute the SHA1 of parts uploaded out
+	// of order, so more than 1 initiates the upload without oss.Sequential.
+	PartConcurrency int
+	// PartSize is the size of each part but the last, 0 means ComputePartSize.
+	// It must stay the same when the upload is resumed.
+	PartSize int64
+	// ModTime is set as the modification time of the uploaded file if not
+	// zero, 115 takes no time at upload so it is set by SetFileTime after.
+	ModTime time.Time
+	// Resume loads and saves the ResumeState of the upload.
+	Resume io.ReadWriter
+	// OnProgress is called after each uploaded part, at most every
+	// ProgressEvery bytes. Resumed parts are counted but not reported.
+	OnProgress    ProgressFunc
+	ProgressEvery int64
 }
 
 func DefalutUploadMultipartOptions() *UploadMultipartOptions {
 	return &UploadMultipartOptions{
 		// oss 启用Sequential必须按顺序上传
-		ThreadsNum:       1,
-		Timeout:          time.Hour * 24,
-		TokenRefreshTime: time.Minute * 50,
+		ThreadsNum:         1,
+		PartConcurrency:    1,
+		Timeout:            time.Hour * 24,
+		TokenRefreshTime:   time.Minute * 50,
+		TokenRefreshMargin: time.Minute * 5,
 	}
 }
 
@@ -191,13 +325,101 @@ func UploadMultipartWithTokenRefreshTime(refreshTime time.Duration) UploadMultip
 	}
 }
 
+// UploadMultipartWithOnConflict sets what to do when a file of the same name exists.
+func UploadMultipartWithOnConflict(p ConflictPolicy) UploadMultipartOption {
+	return func(o *UploadMultipartOptions) {
+		o.OnConflict = p
+	}
+}
+
+// UploadMultipartWithPartConcurrency uploads n parts at once.
+func UploadMultipartWithPartConcurrency(n int) UploadMultipartOption {
+	return func(o *UploadMultipartOptions) {
+		o.PartConcurrency = n
+	}
+}
+
+// UploadMultipartWithPartSize sets the size of each part, it must be within
+// [MinPartSize, MaxPartSize] and make at most MaxPartCount parts.
+func UploadMultipartWithPartSize(size int64) UploadMultipartOption {
+	return func(o *UploadMultipartOptions) {
+		o.PartSize = size
+	}
+}
+
+// UploadMultipartWithModTime sets the modification time of the uploaded file.
+func UploadMultipartWithModTime(t time.Time) UploadMultipartOption {
+	return func(o *UploadMultipartOptions) {
+		o.ModTime = t
+	}
+}
+
+// UploadMultipartWithTokenRefreshMargin refreshes the OSS token when it expires
+// within margin, 0 disables it.
+func UploadMultipartWithTokenRefreshMargin(margin time.Duration) UploadMultipartOption {
+	return func(o *UploadMultipartOptions) {
+		o.TokenRefreshMargin = margin
+	}
+}
+
+// UploadMultipartWithResume resumes the upload from the state saved in rw,
+// and keeps saving the progress to it.
+func UploadMultipartWithResume(rw io.ReadWriter) UploadMultipartOption {
+	return func(o *UploadMultipartOptions) {
+		o.Resume = rw
+	}
+}
+
 type ListOptions struct {
 	ApiURLs []string
+	// OrderBy is one of FileOrderByTime, FileOrderByType, FileOrderBySize,
+	// FileOrderByName and FileOrderByNone.
+	OrderBy   string
+	Ascending bool
+	// NaturalSort sorts by name naturally, it uses ApiFileListByName and
+	// ignores OrderBy. It is off by default, and natsort is sent as 0 then.
+	NaturalSort bool
+	ShowDir     bool
+	// FileType filters the files by the server, directories are excluded
+	// unless it is FileTypeAll.
+	FileType FileType
+	// Limit and Offset are the page of ListWithOptions.
+	Limit  int64
+	Offset int64
+	// ShowHidden turns on the hidden mode before listing so that hidden files
+	// are included, see SetHiddenPassword.
+	ShowHidden bool
 }
 
 func DefaultListOptions() *ListOptions {
 	return &ListOptions{
-		ApiURLs: []string{ApiFileList},
+		ApiURLs:   []string{ApiFileList},
+		OrderBy:   FileOrderByTime,
+		Ascending: true,
+		ShowDir:   true,
+		Limit:     FileListLimit,
+	}
+}
+
+// getFileOptions returns the GetFileOptions of ordering, apiURL is used unless
+// NaturalSort is set.
+func (o *ListOptions) getFileOptions(apiURL string) []GetFileOptions {
+	if o.NaturalSort {
+		return []GetFileOptions{
+			WithApiURL(ApiFileListByName),
+			WithOrder(FileOrderByName),
+			WithAsc(o.Ascending),
+			WithNatsort(true),
+			WithShowDirEnable(o.ShowDir),
+			WithFileType(o.FileType),
+		}
+	}
+	return []GetFileOptions{
+		WithApiURL(apiURL),
+		WithOrder(o.OrderBy),
+		WithAsc(o.Ascending),
+		WithShowDirEnable(o.ShowDir),
+		WithFileType(o.FileType),
 	}
 }
 
@@ -211,6 +433,49 @@ func WithApiURLs(urls ...string) ListOption {
 	}
 }
 
+// ListWithOrder sorts the listing by orderBy, like FileOrderByTime.
+func ListWithOrder(orderBy string, ascending bool) ListOption {
+	return func(o *ListOptions) {
+		o.OrderBy = orderBy
+		o.Ascending = ascending
+	}
+}
+
+// ListWithShowHidden includes hidden files in the listing.
+func ListWithShowHidden(e bool) ListOption {
+	return func(o *ListOptions) {
+		o.ShowHidden = e
+	}
+}
+
+// ListWithNaturalSort sorts the listing by name naturally.
+func ListWithNaturalSort(e bool) ListOption {
+	return func(o *ListOptions) {
+		o.NaturalSort = e
+	}
+}
+
+func ListWithShowDir(e bool) ListOption {
+	return func(o *ListOptions) {
+		o.ShowDir = e
+	}
+}
+
+// ListWithFileType lists only files of t, like FileTypeVideo.
+func ListWithFileType(t FileType) ListOption {
+	return func(o *ListOptions) {
+		o.FileType = t
+	}
+}
+
+// ListWithPage sets the page of ListWithOptions.
+func ListWithPage(offset, limit int64) ListOption {
+	return func(o *ListOptions) {
+		o.Offset = offset
+		o.Limit = limit
+	}
+}
+
 func WithMultiUrls() ListOption {
 	return WithApiURLs([]string{
 		ApiFileList,
@@ -221,12 +486,23 @@ func WithMultiUrls() ListOption {
 }
 
 type OfflineOptions struct {
-	appVer string
+	appVer       string
+	pollInterval time.Duration
 }
 
 func DefaultOfflineOptions() OfflineOptions {
 	return OfflineOptions{
-		appVer: appVer,
+		appVer:       appVer,
+		pollInterval: 5 * time.Second,
+	}
+}
+
+// UploadMultipartWithProgress reports the upload progress to fn at most every
+// n bytes, 0 means after each part.
+func UploadMultipartWithProgress(fn ProgressFunc, every int64) UploadMultipartOption {
+	return func(o *UploadMultipartOptions) {
+		o.OnProgress = fn
+		o.ProgressEvery = every
 	}
 }
 
@@ -237,3 +513,369 @@ func WithAppVer(appVer string) OfflineOption {
 		o.appVer = appVer
 	}
 }
+
+// WithPollInterval sets how often AddOfflineTaskAndMove polls the task, a
+// d <= 0 is ignored.
+func WithPollInterval(d time.Duration) OfflineOption {
+	return func(o *OfflineOptions) {
+		if d > 0 {
+			o.pollInterval = d
+		}
+	}
+}
+
+type DownloadOptions struct {
+	UserAgent string
+	// RangeStart and RangeEnd are the inclusive byte range to download,
+	// a negative RangeEnd means to the end of file.
+	RangeStart int64
+	RangeEnd   int64
+	// OnProgress is called after each written chunk, at most every
+	// ProgressEvery bytes.
+	OnProgress    ProgressFunc
+	ProgressEvery int64
+	// AutoRefreshURL fetches the download URL again when the CDN answers 403,
+	// and resumes a broken body by range.
+	AutoRefreshURL bool
+}
+
+func DefaultDownloadOptions() *DownloadOptions {
+	return &DownloadOptions{
+		RangeStart:     0,
+		RangeEnd:       -1,
+		AutoRefreshURL: true,
+	}
+}
+
+type DownloadOption func(o *DownloadOptions)
+
+func DownloadWithUserAgent(ua string) DownloadOption {
+	return func(o *DownloadOptions) {
+		o.UserAgent = ua
+	}
+}
+
+func DownloadWithRange(start, end int64) DownloadOption {
+	return func(o *DownloadOptions) {
+		o.RangeStart = start
+		o.RangeEnd = end
+	}
+}
+
+// DownloadWithAutoRefreshURL sets whether the expired download URL is fetched
+// again, it is on by default.
+func DownloadWithAutoRefreshURL(refresh bool) DownloadOption {
+	return func(o *DownloadOptions) {
+		o.AutoRefreshURL = refresh
+	}
+}
+
+// DownloadWithProgress reports the download progress to fn at most every n bytes.
+func DownloadWithProgress(fn ProgressFunc, every int64) DownloadOption {
+	return func(o *DownloadOptions) {
+		o.OnProgress = fn
+		o.ProgressEvery = every
+	}
+}
+
+// HasRange reports whether only part of the file is requested.
+func (o *DownloadOptions) HasRange() bool {
+	return o.RangeStart > 0 || o.RangeEnd >= 0
+}
+
+// GetRange returns the value of Range header.
+func (o *DownloadOptions) GetRange() string {
+	if o.RangeEnd < 0 {
+		return fmt.Sprintf("bytes=%d-", o.RangeStart)
+	}
+	return fmt.Sprintf("bytes=%d-%d", o.RangeStart, o.RangeEnd)
+}
+
+type ParallelOptions struct {
+	DownloadOptions
+	// Connections is the number of concurrent range requests.
+	Connections int
+	// ChunkSize is the size of each range request.
+	ChunkSize int64
+}
+
+func DefaultParallelOptions() *ParallelOptions {
+	return &ParallelOptions{
+		DownloadOptions: *DefaultDownloadOptions(),
+		Connections:     4,
+		ChunkSize:       8 * MB,
+	}
+}
+
+type ParallelOption func(o *ParallelOptions)
+
+func ParallelWithConnections(n int) ParallelOption {
+	return func(o *ParallelOptions) {
+		o.Connections = n
+	}
+}
+
+func ParallelWithChunkSize(size int64) ParallelOption {
+	return func(o *ParallelOptions) {
+		o.ChunkSize = size
+	}
+}
+
+func ParallelWithUserAgent(ua string) ParallelOption {
+	return func(o *ParallelOptions) {
+		o.UserAgent = ua
+	}
+}
+
+// ParallelWithProgress reports the aggregate progress to fn at most every n bytes.
+func ParallelWithProgress(fn ProgressFunc, every int64) ParallelOption {
+	return func(o *ParallelOptions) {
+		o.OnProgress = fn
+		o.ProgressEvery = every
+	}
+}
+
+type BatchDownloadOptions struct {
+	DownloadOptions
+	// Concurrency is the number of files downloaded at the same time.
+	Concurrency int
+}
+
+func DefaultBatchDownloadOptions() *BatchDownloadOptions {
+	return &BatchDownloadOptions{
+		DownloadOptions: *DefaultDownloadOptions(),
+		Concurrency:     4,
+	}
+}
+
+type BatchDownloadOption func(o *BatchDownloadOptions)
+
+func BatchDownloadWithConcurrency(n int) BatchDownloadOption {
+	return func(o *BatchDownloadOptions) {
+		o.Concurrency = n
+	}
+}
+
+func BatchDownloadWithUserAgent(ua string) BatchDownloadOption {
+	return func(o *BatchDownloadOptions) {
+		o.UserAgent = ua
+	}
+}
+
+type UploadDirOptions struct {
+	// Concurrency is the number of files uploaded at the same time.
+	Concurrency int
+	// Ignore are the patterns of path.Match, a file or directory is skipped if
+	// its slash-separated path relative to the local directory or its name
+	// matches one.
+	Ignore []string
+	// SkipExisting skips a file if the one of the same name in the destination
+	// has the same SHA1.
+	SkipExisting bool
+	// UploadOptions are passed to UploadFile for each file.
+	UploadOptions []UploadMultipartOption
+}
+
+func DefaultUploadDirOptions() *UploadDirOptions {
+	return &UploadDirOptions{
+		Concurrency:  4,
+		SkipExisting: true,
+	}
+}
+
+type UploadDirOption func(o *UploadDirOptions)
+
+func UploadDirWithConcurrency(n int) UploadDirOption {
+	return func(o *UploadDirOptions) {
+		o.Concurrency = n
+	}
+}
+
+// UploadDirWithIgnore skips the files and directories matching patterns.
+func UploadDirWithIgnore(patterns ...string) UploadDirOption {
+	return func(o *UploadDirOptions) {
+		o.Ignore = append(o.Ignore, patterns...)
+	}
+}
+
+func UploadDirWithSkipExisting(b bool) UploadDirOption {
+	return func(o *UploadDirOptions) {
+		o.SkipExisting = b
+	}
+}
+
+// UploadDirWithUploadOptions sets the options of UploadFile for each file.
+func UploadDirWithUploadOptions(opts ...UploadMultipartOption) UploadDirOption {
+	return func(o *UploadDirOptions) {
+		o.UploadOptions = opts
+	}
+}
+
+type SearchOptions struct {
+	// CID limits the search in a directory, "0" means all.
+	CID      string
+	FileType FileType
+	Offset   int64
+	Limit    int64
+	// OrderBy sorts the result like FileOrderByUpdateTime, empty means by
+	// relevance.
+	OrderBy   string
+	Ascending bool
+}
+
+func DefaultSearchOptions() *SearchOptions {
+	return &SearchOptions{
+		CID:    "0",
+		Offset: 0,
+		Limit:  FileListLimit,
+	}
+}
+
+type SearchOption func(o *SearchOptions)
+
+func SearchWithCID(cid string) SearchOption {
+	return func(o *SearchOptions) {
+		o.CID = cid
+	}
+}
+
+func SearchWithFileType(t FileType) SearchOption {
+	return func(o *SearchOptions) {
+		o.FileType = t
+	}
+}
+
+func SearchWithOffset(offset int64) SearchOption {
+	return func(o *SearchOptions) {
+		o.Offset = offset
+	}
+}
+
+func SearchWithLimit(limit int64) SearchOption {
+	return func(o *SearchOptions) {
+		o.Limit = limit
+	}
+}
+
+// SearchWithOrder sorts the result by orderBy, like FileOrderByUpdateTime.
+func SearchWithOrder(orderBy string, ascending bool) SearchOption {
+	return func(o *SearchOptions) {
+		o.OrderBy = orderBy
+		o.Ascending = ascending
+	}
+}
+
+type MoveByPathOptions struct {
+	// CreateMissing creates the missing directories of the destination path,
+	// otherwise ErrNotExist is returned.
+	CreateMissing bool
+}
+
+func DefaultMoveByPathOptions() *MoveByPathOptions {
+	return &MoveByPathOptions{}
+}
+
+type MoveByPathOption func(o *MoveByPathOptions)
+
+func MoveByPathWithCreateMissing(b bool) MoveByPathOption {
+	return func(o *MoveByPathOptions) {
+		o.CreateMissing = b
+	}
+}
+
+// ShareDuration is the valid days of a share.
+type ShareDuration int
+
+const (
+	ShareDurationForever ShareDuration = -1
+	ShareDuration1Day    ShareDuration = 1
+	ShareDuration7Days   ShareDuration = 7
+)
+
+type ShareOptions struct {
+	Duration ShareDuration
+	// ReceiveCode is the access password, a random one is generated if empty.
+	ReceiveCode string
+}
+
+func DefaultShareOptions() *ShareOptions {
+	return &ShareOptions{
+		Duration: ShareDuration7Days,
+	}
+}
+
+type ShareOption func(o *ShareOptions)
+
+func ShareWithDuration(d ShareDuration) ShareOption {
+	return func(o *ShareOptions) {
+		o.Duration = d
+	}
+}
+
+func ShareWithReceiveCode(code string) ShareOption {
+	return func(o *ShareOptions) {
+		o.ReceiveCode = code
+	}
+}
+
+type CopyOptions struct {
+	// Overwrite deletes the items with the same names in the destination
+	// before copying, instead of letting 115 rename the copies.
+	Overwrite bool
+}
+
+func DefaultCopyOptions() *CopyOptions {
+	return &CopyOptions{}
+}
+
+type CopyOption func(o *CopyOptions)
+
+func CopyWithOverwrite(b bool) CopyOption {
+	return func(o *CopyOptions) {
+		o.Overwrite = b
+	}
+}
+
+type CopyTreeOptions struct {
+	CopyOptions
+	// OnProgress is called with the number of copied files and the total
+	// whenever the number grows.
+	OnProgress func(copied, total int)
+	// PollInterval is how often the copy is checked until it completes.
+	PollInterval time.Duration
+	// StallPolls is how many polls in a row may see no new file before the
+	// wait fails with ErrCopyStalled, 0 waits forever.
+	StallPolls int
+}
+
+func DefaultCopyTreeOptions() *CopyTreeOptions {
+	return &CopyTreeOptions{PollInterval: 2 * time.Second, StallPolls: 30}
+}
+
+type CopyTreeOption func(o *CopyTreeOptions)
+
+func CopyTreeWithOverwrite(b bool) CopyTreeOption {
+	return func(o *CopyTreeOptions) {
+		o.Overwrite = b
+	}
+}
+
+func CopyTreeWithProgress(fn func(copied, total int)) CopyTreeOption {
+	return func(o *CopyTreeOptions) {
+		o.OnProgress = fn
+	}
+}
+
+func CopyTreeWithPollInterval(d time.Duration) CopyTreeOption {
+	return func(o *CopyTreeOptions) {
+		o.PollInterval = d
+	}
+}
+
+// CopyTreeWithStallPolls sets StallPolls, the wait gives up after n polls
+// without progress.
+func CopyTreeWithStallPolls(n int) CopyTreeOption {
+	return func(o *CopyTreeOptions) {
+		o.StallPolls = n
+	}
+}
@@ -0,0 +1,81 @@
+package driver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/pkg/errors"
+)
+
+// ossSession holds the OSS token of an upload and the bucket signed with it.
+// The token is a STS credential which expires in an hour, so long uploads
+// refresh it and rebuild the bucket.
+type ossSession struct {
+	c       *Pan115Client
+	ctx     context.Context
+	name    string
+	margin  time.Duration
+	options []oss.ClientOption
+
+	mu     sync.Mutex
+	token  *UploadOSSTokenResp
+	bucket *oss.Bucket
+}
+
+func (c *Pan115Client) newOSSSession(ctx context.Context, bucketName string, margin time.Duration, options ...oss.ClientOption) (*ossSession, error) {
+	s := &ossSession{c: c, ctx: ctx, name: bucketName, margin: margin, options: options}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refreshLocked(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// get returns the bucket and its token, the token is refreshed first if it
+// expires within the margin.
+func (s *ossSession) get() (*oss.Bucket, *UploadOSSTokenResp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.margin > 0 && !s.token.Expiration.IsZero() && time.Until(s.token.Expiration) < s.margin {
+		if err := s.refreshLocked(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return s.bucket, s.token, nil
+}
+
+// refresh requests a new token from 115.
+func (s *ossSession) refresh() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.refreshLocked()
+}
+
+func (s *ossSession) refreshLocked() error {
+	token, err := s.c.GetOSSTokenContext(s.ctx)
+	if err != nil {
+		return errors.Wrap(err, "刷新token时出现错误")
+	}
+	client, err := s.c.newOSSClient(s.ctx, token, s.options...)
+	if err != nil {
+		return err
+	}
+	bucket, err := client.Bucket(s.name)
+	if err != nil {
+		return err
+	}
+	s.token, s.bucket = token, bucket
+	return nil
+}
+
+// isOSSTokenExpired reports whether err is caused by an expired OSS token.
+func isOSSTokenExpired(err error) bool {
+	var se oss.ServiceError
+	if errors.As(err, &se) {
+		return se.Code == "SecurityTokenExpired" || se.Code == "InvalidAccessKeyId"
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package driver
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// DownloadParallel downloads the file of pickCode to destPath by concurrent
+// range requests, it falls back to a single stream if the server does not
+// support range requests. The SHA1 is verified like DownloadToFile.
+func (c *Pan115Client) DownloadParallel(pickCode, destPath string, opts ...ParallelOption) error {
+	return c.DownloadParallelContext(context.Background(), pickCode, destPath, opts...)
+}
+
+// DownloadParallelContext is like DownloadParallel but with a context.
+func (c *Pan115Client) DownloadParallelContext(ctx context.Context, pickCode, destPath string, opts ...ParallelOption) error {
+	o := DefaultParallelOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	info, err := c.DownloadWithUAContext(ctx, pickCode, o.UserAgent)
+	if err != nil {
+		return err
+	}
+	var sha1 string
+	if info.FileID != "" {
+		file, err := c.GetFileContext(ctx, info.FileID)
+		if err != nil {
+			return err
+		}
+		sha1 = file.Sha1
+	}
+	return c.downloadParallel(ctx, info, sha1, destPath, o)
+}
+
+func (c *Pan115Client) downloadParallel(ctx context.Context, info *DownloadInfo, sha1, destPath string, o *ParallelOptions) error {
+	size := int64(info.FileSize)
+	if o.Connections <= 1 || o.ChunkSize <= 0 || size <= o.ChunkSize {
+		return c.downloadToFile(ctx, info, sha1, destPath, &o.DownloadOptions)
+	}
+
+	partPath := destPath + ".part"
+	f, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	err = c.downloadChunks(ctx, info, f, o)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if errors.Is(err, ErrRangeNotSupported) {
+		_ = os.Remove(partPath)
+		return c.downloadToFile(ctx, info, sha1, destPath, &o.DownloadOptions)
+	}
+	if err != nil {
+		return err
+	}
+
+	if sha1 != "" {
+		sum, err := fileSHA1(partPath)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(sum, sha1) {
+			_ = os.Remove(partPath)
+			return errors.Wrapf(ErrChecksumMismatch, "expected %s, got %s", sha1, sum)
+		}
+	}
+	return os.Rename(partPath, destPath)
+}
+
+// downloadChunks downloads ranges of info concurrently and writes them into f at their offsets.
+func (c *Pan115Client) downloadChunks(ctx context.Context, info *DownloadInfo, f io.WriterAt, o *ParallelOptions) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	size := int64(info.FileSize)
+	prog := newProgress(o.OnProgress, size, o.ProgressEvery)
+	starts := make(chan int64)
+	go func() {
+		defer close(starts)
+		for start := int64(0); start < size; start += o.ChunkSize {
+			select {
+			case starts <- start:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i := 0; i < o.Connections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range starts {
+				end := start + o.ChunkSize - 1
+				if end >= size {
+					end = size - 1
+				}
+				if err := c.downloadChunk(ctx, info, f, start, end, o, prog); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
+
+func (c *Pan115Client) downloadChunk(ctx context.Context, info *DownloadInfo, f io.WriterAt, start, end int64, o *ParallelOptions, prog *progress) error {
+	ro := o.DownloadOptions
+	ro.RangeStart, ro.RangeEnd = start, end
+	body, _, err := c.downloadStream(ctx, info, &ro)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	var w io.Writer = io.NewOffsetWriter(f, start)
+	if prog != nil {
+		w = io.MultiWriter(w, prog)
+	}
+	n, err := io.Copy(w, body)
+	if err != nil {
+		return err
+	}
+	if n != end-start+1 {
+		return errors.Wrapf(ErrUnexpected, "range %d-%d got %d bytes", start, end, n)
+	}
+	return nil
+}
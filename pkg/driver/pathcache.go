@@ -0,0 +1,227 @@
+package driver
+
+import (
+	"container/list"
+	"context"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultPathCacheSize = 1024
+	DefaultPathCacheTTL  = time.Minute
+)
+
+// pathCache is a LRU cache of path lookups with expiration.
+type pathCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	ll      *list.List
+	entries map[string]*list.Element
+}
+
+type pathEntry struct {
+	path    string
+	cid     string
+	file    *File
+	expires time.Time
+}
+
+func newPathCache(size int, ttl time.Duration) *pathCache {
+	return &pathCache{
+		size:    size,
+		ttl:     ttl,
+		ll:      list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (pc *pathCache) get(p string) (*pathEntry, bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	el, ok := pc.entries[p]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*pathEntry)
+	if pc.ttl > 0 && time.Now().After(e.expires) {
+		pc.ll.Remove(el)
+		delete(pc.entries, p)
+		return nil, false
+	}
+	pc.ll.MoveToFront(el)
+	return e, true
+}
+
+func (pc *pathCache) put(p, cid string, file *File) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	e := &pathEntry{path: p, cid: cid, file: file, expires: time.Now().Add(pc.ttl)}
+	if el, ok := pc.entries[p]; ok {
+		el.Value = e
+		pc.ll.MoveToFront(el)
+		return
+	}
+	pc.entries[p] = pc.ll.PushFront(e)
+	for pc.ll.Len() > pc.size {
+		last := pc.ll.Back()
+		pc.ll.Remove(last)
+		delete(pc.entries, last.Value.(*pathEntry).path)
+	}
+}
+
+// remove drops p and everything under it.
+func (pc *pathCache) remove(p string) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	prefix := strings.TrimSuffix(p, "/") + "/"
+	for key, el := range pc.entries {
+		if key == p || strings.HasPrefix(key, prefix) {
+			pc.ll.Remove(el)
+			delete(pc.entries, key)
+		}
+	}
+}
+
+// removeIDs drops the paths of ids and everything under them.
+func (pc *pathCache) removeIDs(ids ...string) {
+	set := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	var paths []string
+	pc.mu.Lock()
+	for key, el := range pc.entries {
+		if _, ok := set[el.Value.(*pathEntry).cid]; ok {
+			paths = append(paths, key)
+		}
+	}
+	pc.mu.Unlock()
+	for _, p := range paths {
+		pc.remove(p)
+	}
+}
+
+// SetPathCache configures the cache used by StatByPath, a size <= 0 disables it.
+func (c *Pan115Client) SetPathCache(size int, ttl time.Duration) *Pan115Client {
+	c.pathCacheMu.Lock()
+	defer c.pathCacheMu.Unlock()
+	if size <= 0 {
+		c.pathCache = nil
+		c.pathCacheOff = true
+		return c
+	}
+	c.pathCache = newPathCache(size, ttl)
+	c.pathCacheOff = false
+	return c
+}
+
+func (c *Pan115Client) getPathCache() *pathCache {
+	c.pathCacheMu.Lock()
+	defer c.pathCacheMu.Unlock()
+	if c.pathCache == nil && !c.pathCacheOff {
+		c.pathCache = newPathCache(DefaultPathCacheSize, DefaultPathCacheTTL)
+	}
+	return c.pathCache
+}
+
+// InvalidatePathCache drops the cached lookups of p and all paths under it,
+// call it after renaming, moving or deleting by path.
+func (c *Pan115Client) InvalidatePathCache(p string) {
+	if pc := c.getPathCache(); pc != nil {
+		pc.remove(cleanPath(p))
+	}
+}
+
+func (c *Pan115Client) invalidatePathIDs(ids ...string) {
+	c.forgetDirNodes(ids...)
+	if pc := c.getPathCache(); pc != nil {
+		pc.removeIDs(ids...)
+	}
+}
+
+// StatByPath gets the file or directory at the absolute path p, IsDirectory
+// tells which one it is. An error wrapping ErrNotExist is returned if p or one
+// of its parents does not exist, or a parent is a file.
+func (c *Pan115Client) StatByPath(p string) (*File, error) {
+	return c.StatByPathContext(context.Background(), p)
+}
+
+// StatByPathContext is like StatByPath but with a context.
+func (c *Pan115Client) StatByPathContext(ctx context.Context, p string) (*File, error) {
+	p = cleanPath(p)
+	if p == "/" {
+		return &File{IsDirectory: true, FileID: "0"}, nil
+	}
+	pc := c.getPathCache()
+	if pc != nil {
+		if e, ok := pc.get(p); ok && e.file != nil {
+			f := *e.file
+			return &f, nil
+		}
+	}
+
+	dir, name := path.Split(p)
+	dir = cleanPath(dir)
+	cid, err := c.dirCID(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *File
+	it := c.ListIteratorContext(ctx, cid)
+	for f, ok := it.Next(); ok; f, ok = it.Next() {
+		if pc != nil {
+			file := *f
+			pc.put(path.Join(dir, f.Name), f.FileID, &file)
+		}
+		if found == nil && f.Name == name {
+			file := *f
+			found = &file
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, errors.Wrap(ErrNotExist, p)
+	}
+	return found, nil
+}
+
+// dirCID resolves the directory path dir to its CID.
+func (c *Pan115Client) dirCID(ctx context.Context, dir string) (string, error) {
+	if dir == "/" {
+		return "0", nil
+	}
+	pc := c.getPathCache()
+	if pc != nil {
+		if e, ok := pc.get(dir); ok {
+			if e.file != nil && !e.file.IsDirectory {
+				return "", errors.Wrapf(ErrNotExist, "%s is not a directory", dir)
+			}
+			return e.cid, nil
+		}
+	}
+	result, err := c.DirName2CIDContext(ctx, dir)
+	if err != nil {
+		return "", err
+	}
+	cid := string(result.CategoryID)
+	if cid == "" || cid == "0" {
+		return "", errors.Wrap(ErrNotExist, dir)
+	}
+	if pc != nil {
+		pc.put(dir, cid, nil)
+	}
+	return cid, nil
+}
+
+func cleanPath(p string) string {
+	return path.Clean("/" + p)
+}
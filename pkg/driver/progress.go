@@ -0,0 +1,54 @@
+package driver
+
+import "sync"
+
+// ProgressFunc reports transferred bytes of total, total is -1 if unknown.
+type ProgressFunc func(transferred, total int64)
+
+// progress calls fn when at least every bytes are transferred since the last
+// call, and when the transfer is completed.
+type progress struct {
+	mu       sync.Mutex
+	fn       ProgressFunc
+	total    int64
+	every    int64
+	done     int64
+	reported int64
+}
+
+// newProgress returns nil if fn is nil, which is safe to use.
+func newProgress(fn ProgressFunc, total, every int64) *progress {
+	if fn == nil {
+		return nil
+	}
+	return &progress{fn: fn, total: total, every: every}
+}
+
+// skip counts n bytes as transferred without reporting, e.g. resumed parts.
+func (p *progress) skip(n int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done += n
+	p.reported = p.done
+}
+
+func (p *progress) add(n int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done += n
+	if p.done-p.reported >= p.every || p.done == p.total {
+		p.reported = p.done
+		p.fn(p.done, p.total)
+	}
+}
+
+func (p *progress) Write(b []byte) (int, error) {
+	p.add(int64(len(b)))
+	return len(b), nil
+}
@@ -1,6 +1,7 @@
 package driver
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 
@@ -15,6 +16,8 @@ type QRCodeSession struct {
 	Sign          string `json:"sign"`
 	Time          int64  `json:"time"`
 	UID           string `json:"uid"`
+	// App to login as, set by QRCodeLoginStart.
+	App LoginApp `json:"-"`
 }
 
 // QRCode get QRCode matrix or image.
@@ -30,8 +33,14 @@ func (s *QRCodeSession) QRCodeByApi() ([]byte, error) {
 
 // QRCodeStart starts a QRCode login session.
 func (c *Pan115Client) QRCodeStart() (*QRCodeSession, error) {
+	return c.QRCodeStartContext(context.Background())
+}
+
+// QRCodeStartContext is like QRCodeStart but with a context.
+func (c *Pan115Client) QRCodeStartContext(ctx context.Context) (*QRCodeSession, error) {
 	result := QRCodeTokenResp{}
 	resp, err := c.NewRequest().
+		SetContext(ctx).
 		SetResult(&result).
 		ForceContentType("application/json;charset=UTF-8").
 		Get(ApiQrcodeToken)
@@ -42,12 +51,65 @@ func (c *Pan115Client) QRCodeStart() (*QRCodeSession, error) {
 	return &result.Data, nil
 }
 
+// QRCodeLoginStart starts a QRCode login session which logins as app when
+// polled by QRCodeLoginPoll, LoginAppWeb is used if app is empty.
+func (c *Pan115Client) QRCodeLoginStart(app LoginApp) (*QRCodeSession, error) {
+	return c.QRCodeLoginStartContext(context.Background(), app)
+}
+
+// QRCodeLoginStartContext is like QRCodeLoginStart but with a context.
+func (c *Pan115Client) QRCodeLoginStartContext(ctx context.Context, app LoginApp) (*QRCodeSession, error) {
+	s, err := c.QRCodeStartContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if app == "" {
+		app = LoginAppWeb
+	}
+	s.App = app
+	return s, nil
+}
+
+// QRCodeLoginPoll gets the status of the session, and logins once the QRCode
+// is allowed, in which case the Credential is returned.
+func (c *Pan115Client) QRCodeLoginPoll(s *QRCodeSession) (*QRCodeStatus, *Credential, error) {
+	return c.QRCodeLoginPollContext(context.Background(), s)
+}
+
+// QRCodeLoginPollContext is like QRCodeLoginPoll but with a context.
+func (c *Pan115Client) QRCodeLoginPollContext(ctx context.Context, s *QRCodeSession) (*QRCodeStatus, *Credential, error) {
+	status, err := c.QRCodeStatusContext(ctx, s)
+	if err != nil {
+		return nil, nil, err
+	}
+	if status.IsExpired() {
+		return status, nil, ErrQrcodeExpired
+	}
+	if !status.IsAllowed() {
+		return status, nil, nil
+	}
+	app := s.App
+	if app == "" {
+		app = LoginAppWeb
+	}
+	cr, err := c.QRCodeLoginWithAppContext(ctx, s, app)
+	if err != nil {
+		return status, nil, err
+	}
+	return status, cr, nil
+}
+
 // QRCodeLogin logins user through QRCode with web app.
 // You SHOULD call this method ONLY when `QRCodeStatus.IsAllowed()` is true.
 func (c *Pan115Client) QRCodeLogin(s *QRCodeSession) (*Credential, error) {
 	return c.QRCodeLoginWithApp(s, LoginAppWeb)
 }
 
+// QRCodeLoginContext is like QRCodeLogin but with a context.
+func (c *Pan115Client) QRCodeLoginContext(ctx context.Context, s *QRCodeSession) (*Credential, error) {
+	return c.QRCodeLoginWithAppContext(ctx, s, LoginAppWeb)
+}
+
 type LoginApp string
 
 const (
@@ -66,8 +128,14 @@ const (
 // QRCodeLoginWithApp logins user through QRCode with specified app.
 // You SHOULD call this method ONLY when `QRCodeStatus.IsAllowed()` is true.
 func (c *Pan115Client) QRCodeLoginWithApp(s *QRCodeSession, app LoginApp) (*Credential, error) {
+	return c.QRCodeLoginWithAppContext(context.Background(), s, app)
+}
+
+// QRCodeLoginWithAppContext is like QRCodeLoginWithApp but with a context.
+func (c *Pan115Client) QRCodeLoginWithAppContext(ctx context.Context, s *QRCodeSession, app LoginApp) (*Credential, error) {
 	result := QRCodeLoginResp{}
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetFormData(map[string]string{
 			"account": s.UID,
 			"app":     string(app),
@@ -118,8 +186,14 @@ There are 4 possible status values:
 - Canceled
 */
 func (c *Pan115Client) QRCodeStatus(s *QRCodeSession) (*QRCodeStatus, error) {
+	return c.QRCodeStatusContext(context.Background(), s)
+}
+
+// QRCodeStatusContext is like QRCodeStatus but with a context.
+func (c *Pan115Client) QRCodeStatusContext(ctx context.Context, s *QRCodeSession) (*QRCodeStatus, error) {
 	result := QRCodeStatusResp{}
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetQueryParams(map[string]string{
 			"uid":  s.UID,
 			"time": strconv.FormatInt(s.Time, 10),
@@ -0,0 +1,137 @@
+package driver
+
+import (
+	"bufio"
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// RangeSeekWindow is how far a seek forward may jump and keep the connection
+// of a rangeReader, the bytes in between are read and dropped instead of
+// opening a new range request.
+const RangeSeekWindow = 256 * KB
+
+// rangeReader reads a file by range requests, the download URL is fetched on
+// the first read and the connection is reopened after seeking out of the window.
+type rangeReader struct {
+	ctx      context.Context
+	c        *Pan115Client
+	pickCode string
+	size     int64
+
+	info   *DownloadInfo
+	offset int64
+	body   io.ReadCloser
+	br     *bufio.Reader
+	// pos is the offset of br.
+	pos int64
+}
+
+func (c *Pan115Client) newRangeReader(ctx context.Context, pickCode string, size int64) *rangeReader {
+	return &rangeReader{ctx: ctx, c: c, pickCode: pickCode, size: size}
+}
+
+// OpenSeeker opens the file of pickCode for random access and returns its size.
+// Nothing is downloaded until the first read, and seeking reopens the
+// connection by range only if it jumps backward or beyond RangeSeekWindow.
+func (c *Pan115Client) OpenSeeker(pickCode string) (io.ReadSeekCloser, int64, error) {
+	return c.OpenSeekerContext(context.Background(), pickCode)
+}
+
+// OpenSeekerContext is like OpenSeeker but with a context.
+func (c *Pan115Client) OpenSeekerContext(ctx context.Context, pickCode string) (io.ReadSeekCloser, int64, error) {
+	if pickCode == "" {
+		return nil, 0, ErrPickCodeIsEmpty
+	}
+	info, err := c.DownloadWithUAContext(ctx, pickCode, "")
+	if err != nil {
+		return nil, 0, err
+	}
+	r := c.newRangeReader(ctx, pickCode, int64(info.FileSize))
+	r.info = info
+	return r, r.size, nil
+}
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.body != nil && r.offset != r.pos {
+		if d := r.offset - r.pos; d > 0 && d <= RangeSeekWindow {
+			n, err := r.br.Discard(int(d))
+			r.pos += int64(n)
+			if err != nil {
+				r.closeBody()
+			}
+		} else {
+			r.closeBody()
+		}
+	}
+	if r.body == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.br.Read(p)
+	r.offset += int64(n)
+	r.pos += int64(n)
+	if err == io.EOF && r.offset < r.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (r *rangeReader) open() error {
+	if r.info == nil {
+		info, err := r.c.DownloadWithUAContext(r.ctx, r.pickCode, "")
+		if err != nil {
+			return err
+		}
+		r.info = info
+	}
+	o := DefaultDownloadOptions()
+	o.RangeStart = r.offset
+	body, _, err := r.c.downloadStream(r.ctx, r.info, o)
+	if err != nil {
+		return err
+	}
+	r.body, r.pos = body, r.offset
+	if r.br == nil {
+		r.br = bufio.NewReader(body)
+	} else {
+		r.br.Reset(body)
+	}
+	return nil
+}
+
+func (r *rangeReader) closeBody() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
+
+func (r *rangeReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.Wrap(ErrWrongParams, "invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.Wrap(ErrWrongParams, "negative position")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *rangeReader) Close() error {
+	return r.closeBody()
+}
@@ -1,13 +1,19 @@
 package driver
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"strconv"
 )
 
-// CleanRecycleBin clean the recycle bin
+// CleanRecycleBin clean the recycle bin, all items are cleaned if no rIDs given.
 func (c *Pan115Client) CleanRecycleBin(password string, rIDs ...string) error {
+	return c.CleanRecycleBinContext(context.Background(), password, rIDs...)
+}
+
+// CleanRecycleBinContext is like CleanRecycleBin but with a context.
+func (c *Pan115Client) CleanRecycleBinContext(ctx context.Context, password string, rIDs ...string) error {
 	form := url.Values{}
 	form.Set("password", password)
 	for idx, rID := range rIDs {
@@ -15,6 +21,7 @@ func (c *Pan115Client) CleanRecycleBin(password string, rIDs ...string) error {
 	}
 	result := BasicResp{}
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetFormDataFromValues(form).
 		SetResult(&result).
 		ForceContentType("application/json;charset=UTF-8")
@@ -25,8 +32,14 @@ func (c *Pan115Client) CleanRecycleBin(password string, rIDs ...string) error {
 
 // ListRecycleBin list the recycle bin
 func (c *Pan115Client) ListRecycleBin(offset, limit int) ([]RecycleBinItem, error) {
+	return c.ListRecycleBinContext(context.Background(), offset, limit)
+}
+
+// ListRecycleBinContext is like ListRecycleBin but with a context.
+func (c *Pan115Client) ListRecycleBinContext(ctx context.Context, offset, limit int) ([]RecycleBinItem, error) {
 	result := RecycleListResponse{}
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetQueryParams(map[string]string{
 			"aid":    "7",
 			"cid":    "0",
@@ -61,12 +74,18 @@ type RecycleBinItem struct {
 
 // RevertRecycleBin revert the recycle bin
 func (c *Pan115Client) RevertRecycleBin(rIDs ...string) error {
+	return c.RevertRecycleBinContext(context.Background(), rIDs...)
+}
+
+// RevertRecycleBinContext is like RevertRecycleBin but with a context.
+func (c *Pan115Client) RevertRecycleBinContext(ctx context.Context, rIDs ...string) error {
 	form := url.Values{}
 	for idx, rID := range rIDs {
 		form.Add(fmt.Sprintf("rid[%d]", idx), rID)
 	}
 	result := BasicResp{}
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetFormDataFromValues(form).
 		SetResult(&result).
 		ForceContentType("application/json;charset=UTF-8")
@@ -74,3 +93,38 @@ func (c *Pan115Client) RevertRecycleBin(rIDs ...string) error {
 	resp, err := req.Post(ApiRecycleRevert)
 	return CheckErr(err, &result, resp)
 }
+
+// RestoredFile is a file restored from the recycle bin.
+type RestoredFile struct {
+	FileID string
+	// ParentID is the directory where the file is restored, which differs from
+	// the original one if it no longer exists.
+	ParentID string
+}
+
+// RestoreFromRecycle restores items from the recycle bin, and returns where
+// they are restored.
+func (c *Pan115Client) RestoreFromRecycle(rIDs ...string) ([]RestoredFile, error) {
+	return c.RestoreFromRecycleContext(context.Background(), rIDs...)
+}
+
+// RestoreFromRecycleContext is like RestoreFromRecycle but with a context.
+func (c *Pan115Client) RestoreFromRecycleContext(ctx context.Context, rIDs ...string) ([]RestoredFile, error) {
+	if len(rIDs) == 0 {
+		return nil, nil
+	}
+	if err := c.RevertRecycleBinContext(ctx, rIDs...); err != nil {
+		return nil, err
+	}
+	c.invalidateCache(rIDs...)
+	restored := make([]RestoredFile, len(rIDs))
+	for i, rID := range rIDs {
+		f, err := c.GetFileContext(ctx, rID)
+		if err != nil {
+			return nil, err
+		}
+		restored[i] = RestoredFile{FileID: rID, ParentID: f.ParentID}
+		c.invalidateCache(f.ParentID)
+	}
+	return restored, nil
+}
@@ -0,0 +1,102 @@
+package driver
+
+import (
+	"net/http"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+)
+
+// CredentialRefresher returns a fresh credential when the old one is expired.
+type CredentialRefresher func() (*Credential, error)
+
+// SetCredentialRefresher sets fn to be called when a request fails as not
+// logged in, then the request will be retried once with the new credential.
+func (c *Pan115Client) SetCredentialRefresher(fn CredentialRefresher) *Pan115Client {
+	c.refresher = fn
+	c.hookRefresher()
+	return c
+}
+
+// hookRefresher adds the retry condition calling the refresher to the resty
+// client once.
+func (c *Pan115Client) hookRefresher() {
+	if c.refresher == nil || c.refresherHooked == c.Client {
+		return
+	}
+	c.refresherHooked = c.Client
+	if c.Client.RetryCount < 1 {
+		c.Client.SetRetryCount(1)
+	}
+	c.Client.AddRetryCondition(c.refreshCondition)
+	c.hookRetry()
+}
+
+func (c *Pan115Client) refreshCondition(resp *resty.Response, err error) bool {
+	if err != nil || resp == nil || c.refresher == nil || resp.Request.Attempt > 1 {
+		return false
+	}
+	if !isNotLoginResp(resp.Body()) {
+		return false
+	}
+	used := ""
+	if resp.Request.RawRequest != nil {
+		if ck, err := resp.Request.RawRequest.Cookie(CookieNameSeid); err == nil {
+			used = ck.Value
+		}
+	}
+	return c.refreshCredential(used) == nil
+}
+
+// refreshCredential calls the refresher unless the session which the failed
+// request used has already been replaced by a concurrent refresh.
+func (c *Pan115Client) refreshCredential(usedSeid string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	if usedSeid != c.cookieValue(CookieNameSeid) {
+		return nil
+	}
+	cr, err := c.refresher()
+	if err != nil {
+		return err
+	}
+	if cr == nil {
+		return ErrCredentialInvalid
+	}
+	c.ImportCredential(cr)
+	return nil
+}
+
+func (c *Pan115Client) cookieValue(name string) string {
+	for _, ck := range c.loadCookies() {
+		if ck.Name == name {
+			return ck.Value
+		}
+	}
+	return ""
+}
+
+func isNotLoginResp(body []byte) bool {
+	for _, code := range respCodes(body) {
+		if errors.Is(GetErr(code), ErrNotLogin) {
+			return true
+		}
+	}
+	return false
+}
+
+// replaceCookies replaces the client cookies which have the same name, the
+// slice is copied so that in-flight requests still see the old one.
+func replaceCookies(old []*http.Cookie, cks []*http.Cookie) []*http.Cookie {
+	names := make(map[string]bool, len(cks))
+	for _, ck := range cks {
+		names[ck.Name+"\x00"+ck.Domain] = true
+	}
+	merged := make([]*http.Cookie, 0, len(old)+len(cks))
+	for _, ck := range old {
+		if !names[ck.Name+"\x00"+ck.Domain] {
+			merged = append(merged, ck)
+		}
+	}
+	return append(merged, cks...)
+}
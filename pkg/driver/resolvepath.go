@@ -0,0 +1,78 @@
+package driver
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DirNodeCacheTTL is how long ResolvePath caches the name and parent of a directory.
+const DirNodeCacheTTL = 10 * time.Minute
+
+// maxPathDepth bounds the walk of ResolvePath in case the parents form a cycle.
+const maxPathDepth = 256
+
+type dirNode struct {
+	name    string
+	parent  string
+	expires time.Time
+}
+
+// ResolvePath gets the absolute path of file like "/a/b/file.ext" by walking
+// its parent directories up to the root. The name and parent of each directory
+// are cached for DirNodeCacheTTL, so files under the same directories are
+// resolved without requests.
+func (c *Pan115Client) ResolvePath(file *File) (string, error) {
+	return c.ResolvePathContext(context.Background(), file)
+}
+
+// ResolvePathContext is like ResolvePath but with a context.
+func (c *Pan115Client) ResolvePathContext(ctx context.Context, file *File) (string, error) {
+	if file.FileID == "0" {
+		return "/", nil
+	}
+	names := []string{file.Name}
+	for cid := file.ParentID; cid != "" && cid != "0"; {
+		if len(names) > maxPathDepth {
+			return "", errors.Wrapf(ErrUnexpected, "path of %s is too deep", file.FileID)
+		}
+		node, err := c.dirNode(ctx, cid)
+		if err != nil {
+			return "", err
+		}
+		names = append(names, node.name)
+		cid = node.parent
+	}
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return "/" + strings.Join(names, "/"), nil
+}
+
+// dirNode gets the name and parent of the directory cid.
+func (c *Pan115Client) dirNode(ctx context.Context, cid string) (*dirNode, error) {
+	if v, ok := c.dirNodes.Load(cid); ok {
+		node := v.(*dirNode)
+		if time.Now().Before(node.expires) {
+			return node, nil
+		}
+		c.dirNodes.Delete(cid)
+	}
+	dir, err := c.GetFileContext(ctx, cid)
+	if err != nil {
+		return nil, err
+	}
+	node := &dirNode{name: dir.Name, parent: dir.ParentID, expires: time.Now().Add(DirNodeCacheTTL)}
+	c.dirNodes.Store(cid, node)
+	return node, nil
+}
+
+// forgetDirNodes drops the cached names and parents of ids after they are
+// renamed, moved or deleted.
+func (c *Pan115Client) forgetDirNodes(ids ...string) {
+	for _, id := range ids {
+		c.dirNodes.Delete(id)
+	}
+}
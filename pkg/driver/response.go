@@ -98,6 +98,7 @@ type FileInfo struct {
 	Size     StringInt64 `json:"s"`
 	Sha1     string      `json:"sha"`
 	PickCode string      `json:"pc"`
+	Thumb    string      `json:"u"`
 
 	IsStar StringInt    `json:"m"`
 	Labels []*LabelInfo `json:"fl"`
@@ -117,6 +118,19 @@ type LabelInfo struct {
 	UpdateTime int64 `json:"update_time"`
 }
 
+type LabelListResp struct {
+	BasicResp
+	Data struct {
+		List  []*LabelInfo `json:"list"`
+		Total StringInt    `json:"total"`
+	} `json:"data"`
+}
+
+type LabelAddResp struct {
+	BasicResp
+	Data []*LabelInfo `json:"data"`
+}
+
 type UploadInfoResp struct {
 	BasicResp
 	UploadMetaInfo
@@ -396,6 +410,20 @@ type ShareFile struct {
 	// U          string       `json:"u"`
 }
 
+// IsDirectory reports whether the shared file is a directory.
+func (f *ShareFile) IsDirectory() bool {
+	return f.FileID == ""
+}
+
+// ID returns the file id, or the directory id for a directory, which is
+// used to save the shared file.
+func (f *ShareFile) ID() string {
+	if f.IsDirectory() {
+		return string(f.CategoryID)
+	}
+	return f.FileID
+}
+
 type UploadResult struct {
 	BasicResp
 	Data struct {
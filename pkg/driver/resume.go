@@ -0,0 +1,103 @@
+package driver
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/pkg/errors"
+)
+
+// ResumeState records the progress of a multipart upload, so that an
+// interrupted upload can skip the parts which have already been uploaded.
+type ResumeState struct {
+	Bucket   string       `json:"bucket"`
+	Object   string       `json:"object"`
+	UploadID string       `json:"upload_id"`
+	Parts    []ResumePart `json:"parts"`
+}
+
+// ResumePart is a completed part of a multipart upload.
+type ResumePart struct {
+	Number int    `json:"number"`
+	ETag   string `json:"etag"`
+}
+
+// SaveResumeState writes the state as a line of JSON to w.
+func SaveResumeState(w io.Writer, s *ResumeState) error {
+	return json.NewEncoder(w).Encode(s)
+}
+
+// LoadResumeState reads the last state written by SaveResumeState from r,
+// an empty state will be returned if r has no data.
+func LoadResumeState(r io.Reader) (*ResumeState, error) {
+	state := &ResumeState{}
+	dec := json.NewDecoder(r)
+	for {
+		s := &ResumeState{}
+		if err := dec.Decode(s); err == io.EOF {
+			return state, nil
+		} else if err != nil {
+			return nil, err
+		}
+		state = s
+	}
+}
+
+func (s *ResumeState) uploadParts() []oss.UploadPart {
+	parts := make([]oss.UploadPart, len(s.Parts))
+	for i, p := range s.Parts {
+		parts[i] = oss.UploadPart{PartNumber: p.Number, ETag: p.ETag}
+	}
+	return parts
+}
+
+// resumeMultipartUpload loads the saved state and checks it against the
+// parts OSS still has, an empty UploadID is returned if the upload must start over.
+func resumeMultipartUpload(bucket *oss.Bucket, params *UploadOSSParams, r io.Reader, options ...oss.Option) (oss.InitiateMultipartUploadResult, []oss.UploadPart, error) {
+	imur := oss.InitiateMultipartUploadResult{}
+	state, err := LoadResumeState(r)
+	if err != nil {
+		return imur, nil, err
+	}
+	if state.UploadID == "" || state.Bucket != params.Bucket || state.Object != params.Object {
+		return imur, nil, nil
+	}
+	imur = oss.InitiateMultipartUploadResult{
+		Bucket:   state.Bucket,
+		Key:      state.Object,
+		UploadID: state.UploadID,
+	}
+
+	uploaded := map[int]string{}
+	marker := 0
+	for {
+		lsRes, err := bucket.ListUploadedParts(imur, append(options, oss.PartNumberMarker(marker))...)
+		if err != nil {
+			var srvErr oss.ServiceError
+			if errors.As(err, &srvErr) && srvErr.Code == "NoSuchUpload" {
+				// 上传会话已过期，重新上传
+				return oss.InitiateMultipartUploadResult{}, nil, nil
+			}
+			return imur, nil, err
+		}
+		for _, p := range lsRes.UploadedParts {
+			uploaded[p.PartNumber] = p.ETag
+		}
+		if !lsRes.IsTruncated {
+			break
+		}
+		if marker, err = strconv.Atoi(lsRes.NextPartNumberMarker); err != nil {
+			return imur, nil, err
+		}
+	}
+
+	var parts []oss.UploadPart
+	for _, p := range state.uploadParts() {
+		if etag, ok := uploaded[p.PartNumber]; ok && etag == p.ETag {
+			parts = append(parts, p)
+		}
+	}
+	return imur, parts, nil
+}
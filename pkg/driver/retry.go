@@ -0,0 +1,131 @@
+package driver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// RetryPolicy retries requests which failed with retryable server codes,
+// waiting a jittered exponential backoff or the Retry-After header if present.
+type RetryPolicy struct {
+	MaxRetries int
+	// BaseDelay is the initial backoff.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff and the Retry-After, 30s if unset.
+	MaxDelay time.Duration
+	// RetryableCodes are the errno of 115 to retry, HTTP 429 is always retried.
+	RetryableCodes []int
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: 3,
+		BaseDelay:  500 * time.Millisecond,
+		MaxDelay:   30 * time.Second,
+	}
+}
+
+// SetRetryPolicy sets the retry policy applied to every request of the client.
+func (c *Pan115Client) SetRetryPolicy(p RetryPolicy) *Pan115Client {
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 30 * time.Second
+	}
+	c.retryPolicy = &p
+	c.hookRetryPolicy()
+	return c
+}
+
+// hookRetryPolicy applies the retry policy to the resty client, the retry
+// condition is added once.
+func (c *Pan115Client) hookRetryPolicy() {
+	p := c.retryPolicy
+	if p == nil {
+		return
+	}
+	retries := p.MaxRetries
+	if c.refresher != nil && retries < 1 {
+		retries = 1
+	}
+	c.Client.
+		SetRetryCount(retries).
+		SetRetryWaitTime(p.BaseDelay).
+		SetRetryMaxWaitTime(p.MaxDelay).
+		SetRetryAfter(retryAfter)
+	if c.retryHooked != c.Client {
+		c.retryHooked = c.Client
+		c.Client.AddRetryCondition(c.retryCondition)
+	}
+	c.hookRetry()
+}
+
+func (c *Pan115Client) retryCondition(resp *resty.Response, err error) bool {
+	p := c.retryPolicy
+	if p == nil || err != nil || resp == nil {
+		return false
+	}
+	if resp.StatusCode() == http.StatusTooManyRequests {
+		return true
+	}
+	for _, code := range respCodes(resp.Body()) {
+		for _, retryable := range p.RetryableCodes {
+			if code == retryable {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hookRetry makes the retried requests carry the current cookies only.
+func (c *Pan115Client) hookRetry() {
+	if c.retryHookAdded == c.Client {
+		return
+	}
+	c.retryHookAdded = c.Client
+	c.Client.AddRetryHook(func(resp *resty.Response, err error) {
+		// resty reuses the header of previous attempt, drop the stale cookies
+		if resp != nil && resp.Request != nil {
+			resp.Request.Header.Del("Cookie")
+		}
+	})
+}
+
+// retryAfter returns the wait of Retry-After header, 0 means using the backoff.
+func retryAfter(_ *resty.Client, resp *resty.Response) (time.Duration, error) {
+	v := resp.Header().Get("Retry-After")
+	if v == "" {
+		return 0, nil
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second, nil
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, nil
+		}
+	}
+	return 0, nil
+}
+
+// respCodes returns the non-zero error codes in a response body.
+func respCodes(body []byte) []int {
+	result := struct {
+		Errno StringInt `json:"errno"`
+		ErrNo int       `json:"errNo"`
+		Code  StringInt `json:"code"`
+	}{}
+	if len(body) == 0 || json.Unmarshal(body, &result) != nil {
+		return nil
+	}
+	var codes []int
+	for _, code := range []int{int(result.Errno), result.ErrNo, int(result.Code)} {
+		if code != 0 {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
@@ -0,0 +1,191 @@
+package driver
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Search searches files and directories by keyword.
+func (c *Pan115Client) Search(keyword string, opts ...SearchOption) (*FileListResp, error) {
+	return c.SearchContext(context.Background(), keyword, opts...)
+}
+
+// SearchContext is like Search but with a context.
+func (c *Pan115Client) SearchContext(ctx context.Context, keyword string, opts ...SearchOption) (*FileListResp, error) {
+	o := DefaultSearchOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.Limit > MaxDirPageLimit {
+		o.Limit = MaxDirPageLimit
+	}
+	params := map[string]string{
+		"aid":          "1",
+		"cid":          o.CID,
+		"search_value": keyword,
+		"offset":       strconv.FormatInt(o.Offset, 10),
+		"limit":        strconv.FormatInt(o.Limit, 10),
+		"format":       "json",
+	}
+	if o.FileType != FileTypeAll {
+		params["type"] = strconv.Itoa(int(o.FileType))
+	}
+	if o.OrderBy != "" {
+		params["o"] = o.OrderBy
+		params["asc"] = "0"
+		if o.Ascending {
+			params["asc"] = "1"
+		}
+	}
+
+	result := FileListResp{}
+	req := c.NewRequest().
+		SetContext(ctx).
+		SetQueryParams(params).
+		ForceContentType("application/json;charset=UTF-8").
+		SetResult(&result)
+	resp, err := req.Get(ApiFileSearch)
+	if err = CheckErr(err, &result, resp); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// recentFirstPage is the page size of the first request of ListRecent, it
+// doubles on each next page up to MaxDirPageLimit.
+const recentFirstPage = 32
+
+// ListRecent lists the files under cid, including those of its subdirectories,
+// by the modification time descending until one older than since appears or
+// limit files are got. A zero since or a limit of 0 does not stop it. The
+// search API is used with an empty keyword, and the modification time has a
+// precision of minutes.
+func (c *Pan115Client) ListRecent(cid string, since time.Time, limit int) ([]File, error) {
+	return c.ListRecentContext(context.Background(), cid, since, limit)
+}
+
+// ListRecentContext is like ListRecent but with a context.
+func (c *Pan115Client) ListRecentContext(ctx context.Context, cid string, since time.Time, limit int) ([]File, error) {
+	if cid == "" {
+		cid = "0"
+	}
+	var files []File
+	pageSize := int64(recentFirstPage)
+	for offset := int64(0); ; {
+		result, err := c.SearchContext(ctx, "",
+			SearchWithCID(cid),
+			SearchWithOffset(offset),
+			SearchWithLimit(pageSize),
+			SearchWithOrder(FileOrderByUpdateTime, false),
+		)
+		if err != nil {
+			return nil, err
+		}
+		for i := range result.Files {
+			f := (&File{}).from(&result.Files[i])
+			if f.IsDirectory {
+				continue
+			}
+			if !since.IsZero() && f.UpdateTime.Before(since) {
+				return files, nil
+			}
+			files = append(files, *f)
+			if limit > 0 && len(files) >= limit {
+				return files, nil
+			}
+		}
+		offset += int64(len(result.Files))
+		if len(result.Files) == 0 || offset >= int64(result.Count) {
+			return files, nil
+		}
+		if pageSize *= 2; pageSize > MaxDirPageLimit {
+			pageSize = MaxDirPageLimit
+		}
+	}
+}
+
+// FileDigest identifies a content by its SHA1 and size, a size of 0 matches any size.
+type FileDigest struct {
+	SHA1 string
+	Size int64
+}
+
+// SHA1SearchResp is the response of searching a file by SHA1.
+type SHA1SearchResp struct {
+	BasicResp
+	Data struct {
+		FileID     string      `json:"file_id"`
+		CategoryID string      `json:"category_id"`
+		FileName   string      `json:"file_name"`
+		FileSize   StringInt64 `json:"file_size"`
+		PickCode   string      `json:"pick_code"`
+		Sha1       string      `json:"sha1"`
+	} `json:"data"`
+}
+
+// SearchBySHA1 finds a file in the drive with the content of sha1, nil if not found.
+func (c *Pan115Client) SearchBySHA1(sha1 string) (*File, error) {
+	return c.SearchBySHA1Context(context.Background(), sha1)
+}
+
+// SearchBySHA1Context is like SearchBySHA1 but with a context.
+func (c *Pan115Client) SearchBySHA1Context(ctx context.Context, sha1 string) (*File, error) {
+	result := SHA1SearchResp{}
+	req := c.NewRequest().
+		SetContext(ctx).
+		SetQueryParam("sha1", strings.ToUpper(sha1)).
+		ForceContentType("application/json;charset=UTF-8").
+		SetResult(&result)
+	resp, err := req.Get(ApiFileSHA1Search)
+	// not found is told by a failed state without a code or a message
+	if err == nil && resp.IsSuccess() && !result.State &&
+		findNonZero(int(result.Errno), result.ErrNo) == 0 && result.Error == "" && result.Msg == "" {
+		return nil, nil
+	}
+	if err = CheckErr(err, &result, resp); err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if result.Data.FileID == "" {
+		return nil, nil
+	}
+	return &File{
+		FileID:   result.Data.FileID,
+		ParentID: result.Data.CategoryID,
+		Name:     result.Data.FileName,
+		Size:     int64(result.Data.FileSize),
+		PickCode: result.Data.PickCode,
+		Sha1:     result.Data.Sha1,
+	}, nil
+}
+
+// CheckExistsBySHA1 finds the existing file of each digest without uploading,
+// the result maps each upper-case SHA1 to the file or nil if not found. The
+// digests are probed one at a time, duplicates are probed once.
+func (c *Pan115Client) CheckExistsBySHA1(hashes []FileDigest) (map[string]*File, error) {
+	return c.CheckExistsBySHA1Context(context.Background(), hashes)
+}
+
+// CheckExistsBySHA1Context is like CheckExistsBySHA1 but with a context.
+func (c *Pan115Client) CheckExistsBySHA1Context(ctx context.Context, hashes []FileDigest) (map[string]*File, error) {
+	result := make(map[string]*File, len(hashes))
+	for _, d := range hashes {
+		sha1 := strings.ToUpper(d.SHA1)
+		if _, ok := result[sha1]; ok {
+			continue
+		}
+		f, err := c.SearchBySHA1Context(ctx, sha1)
+		if err != nil {
+			return result, err
+		}
+		if f != nil && d.Size > 0 && f.Size != d.Size {
+			f = nil
+		}
+		result[sha1] = f
+	}
+	return result, nil
+}
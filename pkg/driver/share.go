@@ -1,7 +1,12 @@
 package driver
 
 import (
+	"context"
+	"net/url"
 	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
 )
 
 type Query func(query *map[string]string)
@@ -22,6 +27,11 @@ func QueryOffset(offset int) Query {
 
 // GetShareSnap get share snap info
 func (c *Pan115Client) GetShareSnap(shareCode, receiveCode, dirID string, Queries ...Query) (*ShareSnapResp, error) {
+	return c.GetShareSnapContext(context.Background(), shareCode, receiveCode, dirID, Queries...)
+}
+
+// GetShareSnapContext is like GetShareSnap but with a context.
+func (c *Pan115Client) GetShareSnapContext(ctx context.Context, shareCode, receiveCode, dirID string, Queries ...Query) (*ShareSnapResp, error) {
 	if isCalledByAlistV3() {
 		return nil, ErrorNotSupportAlist
 	}
@@ -39,6 +49,7 @@ func (c *Pan115Client) GetShareSnap(shareCode, receiveCode, dirID string, Querie
 	}
 
 	req := c.NewRequest().
+		SetContext(ctx).
 		SetQueryParams(query).
 		ForceContentType("application/json;charset=UTF-8").
 		SetResult(&result)
@@ -49,3 +60,208 @@ func (c *Pan115Client) GetShareSnap(shareCode, receiveCode, dirID string, Querie
 
 	return &result, nil
 }
+
+// ShareListPageSize is the page size used by GetShareList.
+const ShareListPageSize = 1000
+
+// ShareListResp is the files of a directory in a share.
+type ShareListResp struct {
+	ShareTitle string
+	Count      int
+	Files      []ShareFile
+}
+
+// GetShareList lists all files and directories of dirID in a share, "0" or
+// empty dirID is the root of the share. ErrShareReceiveCodeIncorrect is
+// returned if the receive code is wrong.
+func (c *Pan115Client) GetShareList(shareCode, receiveCode, dirID string) (*ShareListResp, error) {
+	return c.GetShareListContext(context.Background(), shareCode, receiveCode, dirID)
+}
+
+// GetShareListContext is like GetShareList but with a context.
+func (c *Pan115Client) GetShareListContext(ctx context.Context, shareCode, receiveCode, dirID string) (*ShareListResp, error) {
+	if dirID == "" {
+		dirID = "0"
+	}
+	list := &ShareListResp{}
+	for offset := 0; ; {
+		snap, err := c.GetShareSnapContext(ctx, shareCode, receiveCode, dirID,
+			QueryLimit(ShareListPageSize), QueryOffset(offset))
+		if err != nil {
+			return nil, err
+		}
+		list.ShareTitle = snap.Data.Shareinfo.ShareTitle
+		list.Count = snap.Data.Count
+		list.Files = append(list.Files, snap.Data.List...)
+		offset += len(snap.Data.List)
+		if len(snap.Data.List) == 0 || offset >= snap.Data.Count {
+			break
+		}
+	}
+	return list, nil
+}
+
+// SaveShareToDir saves files or directories of a share into dirID of my drive,
+// fileIDs are the ID of ShareFile. ErrInsufficientSpace, ErrShareSaveLimit
+// or ErrExist is returned if 115 rejects the saving for the reason.
+func (c *Pan115Client) SaveShareToDir(shareCode, receiveCode string, fileIDs []string, dirID string) error {
+	return c.SaveShareToDirContext(context.Background(), shareCode, receiveCode, fileIDs, dirID)
+}
+
+// SaveShareToDirContext is like SaveShareToDir but with a context.
+func (c *Pan115Client) SaveShareToDirContext(ctx context.Context, shareCode, receiveCode string, fileIDs []string, dirID string) error {
+	if isCalledByAlistV3() {
+		return ErrorNotSupportAlist
+	}
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	userID, err := c.userID(ctx)
+	if err != nil {
+		return err
+	}
+	result := BasicResp{}
+	req := c.NewRequest().
+		SetContext(ctx).
+		SetFormData(map[string]string{
+			"user_id":      strconv.FormatInt(userID, 10),
+			"share_code":   shareCode,
+			"receive_code": receiveCode,
+			"file_id":      strings.Join(fileIDs, ","),
+			"cid":          dirID,
+		}).
+		ForceContentType("application/json;charset=UTF-8").
+		SetResult(&result)
+	resp, err := req.Post(ApiShareSave)
+	if err = CheckErr(err, &result, resp); err != nil {
+		return shareSaveErr(err, result.Error)
+	}
+	c.invalidateCache(dirID)
+	return nil
+}
+
+// shareSaveErr types the error of saving a share by its message, as 115
+// uses the same codes for them.
+func shareSaveErr(err error, msg string) error {
+	switch {
+	case strings.Contains(msg, "空间不足"):
+		return errors.Wrap(ErrInsufficientSpace, err.Error())
+	case strings.Contains(msg, "上限"):
+		return errors.Wrap(ErrShareSaveLimit, err.Error())
+	case strings.Contains(msg, "已接收"), strings.Contains(msg, "已存在"):
+		return errors.Wrap(ErrExist, err.Error())
+	}
+	return err
+}
+
+// ShareInfo is a created share.
+type ShareInfo struct {
+	ShareCode   string
+	ReceiveCode string
+	// URL of the share, with the receive code.
+	URL   string
+	Title string
+}
+
+// CreateShare shares files and directories, which expires in 7 days by default.
+func (c *Pan115Client) CreateShare(fileIDs []string, opts ...ShareOption) (*ShareInfo, error) {
+	return c.CreateShareContext(context.Background(), fileIDs, opts...)
+}
+
+// CreateShareContext is like CreateShare but with a context.
+func (c *Pan115Client) CreateShareContext(ctx context.Context, fileIDs []string, opts ...ShareOption) (*ShareInfo, error) {
+	o := DefaultShareOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	if len(fileIDs) == 0 {
+		return nil, errors.Wrap(ErrWrongParams, "no file to share")
+	}
+	userID, err := c.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := ShareSendResp{}
+	req := c.NewRequest().
+		SetContext(ctx).
+		SetFormData(map[string]string{
+			"user_id":     strconv.FormatInt(userID, 10),
+			"file_ids":    strings.Join(fileIDs, ","),
+			"ignore_warn": "1",
+		}).
+		ForceContentType("application/json;charset=UTF-8").
+		SetResult(&result)
+	resp, err := req.Post(ApiShareSend)
+	if err = CheckErr(err, &result, resp); err != nil {
+		return nil, err
+	}
+	info := &ShareInfo{
+		ShareCode:   result.Data.ShareCode,
+		ReceiveCode: result.Data.ReceiveCode,
+		URL:         result.Data.ShareURL,
+		Title:       result.Data.ShareTitle,
+	}
+
+	form := map[string]string{
+		"share_code":     info.ShareCode,
+		"share_duration": strconv.Itoa(int(o.Duration)),
+	}
+	if o.ReceiveCode != "" {
+		form["receive_code"] = o.ReceiveCode
+		form["is_custom_code"] = "1"
+	}
+	update := BasicResp{}
+	req = c.NewRequest().
+		SetContext(ctx).
+		SetFormData(form).
+		ForceContentType("application/json;charset=UTF-8").
+		SetResult(&update)
+	resp, err = req.Post(ApiShareUpdate)
+	if err = CheckErr(err, &update, resp); err != nil {
+		return nil, err
+	}
+	if o.ReceiveCode != "" {
+		info.ReceiveCode = o.ReceiveCode
+	}
+	info.URL = ShareURL(info.ShareCode, info.ReceiveCode)
+	return info, nil
+}
+
+// ShareURL returns the URL of a share with its receive code.
+func ShareURL(shareCode, receiveCode string) string {
+	u := "https://115.com/s/" + shareCode
+	if receiveCode != "" {
+		u += "?password=" + receiveCode
+	}
+	return u
+}
+
+// ParseShareURL parses the share code and receive code from a share URL,
+// like https://115.com/s/swxxxxx?password=xxxx or https://115.com/s/swxxxxx#xxxx.
+func ParseShareURL(shareURL string) (shareCode, receiveCode string, err error) {
+	u, err := url.Parse(strings.TrimSpace(shareURL))
+	if err != nil {
+		return "", "", errors.Wrap(ErrWrongParams, err.Error())
+	}
+	p := strings.Trim(u.Path, "/")
+	if !strings.HasPrefix(p, "s/") || len(p) == 2 {
+		return "", "", errors.Wrapf(ErrWrongParams, "not a share URL: %s", shareURL)
+	}
+	shareCode = p[2:]
+	receiveCode = u.Query().Get("password")
+	if receiveCode == "" {
+		receiveCode = u.Fragment
+	}
+	return shareCode, receiveCode, nil
+}
+
+type ShareSendResp struct {
+	BasicResp
+	Data struct {
+		ShareCode   string `json:"share_code"`
+		ReceiveCode string `json:"receive_code"`
+		ShareURL    string `json:"share_url"`
+		ShareTitle  string `json:"share_title"`
+	} `json:"data"`
+}
@@ -0,0 +1,59 @@
+package driver
+
+import (
+	"encoding/hex"
+	"encoding/json"
+
+	crypto "github.com/SheltonZhu/115driver/pkg/crypto/m115"
+	"github.com/pkg/errors"
+)
+
+// SignParams encrypts data, usually the JSON of the parameters, the way the
+// download and offline APIs take it. The encoded string is posted as the "data"
+// form field and the hex key decodes the "data" of the response by DecodeParams.
+// It is an escape hatch to call APIs not wrapped by the client via NewRequest.
+func (c *Pan115Client) SignParams(data []byte) (encoded string, key string, err error) {
+	k := crypto.GenerateKey()
+	return crypto.Encode(data, k), hex.EncodeToString(k[:]), nil
+}
+
+// DecodeParams decrypts the encoded "data" of a response to a request signed
+// by SignParams with key.
+func DecodeParams(encoded, key string) ([]byte, error) {
+	k, err := parseCryptoKey(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := crypto.Decode(encoded, k)
+	if err != nil {
+		return nil, errors.Wrap(ErrUnexpected, err.Error())
+	}
+	return data, nil
+}
+
+// DecodeEncryptedResponse decrypts the body of a response to a request signed
+// by SignParams with key. The error of 115 is returned if the state of the body
+// is failed, otherwise the decrypted "data" is returned.
+func DecodeEncryptedResponse(body []byte, key string) ([]byte, error) {
+	result := DownloadResp{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, errors.Wrap(ErrUnexpected, err.Error())
+	}
+	if err := result.Err(string(body)); err != nil {
+		return nil, err
+	}
+	if result.EncodedData == "" {
+		return nil, errors.Wrap(ErrUnexpected, "no data")
+	}
+	return DecodeParams(string(result.EncodedData), key)
+}
+
+func parseCryptoKey(key string) (crypto.Key, error) {
+	k := crypto.Key{}
+	b, err := hex.DecodeString(key)
+	if err != nil || len(b) != len(k) {
+		return k, errors.Wrapf(ErrWrongParams, "invalid key %q", key)
+	}
+	copy(k[:], b)
+	return k, nil
+}
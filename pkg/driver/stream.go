@@ -0,0 +1,210 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/pkg/errors"
+)
+
+// StreamPartSize is the part size of UploadStream, which is the most bytes
+// buffered in memory while uploading.
+const StreamPartSize = 8 * MB
+
+// UploadStream uploads the content of r into dirID as name, size is -1 if unknown.
+//
+// UploadStream can not pipe a plain io.Reader straight into 115 without
+// storing it. The upload init, which returns the OSS object to write to,
+// requires the SHA1 and the size of the whole content, so the content is read
+// once for the digest and once for the upload. An io.ReadSeeker is rewound
+// and never stored, only one part of StreamPartSize is buffered at a time.
+// Any other reader is kept in memory if it fits in one part, otherwise it is
+// written to a temporary file first. Pass an io.ReadSeeker, e.g. an HTTP body
+// that supports ranges, to keep large contents off the disk.
+func (c *Pan115Client) UploadStream(r io.Reader, size int64, dirID, name string) (*File, error) {
+	return c.UploadStreamContext(context.Background(), r, size, dirID, name)
+}
+
+// UploadStreamContext is like UploadStream but with a context.
+func (c *Pan115Client) UploadStreamContext(ctx context.Context, r io.Reader, size int64, dirID, name string) (*File, error) {
+	if ok, err := c.UploadAvailableContext(ctx); err != nil || !ok {
+		return nil, err
+	}
+	if size > c.uploadSizeLimit() {
+		return nil, ErrUploadTooLarge
+	}
+
+	rs, ok := r.(io.ReadSeeker)
+	if !ok {
+		spooled, cleanup, err := spoolStream(r)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+		rs = spooled
+	}
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	digest, err := c.GetDigestResult(rs)
+	if err != nil {
+		return nil, err
+	}
+	if size >= 0 && digest.Size != size {
+		return nil, errors.Wrapf(ErrWrongParams, "read %d bytes, expected %d", digest.Size, size)
+	}
+	if digest.Size > c.uploadSizeLimit() {
+		return nil, ErrUploadTooLarge
+	}
+
+	// 闪传
+	fastInfo, err := c.RapidUploadContext(ctx, digest.Size, name, dirID, digest.PreID, digest.QuickID, rs)
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := fastInfo.Ok(); err != nil {
+		return nil, err
+	} else if ok {
+		return c.findUploadedFile(ctx, dirID, digest.QuickID)
+	}
+	if _, err = rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if digest.Size == 0 {
+		// an empty file has no part, upload it in one put
+		return c.uploadByOSS(ctx, &fastInfo.UploadOSSParams, rs, dirID)
+	}
+	return c.uploadStreamByMultipart(ctx, &fastInfo.UploadOSSParams, rs, digest.Size)
+}
+
+// spoolStream makes r seekable, in memory if it has at most StreamPartSize
+// bytes, otherwise in a temporary file which cleanup removes.
+func spoolStream(r io.Reader) (io.ReadSeeker, func(), error) {
+	head, err := io.ReadAll(io.LimitReader(r, StreamPartSize+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(head) <= StreamPartSize {
+		return bytes.NewReader(head), func() {}, nil
+	}
+	tmp, err := os.CreateTemp("", "115upload-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}
+	if _, err = io.Copy(tmp, io.MultiReader(bytes.NewReader(head), r)); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return tmp, cleanup, nil
+}
+
+// uploadStreamByMultipart uploads r part by part in sequence, only one part is
+// buffered at a time.
+func (c *Pan115Client) uploadStreamByMultipart(ctx context.Context, params *UploadOSSParams, r io.Reader, size int64) (*File, error) {
+	sess, err := c.newOSSSession(ctx, params.Bucket, DefalutUploadMultipartOptions().TokenRefreshMargin)
+	if err != nil {
+		return nil, err
+	}
+	bucket, ossToken := sess.bucket, sess.token
+	imur, err := bucket.InitiateMultipartUpload(params.Object,
+		oss.SetHeader(OssSecurityTokenHeaderName, ossToken.SecurityToken),
+		oss.UserAgentHeader(OSSUserAgent),
+		oss.EnableSha1(),
+		oss.Sequential(),
+		oss.WithContext(ctx),
+	)
+	if err != nil {
+		return nil, err
+	}
+	abort := func(err error) (*File, error) {
+		if bucket, ossToken, e := sess.get(); e == nil {
+			_ = bucket.AbortMultipartUpload(imur,
+				oss.SetHeader(OssSecurityTokenHeaderName, ossToken.SecurityToken),
+				oss.UserAgentHeader(OSSUserAgent),
+			)
+		}
+		return nil, err
+	}
+
+	partSize := int64(StreamPartSize)
+	if size/partSize >= 10000 { // 最多10000片
+		partSize = size/10000 + 1
+	}
+	buf := make([]byte, partSize)
+	var parts []oss.UploadPart
+	for number := 1; ; number++ {
+		n, err := io.ReadFull(r, buf)
+		if err == io.EOF && number > 1 {
+			break
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return abort(err)
+		}
+		part, err := c.uploadStreamPart(ctx, sess, params, imur, buf[:n], number)
+		if err != nil {
+			return abort(err)
+		}
+		parts = append(parts, part)
+		if int64(n) < partSize {
+			break
+		}
+	}
+
+	var bodyBytes []byte
+	if bucket, ossToken, err = sess.get(); err != nil {
+		return abort(err)
+	}
+	if _, err = bucket.CompleteMultipartUpload(imur, parts,
+		append(
+			OssOption(params, ossToken),
+			oss.CallbackResult(&bodyBytes),
+			oss.WithContext(ctx),
+		)...); err != nil {
+		return abort(err)
+	}
+	var uploadResult UploadResult
+	if err = json.Unmarshal(bodyBytes, &uploadResult); err != nil {
+		return nil, err
+	}
+	if err = uploadResult.Err(string(bodyBytes)); err != nil {
+		return nil, err
+	}
+	return &File{
+		FileID:   uploadResult.Data.FileID,
+		ParentID: uploadResult.Data.Cid,
+		Name:     uploadResult.Data.FileName,
+		Size:     int64(uploadResult.Data.FileSize),
+		PickCode: uploadResult.Data.PickCode,
+		Sha1:     uploadResult.Data.Sha1,
+	}, nil
+}
+
+// uploadStreamPart uploads a part, it is retried once with a new token if the
+// token has expired.
+func (c *Pan115Client) uploadStreamPart(ctx context.Context, sess *ossSession, params *UploadOSSParams, imur oss.InitiateMultipartUploadResult, buf []byte, number int) (oss.UploadPart, error) {
+	bucket, ossToken, err := sess.get()
+	if err != nil {
+		return oss.UploadPart{}, err
+	}
+	part, err := bucket.UploadPart(imur, c.limitReader(ctx, bytes.NewReader(buf)), int64(len(buf)), number,
+		append(OssOption(params, ossToken), oss.WithContext(ctx))...)
+	if !isOSSTokenExpired(err) {
+		return part, err
+	}
+	if err = sess.refresh(); err != nil {
+		return oss.UploadPart{}, err
+	}
+	if bucket, ossToken, err = sess.get(); err != nil {
+		return oss.UploadPart{}, err
+	}
+	return bucket.UploadPart(imur, c.limitReader(ctx, bytes.NewReader(buf)), int64(len(buf)), number,
+		append(OssOption(params, ossToken), oss.WithContext(ctx))...)
+}
@@ -0,0 +1,209 @@
+package driver
+
+import (
+	"context"
+	"io"
+	"path"
+
+	"github.com/pkg/errors"
+)
+
+// SubClient confines the path-based operations to the directory tree of its
+// root. Paths are relative to the root, "/" or "" being the root itself, and
+// ".." never leaves it. Lookups are cached for DefaultPathCacheTTL.
+type SubClient struct {
+	c     *Pan115Client
+	root  string
+	cache *pathCache
+}
+
+// Sub returns a SubClient rooted at the directory rootCID. The root of the
+// cloud storage can not be a sandbox, every operation of a SubClient of "0"
+// fails with ErrWrongParams.
+func (c *Pan115Client) Sub(rootCID string) *SubClient {
+	return &SubClient{c: c, root: rootCID, cache: newPathCache(DefaultPathCacheSize, DefaultPathCacheTTL)}
+}
+
+// Root returns the CID of the root directory.
+func (s *SubClient) Root() string {
+	return s.root
+}
+
+// Stat gets the file or directory at p.
+func (s *SubClient) Stat(p string) (*File, error) {
+	return s.StatContext(context.Background(), p)
+}
+
+// StatContext is like Stat but with a context.
+func (s *SubClient) StatContext(ctx context.Context, p string) (*File, error) {
+	if s.root == "" || s.root == "0" {
+		return nil, errors.Wrap(ErrWrongParams, "sub client of the root")
+	}
+	p = cleanPath(p)
+	if p == "/" {
+		return &File{FileID: s.root, IsDirectory: true}, nil
+	}
+	if e, ok := s.cache.get(p); ok && e.file != nil {
+		f := *e.file
+		return &f, nil
+	}
+	dir, name := path.Split(p)
+	parent, err := s.StatContext(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	if !parent.IsDirectory {
+		return nil, errors.Wrapf(ErrNotExist, "%s is not a directory", dir)
+	}
+	files, err := s.c.listAllFiles(ctx, parent.FileID)
+	if err != nil {
+		return nil, err
+	}
+	var found *File
+	for i := range files {
+		f := files[i]
+		s.cache.put(path.Join(dir, f.Name), f.FileID, &f)
+		if found == nil && f.Name == name {
+			found = &f
+		}
+	}
+	if found == nil {
+		return nil, errors.Wrap(ErrNotExist, p)
+	}
+	return found, nil
+}
+
+// List lists the files and directories of the directory at p.
+func (s *SubClient) List(p string) ([]File, error) {
+	return s.ListContext(context.Background(), p)
+}
+
+// ListContext is like List but with a context.
+func (s *SubClient) ListContext(ctx context.Context, p string) ([]File, error) {
+	dir, err := s.StatContext(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	if !dir.IsDirectory {
+		return nil, errors.Wrapf(ErrNotExist, "%s is not a directory", p)
+	}
+	return s.c.listAllFiles(ctx, dir.FileID)
+}
+
+// MkdirAll creates the directory at p along with any missing parents, and
+// returns its directory id like Pan115Client.MkdirAll.
+func (s *SubClient) MkdirAll(p string) (string, error) {
+	return s.MkdirAllContext(context.Background(), p)
+}
+
+// MkdirAllContext is like MkdirAll but with a context.
+func (s *SubClient) MkdirAllContext(ctx context.Context, p string) (string, error) {
+	p = cleanPath(p)
+	f, err := s.StatContext(ctx, p)
+	if err == nil {
+		if !f.IsDirectory {
+			return "", errors.Wrapf(ErrExist, "%s exists and is not a directory", p)
+		}
+		return f.FileID, nil
+	}
+	if !errors.Is(err, ErrNotExist) {
+		return "", err
+	}
+	dir, name := path.Split(p)
+	pid, err := s.MkdirAllContext(ctx, dir)
+	if err != nil {
+		return "", err
+	}
+	cid, err := s.c.MkdirContext(ctx, pid, name)
+	if errors.Is(err, ErrExist) {
+		// created by others since the lookup
+		s.cache.remove(cleanPath(dir))
+		if f, err = s.StatContext(ctx, p); err != nil {
+			return "", err
+		}
+		if !f.IsDirectory {
+			return "", errors.Wrapf(ErrExist, "%s exists and is not a directory", p)
+		}
+		return f.FileID, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	s.cache.put(p, cid, &File{FileID: cid, ParentID: pid, Name: name, IsDirectory: true})
+	return cid, nil
+}
+
+// Upload uploads the content of r as the file at p like UploadStream, the
+// parent directory of p must exist.
+func (s *SubClient) Upload(p string, r io.Reader, size int64) (*File, error) {
+	return s.UploadContext(context.Background(), p, r, size)
+}
+
+// UploadContext is like Upload but with a context.
+func (s *SubClient) UploadContext(ctx context.Context, p string, r io.Reader, size int64) (*File, error) {
+	p = cleanPath(p)
+	if p == "/" {
+		return nil, errors.Wrap(ErrWrongParams, "upload to the root")
+	}
+	dir, name := path.Split(p)
+	parent, err := s.StatContext(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	if !parent.IsDirectory {
+		return nil, errors.Wrapf(ErrNotExist, "%s is not a directory", dir)
+	}
+	f, err := s.c.UploadStreamContext(ctx, r, size, parent.FileID, name)
+	s.cache.remove(p)
+	return f, err
+}
+
+// Open opens the file at p for random access like OpenSeeker, and returns its
+// size.
+func (s *SubClient) Open(p string) (io.ReadSeekCloser, int64, error) {
+	return s.OpenContext(context.Background(), p)
+}
+
+// OpenContext is like Open but with a context.
+func (s *SubClient) OpenContext(ctx context.Context, p string) (io.ReadSeekCloser, int64, error) {
+	f, err := s.file(ctx, p)
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.c.newRangeReader(ctx, f.PickCode, f.Size), f.Size, nil
+}
+
+// DownloadToFile downloads the file at p to destPath like
+// Pan115Client.DownloadToFile.
+func (s *SubClient) DownloadToFile(p, destPath string, opts ...DownloadOption) error {
+	return s.DownloadToFileContext(context.Background(), p, destPath, opts...)
+}
+
+// DownloadToFileContext is like DownloadToFile but with a context.
+func (s *SubClient) DownloadToFileContext(ctx context.Context, p, destPath string, opts ...DownloadOption) error {
+	f, err := s.file(ctx, p)
+	if err != nil {
+		return err
+	}
+	o := DefaultDownloadOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	info, err := s.c.DownloadWithUAContext(ctx, f.PickCode, o.UserAgent)
+	if err != nil {
+		return err
+	}
+	return s.c.downloadToFile(ctx, info, f.Sha1, destPath, o)
+}
+
+// file gets the file at p, which must not be a directory.
+func (s *SubClient) file(ctx context.Context, p string) (*File, error) {
+	f, err := s.StatContext(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	if f.IsDirectory {
+		return nil, errors.Wrapf(ErrDownloadDirectory, "%s", p)
+	}
+	return f, nil
+}
@@ -0,0 +1,243 @@
+package driver
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SyncDirection is the way ApplySyncPlan syncs, the source side is kept and
+// the other side is made the same.
+type SyncDirection int
+
+const (
+	// SyncToRemote uploads SyncPlan.Upload and deletes SyncPlan.DeleteRemote.
+	SyncToRemote SyncDirection = iota
+	// SyncToLocal downloads SyncPlan.Download and deletes SyncPlan.DeleteLocal.
+	SyncToLocal
+)
+
+// SyncEntry is a file of a SyncPlan.
+type SyncEntry struct {
+	// Path is slash-separated and relative to both roots, like "a/b.txt".
+	Path string
+	// Size is of the local file, or the remote one if there is no local file.
+	Size int64
+	// Remote is the file on 115, nil if it is only local.
+	Remote *File
+}
+
+// SyncPlan is the difference between a local directory and a 115 directory
+// found by DiffDir. A file which is on both sides but differs in size or SHA1
+// is in both Upload and Download. Directories are not compared, empty ones are
+// neither created nor deleted.
+type SyncPlan struct {
+	LocalDir  string
+	RemoteCID string
+	// Upload are the files only local or changed.
+	Upload []SyncEntry
+	// Download are the files only on 115 or changed.
+	Download []SyncEntry
+	// DeleteRemote are the files only on 115.
+	DeleteRemote []SyncEntry
+	// DeleteLocal are the files only local.
+	DeleteLocal []SyncEntry
+}
+
+// DiffDir compares the files of localDir and remoteCID by path, size and SHA1,
+// the local SHA1 is only computed if the sizes are equal.
+func (c *Pan115Client) DiffDir(localDir, remoteCID string) (*SyncPlan, error) {
+	return c.DiffDirContext(context.Background(), localDir, remoteCID)
+}
+
+// DiffDirContext is like DiffDir but with a context.
+func (c *Pan115Client) DiffDirContext(ctx context.Context, localDir, remoteCID string) (*SyncPlan, error) {
+	remote := map[string]*File{}
+	err := c.WalkContext(ctx, remoteCID, func(p string, f *File) error {
+		if !f.IsDirectory {
+			// the walker may reuse f, keep a copy
+			file := *f
+			remote[strings.TrimPrefix(p, "/")] = &file
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &SyncPlan{LocalDir: localDir, RemoteCID: remoteCID}
+	err = filepath.WalkDir(localDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entry := SyncEntry{Path: rel, Size: fi.Size()}
+		f, ok := remote[rel]
+		if !ok {
+			plan.Upload = append(plan.Upload, entry)
+			plan.DeleteLocal = append(plan.DeleteLocal, entry)
+			return nil
+		}
+		delete(remote, rel)
+		entry.Remote = f
+		if f.Size == fi.Size() {
+			sum, err := fileSHA1(p)
+			if err != nil {
+				return err
+			}
+			if strings.EqualFold(sum, f.Sha1) {
+				return nil
+			}
+		}
+		plan.Upload = append(plan.Upload, entry)
+		plan.Download = append(plan.Download, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(remote))
+	for p := range remote {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		entry := SyncEntry{Path: p, Size: remote[p].Size, Remote: remote[p]}
+		plan.Download = append(plan.Download, entry)
+		plan.DeleteRemote = append(plan.DeleteRemote, entry)
+	}
+	return plan, nil
+}
+
+// ApplySyncPlan makes the other side of direction the same as its source by
+// plan. The changed files are replaced. A failed file does not stop the
+// others, a *BatchError listing the failed paths is returned.
+func (c *Pan115Client) ApplySyncPlan(plan *SyncPlan, direction SyncDirection) error {
+	return c.ApplySyncPlanContext(context.Background(), plan, direction)
+}
+
+// ApplySyncPlanContext is like ApplySyncPlan but with a context.
+func (c *Pan115Client) ApplySyncPlanContext(ctx context.Context, plan *SyncPlan, direction SyncDirection) error {
+	batchErr := &BatchError{}
+	switch direction {
+	case SyncToRemote:
+		c.syncToRemote(ctx, plan, batchErr)
+	case SyncToLocal:
+		c.syncToLocal(ctx, plan, batchErr)
+	default:
+		return ErrWrongParams
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return batchErr.orNil()
+}
+
+func (c *Pan115Client) syncToRemote(ctx context.Context, plan *SyncPlan, batchErr *BatchError) {
+	batchErr.Total = len(plan.Upload) + len(plan.DeleteRemote)
+	var root string
+	if len(plan.Upload) > 0 {
+		var err error
+		if root, err = c.dirPath(ctx, plan.RemoteCID); err != nil {
+			for _, e := range plan.Upload {
+				batchErr.add(err, e.Path)
+			}
+		}
+	}
+	for _, e := range plan.Upload {
+		if root == "" || ctx.Err() != nil {
+			break
+		}
+		dirID := plan.RemoteCID
+		if dir := path.Dir(e.Path); dir != "." {
+			var err error
+			if dirID, err = c.mkdirAll(ctx, path.Join(root, dir)); err != nil {
+				batchErr.add(err, e.Path)
+				continue
+			}
+		}
+		if err := c.syncUpload(ctx, filepath.Join(plan.LocalDir, filepath.FromSlash(e.Path)), dirID); err != nil {
+			batchErr.add(err, e.Path)
+		}
+	}
+
+	byParent := map[string][]SyncEntry{}
+	for _, e := range plan.DeleteRemote {
+		byParent[e.Remote.ParentID] = append(byParent[e.Remote.ParentID], e)
+	}
+	for parentID, entries := range byParent {
+		if ctx.Err() != nil {
+			break
+		}
+		ids := make([]string, len(entries))
+		for i, e := range entries {
+			ids[i] = e.Remote.FileID
+		}
+		if err := c.DeleteFilesContext(ctx, parentID, ids...); err != nil {
+			for _, e := range entries {
+				batchErr.add(err, e.Path)
+			}
+		}
+	}
+}
+
+func (c *Pan115Client) syncUpload(ctx context.Context, localPath, dirID string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	_, err = c.UploadFileContext(ctx, dirID, fi.Name(), fi.Size(), f, UploadMultipartWithOnConflict(ConflictOverwrite))
+	return err
+}
+
+func (c *Pan115Client) syncToLocal(ctx context.Context, plan *SyncPlan, batchErr *BatchError) {
+	batchErr.Total = len(plan.Download) + len(plan.DeleteLocal)
+	root, err := filepath.Abs(plan.LocalDir)
+	if err != nil {
+		root = plan.LocalDir
+	}
+	for _, e := range plan.Download {
+		if ctx.Err() != nil {
+			break
+		}
+		localPath := filepath.Join(root, filepath.FromSlash(e.Path))
+		if !strings.HasPrefix(localPath, root+string(filepath.Separator)) {
+			batchErr.add(errors.Wrapf(ErrInvalidFileName, "%s escapes %s", e.Path, plan.LocalDir), e.Path)
+			continue
+		}
+		if err := c.downloadDirFile(ctx, e.Remote, localPath, DefaultDownloadOptions()); err != nil {
+			batchErr.add(err, e.Path)
+		}
+	}
+	for _, e := range plan.DeleteLocal {
+		if err := os.Remove(filepath.Join(plan.LocalDir, filepath.FromSlash(e.Path))); err != nil && !os.IsNotExist(err) {
+			batchErr.add(err, e.Path)
+		}
+	}
+}
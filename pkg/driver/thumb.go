@@ -0,0 +1,78 @@
+package driver
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+)
+
+// ThumbSize is the longer side in pixels of a thumbnail.
+type ThumbSize int
+
+const (
+	ThumbSizeSmall  ThumbSize = 100
+	ThumbSizeMedium ThumbSize = 480
+	ThumbSizeLarge  ThumbSize = 800
+	ThumbSizeXLarge ThumbSize = 1440
+	// ThumbSizeOriginal is the image in its original size.
+	ThumbSizeOriginal ThumbSize = 0
+)
+
+type FileImageResp struct {
+	BasicResp
+	Data struct {
+		URL       string `json:"url"`
+		OriginURL string `json:"origin_url"`
+		FileName  string `json:"file_name"`
+		FileSha1  string `json:"file_sha1"`
+		PickCode  string `json:"pick_code"`
+	} `json:"data"`
+}
+
+// thumbSizeRe matches the size suffix of a thumbnail path, like "_100" in
+// "https://thumb.115.com/thumb/xx/SHA1_100?s=...".
+var thumbSizeRe = regexp.MustCompile(`_\d+(\?|$)`)
+
+// ThumbnailURL returns url resized to size, url is a thumbnail URL like File.Thumbnail.
+// ThumbSizeOriginal has no thumbnail, url is returned unchanged for it.
+func ThumbnailURL(url string, size ThumbSize) string {
+	loc := thumbSizeRe.FindStringIndex(url)
+	if loc == nil || size == ThumbSizeOriginal {
+		return url
+	}
+	end := loc[1]
+	if url[end-1] == '?' {
+		end--
+	}
+	return url[:loc[0]] + "_" + strconv.Itoa(int(size)) + url[end:]
+}
+
+// GetThumbnail gets the thumbnail URL of an image or video in size, it returns
+// ErrNoThumbnail if the thumbnail is not generated yet.
+func (c *Pan115Client) GetThumbnail(pickCode string, size ThumbSize) (string, error) {
+	return c.GetThumbnailContext(context.Background(), pickCode, size)
+}
+
+// GetThumbnailContext is like GetThumbnail but with a context.
+func (c *Pan115Client) GetThumbnailContext(ctx context.Context, pickCode string, size ThumbSize) (string, error) {
+	if pickCode == "" {
+		return "", ErrPickCodeIsEmpty
+	}
+	result := FileImageResp{}
+	req := c.NewRequest().
+		SetContext(ctx).
+		SetQueryParam("pickcode", pickCode).
+		ForceContentType("application/json;charset=UTF-8").
+		SetResult(&result)
+	resp, err := req.Get(ApiFileImage)
+	if err = CheckErr(err, &result, resp); err != nil {
+		return "", err
+	}
+	if size == ThumbSizeOriginal && result.Data.OriginURL != "" {
+		return result.Data.OriginURL, nil
+	}
+	if result.Data.URL == "" {
+		return "", ErrNoThumbnail
+	}
+	return ThumbnailURL(result.Data.URL, size), nil
+}
@@ -0,0 +1,91 @@
+package driver
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// SetTimeout bounds every request of the client to d, from sending it to
+// reading the response, 0 disables it. A request whose context has a deadline
+// keeps it, and downloads use the timeout of SetTransferTimeout instead.
+func (c *Pan115Client) SetTimeout(d time.Duration) *Pan115Client {
+	c.timeout = d
+	c.hookTimeout()
+	return c
+}
+
+// SetTransferTimeout bounds every download of the client to d, including the
+// stream of the body, 0 disables it. OSS uploads are bounded by
+// UploadMultipartWithTimeout.
+func (c *Pan115Client) SetTransferTimeout(d time.Duration) *Pan115Client {
+	c.transferTimeout = d
+	c.hookTimeout()
+	return c
+}
+
+type transferKey struct{}
+
+type timeoutCancelKey struct{}
+
+// withTransfer marks the requests of ctx as transfers.
+func withTransfer(ctx context.Context) context.Context {
+	return context.WithValue(ctx, transferKey{}, true)
+}
+
+// hookTimeout adds the hooks applying the timeouts to the resty client once.
+func (c *Pan115Client) hookTimeout() {
+	if (c.timeout <= 0 && c.transferTimeout <= 0) || c.timeoutHooked == c.Client {
+		return
+	}
+	c.timeoutHooked = c.Client
+	c.Client.OnBeforeRequest(func(_ *resty.Client, req *resty.Request) error {
+		ctx := req.Context()
+		if _, ok := ctx.Deadline(); ok {
+			return nil
+		}
+		d := c.timeout
+		if ctx.Value(transferKey{}) != nil {
+			d = c.transferTimeout
+		}
+		if d <= 0 {
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(ctx, d)
+		req.SetContext(context.WithValue(ctx, timeoutCancelKey{}, cancel))
+		return nil
+	})
+	c.Client.OnSuccess(func(_ *resty.Client, resp *resty.Response) {
+		cancel, ok := resp.Request.Context().Value(timeoutCancelKey{}).(context.CancelFunc)
+		if !ok {
+			return
+		}
+		// the body of an unparsed response is read by the caller after
+		if raw := resp.RawResponse; raw != nil && resp.Body() == nil {
+			raw.Body = &cancelReadCloser{ReadCloser: raw.Body, cancel: cancel}
+			return
+		}
+		cancel()
+	})
+	c.Client.OnError(func(req *resty.Request, _ error) {
+		if cancel, ok := req.Context().Value(timeoutCancelKey{}).(context.CancelFunc); ok {
+			cancel()
+		}
+	})
+}
+
+// cancelReadCloser cancels the context of the request when the body is closed.
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+	once   sync.Once
+}
+
+func (r *cancelReadCloser) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.cancel)
+	return err
+}
@@ -2,6 +2,7 @@ package driver
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"crypto/sha1"
 	"encoding/base64"
@@ -11,6 +12,7 @@ import (
 	"io"
 	"net/url"
 	"os"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
@@ -28,9 +30,71 @@ func (c *Pan115Client) GetDigestResult(r io.Reader) (*hash.DigestResult, error)
 	return &d, hash.Digest(r, &d)
 }
 
+// ComputeUploadDigest streams r and computes the SHA1 of the whole content and
+// the SHA1 of its first 128KB, in upper case HEX as rapid upload expects.
+// The read size is checked against size unless size is negative.
+func ComputeUploadDigest(r io.Reader, size int64) (sha1Hex, preIDHex string, err error) {
+	d := hash.DigestResult{}
+	if err = hash.Digest(r, &d); err != nil {
+		return "", "", err
+	}
+	if size >= 0 && d.Size != size {
+		return "", "", errors.Wrapf(ErrWrongParams, "read %d bytes, expected %d", d.Size, size)
+	}
+	return d.QuickID, d.PreID, nil
+}
+
+// preIDSize is the size of the head which PreID is computed from.
+const preIDSize = 128 * KB
+
+// GetPreID gets the PreID of the file of pickCode, which is the SHA1 of its first
+// 128KB used by rapid upload. Listings only carry the SHA1 of the whole content
+// as File.Sha1, so the head is downloaded by a range request.
+func (c *Pan115Client) GetPreID(pickCode string) (string, error) {
+	return c.GetPreIDContext(context.Background(), pickCode)
+}
+
+// GetPreIDContext is like GetPreID but with a context.
+func (c *Pan115Client) GetPreIDContext(ctx context.Context, pickCode string) (string, error) {
+	info, err := c.DownloadWithUAContext(ctx, pickCode, "")
+	if err != nil {
+		return "", err
+	}
+	return c.preID(ctx, info)
+}
+
+func (c *Pan115Client) preID(ctx context.Context, info *DownloadInfo) (string, error) {
+	h := sha1.New()
+	if size := int64(info.FileSize); size > 0 {
+		o := DefaultDownloadOptions()
+		o.RangeStart, o.RangeEnd = 0, preIDSize-1
+		if size < preIDSize {
+			o.RangeEnd = size - 1
+		}
+		body, _, err := c.downloadStream(ctx, info, o)
+		if errors.Is(err, ErrRangeNotSupported) {
+			body, _, err = c.downloadStream(ctx, info, DefaultDownloadOptions())
+		}
+		if err != nil {
+			return "", err
+		}
+		defer body.Close()
+		if _, err = io.Copy(h, io.LimitReader(body, preIDSize)); err != nil {
+			return "", err
+		}
+	}
+	return strings.ToUpper(hex.EncodeToString(h.Sum(nil))), nil
+}
+
 // GetUploadEndpoint get upload endPoint
 func (c *Pan115Client) GetUploadEndpoint(endpoint *UploadEndpointResp) error {
+	return c.GetUploadEndpointContext(context.Background(), endpoint)
+}
+
+// GetUploadEndpointContext is like GetUploadEndpoint but with a context.
+func (c *Pan115Client) GetUploadEndpointContext(ctx context.Context, endpoint *UploadEndpointResp) error {
 	req := c.NewRequest().
+		SetContext(ctx).
 		ForceContentType("application/json;charset=UTF-8").
 		SetResult(&endpoint)
 	_, err := req.Get(ApiGetUploadEndpoint)
@@ -42,26 +106,53 @@ func (c *Pan115Client) GetUploadEndpoint(endpoint *UploadEndpointResp) error {
 
 // GetUploadInfo get some info for upload
 func (c *Pan115Client) GetUploadInfo() error {
+	return c.GetUploadInfoContext(context.Background())
+}
+
+// GetUploadInfoContext is like GetUploadInfo but with a context.
+func (c *Pan115Client) GetUploadInfoContext(ctx context.Context) error {
 	result := UploadInfoResp{}
 	req := c.NewRequest().
+		SetContext(ctx).
 		ForceContentType("application/json;charset=UTF-8").
 		SetResult(&result)
 	resp, err := req.Post(ApiUploadInfo)
 	if err = CheckErr(err, &result, resp); err != nil {
 		return err
 	}
+	c.userMu.Lock()
 	c.Userkey = result.Userkey
 	c.UserID = result.UserID
 	c.UploadMetaInfo = &result.UploadMetaInfo
+	c.userMu.Unlock()
 	return nil
 }
 
+// uploadUser returns the user id and key set by GetUploadInfo.
+func (c *Pan115Client) uploadUser() (int64, string) {
+	c.userMu.RLock()
+	defer c.userMu.RUnlock()
+	return c.UserID, c.Userkey
+}
+
+// uploadSizeLimit returns the largest file size set by GetUploadInfo.
+func (c *Pan115Client) uploadSizeLimit() int64 {
+	c.userMu.RLock()
+	defer c.userMu.RUnlock()
+	return c.UploadMetaInfo.SizeLimit
+}
+
 // UploadAvailable check and prepare to upload
 func (c *Pan115Client) UploadAvailable() (bool, error) {
-	if c.UserID != 0 && len(c.Userkey) > 0 {
+	return c.UploadAvailableContext(context.Background())
+}
+
+// UploadAvailableContext is like UploadAvailable but with a context.
+func (c *Pan115Client) UploadAvailableContext(ctx context.Context) (bool, error) {
+	if userID, userkey := c.uploadUser(); userID != 0 && len(userkey) > 0 {
 		return true, nil
 	}
-	if err := c.GetUploadInfo(); err != nil {
+	if err := c.GetUploadInfoContext(ctx); err != nil {
 		return false, err
 	}
 	return true, nil
@@ -75,23 +166,28 @@ func (c *Pan115Client) UploadFastOrByOSS(dirID, fileName string, fileSize int64,
 
 // RapidUploadOrByOSS Upload By OSS when unable to rapid upload file
 func (c *Pan115Client) RapidUploadOrByOSS(dirID, fileName string, fileSize int64, r io.ReadSeeker) error {
+	return c.RapidUploadOrByOSSContext(context.Background(), dirID, fileName, fileSize, r)
+}
+
+// RapidUploadOrByOSSContext is like RapidUploadOrByOSS but with a context.
+func (c *Pan115Client) RapidUploadOrByOSSContext(ctx context.Context, dirID, fileName string, fileSize int64, r io.ReadSeeker) error {
 	var (
 		err      error
 		digest   *hash.DigestResult
 		fastInfo *UploadInitResp
 	)
 
-	if ok, err := c.UploadAvailable(); err != nil || !ok {
+	if ok, err := c.UploadAvailableContext(ctx); err != nil || !ok {
 		return err
 	}
-	if fileSize > c.UploadMetaInfo.SizeLimit {
+	if fileSize > c.uploadSizeLimit() {
 		return ErrUploadTooLarge
 	}
 	if digest, err = c.GetDigestResult(r); err != nil {
 		return err
 	}
 	// 闪传
-	if fastInfo, err = c.RapidUpload(
+	if fastInfo, err = c.RapidUploadContext(ctx,
 		digest.Size, fileName, dirID, digest.PreID, digest.QuickID, r,
 	); err != nil {
 		return err
@@ -105,14 +201,14 @@ func (c *Pan115Client) RapidUploadOrByOSS(dirID, fileName string, fileSize int64
 		return err
 	}
 	// 闪传失败，普通上传
-	return c.UploadByOSS(&fastInfo.UploadOSSParams, r, dirID)
+	return c.UploadByOSSContext(ctx, &fastInfo.UploadOSSParams, r, dirID)
 }
 
 // getOSSEndpoint get oss endpoint 利用阿里云内网上传文件，需要在阿里云服务器上运行本程序，同时也需要115在服务器的所在地域开通了阿里云OSS
-func (c *Pan115Client) getOSSEndpoint(enableInternalUpload bool) string {
+func (c *Pan115Client) getOSSEndpoint(ctx context.Context, enableInternalUpload bool) string {
 	if enableInternalUpload {
 		uploadEndpoint := UploadEndpointResp{}
-		if err := c.GetUploadEndpoint(&uploadEndpoint); err != nil {
+		if err := c.GetUploadEndpointContext(ctx, &uploadEndpoint); err != nil {
 			// TODO warn error log
 			return OSSEndpoint
 		}
@@ -127,34 +223,53 @@ func (c *Pan115Client) getOSSEndpoint(enableInternalUpload bool) string {
 
 // GetOSSEndpoint get oss endpoint 利用阿里云内网上传文件，需要在阿里云服务器上运行本程序，同时也需要115在服务器的所在地域开通了阿里云OSS
 func (c *Pan115Client) GetOSSEndpoint(enableInternalUpload bool) string {
-	return c.getOSSEndpoint(enableInternalUpload)
+	return c.getOSSEndpoint(context.Background(), enableInternalUpload)
+}
+
+// newOSSClient creates an oss client which shares the customized http client.
+func (c *Pan115Client) newOSSClient(ctx context.Context, ossToken *UploadOSSTokenResp, options ...oss.ClientOption) (*oss.Client, error) {
+	if c.customTransport {
+		options = append(options, oss.HTTPClient(c.Client.GetClient()))
+	}
+	return oss.New(c.getOSSEndpoint(ctx, c.UseInternalUpload), ossToken.AccessKeyID, ossToken.AccessKeySecret, options...)
 }
 
 // UploadByOSS use aliyun sdk to upload
 func (c *Pan115Client) UploadByOSS(params *UploadOSSParams, r io.Reader, dirID string) error {
-	ossToken, err := c.GetOSSToken()
+	return c.UploadByOSSContext(context.Background(), params, r, dirID)
+}
+
+// UploadByOSSContext is like UploadByOSS but with a context.
+func (c *Pan115Client) UploadByOSSContext(ctx context.Context, params *UploadOSSParams, r io.Reader, dirID string) error {
+	_, err := c.uploadByOSS(ctx, params, r, dirID)
+	return err
+}
+
+// uploadByOSS is like UploadByOSSContext but returns the uploaded file.
+func (c *Pan115Client) uploadByOSS(ctx context.Context, params *UploadOSSParams, r io.Reader, dirID string) (*File, error) {
+	ossToken, err := c.GetOSSTokenContext(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	ossClient, err := oss.New(c.getOSSEndpoint(c.UseInternalUpload), ossToken.AccessKeyID, ossToken.AccessKeySecret)
+	ossClient, err := c.newOSSClient(ctx, ossToken)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	bucket, err := ossClient.Bucket(params.Bucket)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if err = bucket.PutObject(params.Object, r, OssOption(params, ossToken)...); err != nil {
-		return err
+	if err = bucket.PutObject(params.Object, c.limitReader(ctx, r), append(OssOption(params, ossToken), oss.WithContext(ctx))...); err != nil {
+		return nil, err
 	}
-
-	return c.checkUploadStatus(dirID, params.SHA1)
+	c.invalidateCache(dirID)
+	return c.findUploadedFile(ctx, dirID, params.SHA1)
 }
 
-func (c *Pan115Client) checkUploadStatus(dirID, sha1 string) error {
-	// 验证上传是否成功
-	req := c.NewRequest().ForceContentType("application/json;charset=UTF-8")
+// findUploadedFile finds the recently uploaded file by sha1 in dirID.
+func (c *Pan115Client) findUploadedFile(ctx context.Context, dirID, sha1 string) (*File, error) {
+	req := c.NewRequest().SetContext(ctx).ForceContentType("application/json;charset=UTF-8")
 	opts := []GetFileOptions{
 		WithOrder(FileOrderByTime),
 		WithShowDirEnable(false),
@@ -163,20 +278,26 @@ func (c *Pan115Client) checkUploadStatus(dirID, sha1 string) error {
 	}
 	fResp, err := GetFiles(req, dirID, opts...)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	for _, fileInfo := range fResp.Files {
-		if fileInfo.Sha1 == sha1 {
-			return nil
+		if strings.EqualFold(fileInfo.Sha1, sha1) {
+			return (&File{}).from(&fileInfo), nil
 		}
 	}
-	return ErrUploadFailed
+	return nil, ErrUploadFailed
 }
 
 // GetOSSToken get oss token for oss upload
 func (c *Pan115Client) GetOSSToken() (*UploadOSSTokenResp, error) {
+	return c.GetOSSTokenContext(context.Background())
+}
+
+// GetOSSTokenContext is like GetOSSToken but with a context.
+func (c *Pan115Client) GetOSSTokenContext(ctx context.Context) (*UploadOSSTokenResp, error) {
 	result := UploadOSSTokenResp{}
 	req := c.NewRequest().
+		SetContext(ctx).
 		ForceContentType("application/json;charset=UTF-8").
 		SetResult(&result)
 
@@ -192,8 +313,26 @@ func (c *Pan115Client) UploadSHA1(fileSize int64, fileName, dirID, preID, fileID
 
 // RapidUpload rapid upload
 func (c *Pan115Client) RapidUpload(fileSize int64, fileName, dirID, preID, fileID string, r io.ReadSeeker) (*UploadInitResp, error) {
+	return c.RapidUploadContext(context.Background(), fileSize, fileName, dirID, preID, fileID, r)
+}
+
+// uploadCipher encrypts the upload init requests and decrypts their responses.
+type uploadCipher interface {
+	EncodeToken(timestamp int64) (string, error)
+	Encrypt(plainText []byte) ([]byte, error)
+	Decrypt(cipherText []byte) ([]byte, error)
+}
+
+// newUploadCipher creates the cipher of an upload init, tests replace it as
+// only 115 can answer ECDH.
+var newUploadCipher = func() (uploadCipher, error) {
+	return cipher.NewEcdhCipher()
+}
+
+// RapidUploadContext is like RapidUpload but with a context.
+func (c *Pan115Client) RapidUploadContext(ctx context.Context, fileSize int64, fileName, dirID, preID, fileID string, r io.ReadSeeker) (*UploadInitResp, error) {
 	var (
-		ecdhCipher   *cipher.EcdhCipher
+		ecdhCipher   uploadCipher
 		encrypted    []byte
 		decrypted    []byte
 		encodedToken string
@@ -203,15 +342,16 @@ func (c *Pan115Client) RapidUpload(fileSize int64, fileName, dirID, preID, fileI
 		result       = UploadInitResp{}
 		fileSizeStr  = strconv.FormatInt(fileSize, 10)
 	)
-	if ecdhCipher, err = cipher.NewEcdhCipher(); err != nil {
+	if ecdhCipher, err = newUploadCipher(); err != nil {
 		return nil, err
 	}
 
-	if ok, err := c.UploadAvailable(); !ok || err != nil {
+	if ok, err := c.UploadAvailableContext(ctx); !ok || err != nil {
 		return nil, err
 	}
 
-	userID := strconv.FormatInt(c.UserID, 10)
+	uid, _ := c.uploadUser()
+	userID := strconv.FormatInt(uid, 10)
 	form := url.Values{}
 	form.Set("appid", "0")
 	form.Set("appversion", appVer)
@@ -246,6 +386,7 @@ func (c *Pan115Client) RapidUpload(fileSize int64, fileName, dirID, preID, fileI
 		}
 
 		req := c.NewRequest().
+			SetContext(ctx).
 			SetQueryParams(params).
 			SetBody(encrypted).
 			SetHeaderVerbatim("Content-Type", "application/x-www-form-urlencoded").
@@ -266,6 +407,9 @@ func (c *Pan115Client) RapidUpload(fileSize int64, fileName, dirID, preID, fileI
 			return nil, err
 		}
 		if result.Status == 7 {
+			if r == nil {
+				return nil, errors.Wrap(ErrWrongParams, "file content is required by sign check")
+			}
 			// Update signKey & signVal
 			signKey = result.SignKey
 			signVal, _ = c.UploadDigestRange(r, result.SignCheck)
@@ -274,10 +418,41 @@ func (c *Pan115Client) RapidUpload(fileSize int64, fileName, dirID, preID, fileI
 		}
 		result.SHA1 = fileID
 	}
-
+	c.invalidateCache(dirID)
 	return &result, nil
 }
 
+// TryRapidUpload tries to upload a file by its SHA1 and pre-ID only, nothing is
+// transferred if it is not hit. The server may ask to check a range of the
+// file content, which is read from r. If it is hit but the uploaded file can
+// not be found, hit is true and the error of the lookup is returned with a nil
+// file.
+func (c *Pan115Client) TryRapidUpload(fileSize int64, sha1, preID, dirID, fileName string, r io.ReadSeeker) (hit bool, file *File, err error) {
+	return c.TryRapidUploadContext(context.Background(), fileSize, sha1, preID, dirID, fileName, r)
+}
+
+// TryRapidUploadContext is like TryRapidUpload but with a context.
+func (c *Pan115Client) TryRapidUploadContext(ctx context.Context, fileSize int64, sha1, preID, dirID, fileName string, r io.ReadSeeker) (hit bool, file *File, err error) {
+	if ok, err := c.UploadAvailableContext(ctx); err != nil || !ok {
+		return false, nil, err
+	}
+	if fileSize > c.uploadSizeLimit() {
+		return false, nil, ErrUploadTooLarge
+	}
+	sha1, preID = strings.ToUpper(sha1), strings.ToUpper(preID)
+	fastInfo, err := c.RapidUploadContext(ctx, fileSize, fileName, dirID, preID, sha1, r)
+	if err != nil {
+		return false, nil, err
+	}
+	if hit, err = fastInfo.Ok(); !hit || err != nil {
+		return false, nil, err
+	}
+	if file, err = c.findUploadedFile(ctx, dirID, sha1); err != nil {
+		return true, nil, err
+	}
+	return true, file, nil
+}
+
 const (
 	md5Salt = "Qclm8MGWUv59TnrR0XPg"
 	appVer  = "27.0.5.7"
@@ -301,14 +476,16 @@ func (c *Pan115Client) UploadDigestRange(r io.ReadSeeker, rangeSpec string) (res
 }
 
 func (c *Pan115Client) GenerateSignature(fileID, target string) string {
-	sh1hash := sha1.Sum([]byte(strconv.FormatInt(c.UserID, 10) + fileID + target + "0"))
-	sigStr := c.Userkey + hex.EncodeToString(sh1hash[:]) + "000000"
+	userID, userkey := c.uploadUser()
+	sh1hash := sha1.Sum([]byte(strconv.FormatInt(userID, 10) + fileID + target + "0"))
+	sigStr := userkey + hex.EncodeToString(sh1hash[:]) + "000000"
 	sh1Sig := sha1.Sum([]byte(sigStr))
 	return strings.ToUpper(hex.EncodeToString(sh1Sig[:]))
 }
 
 func (c *Pan115Client) GenerateToken(fileID, preID, timeStamp, fileSize, signKey, signVal string) string {
-	userID := strconv.FormatInt(c.UserID, 10)
+	uid, _ := c.uploadUser()
+	userID := strconv.FormatInt(uid, 10)
 	userIDMd5 := md5.Sum([]byte(userID))
 	tokenMd5 := md5.Sum([]byte(md5Salt + fileID + fileSize + signKey + signVal + userID + timeStamp + hex.EncodeToString(userIDMd5[:]) + appVer))
 	return hex.EncodeToString(tokenMd5[:])
@@ -322,51 +499,210 @@ func (c *Pan115Client) UploadFastOrByMultipart(dirID, fileName string, fileSize
 
 // RapidUploadOrByMultipart upload by mutipart blocks when unable to rapid upload
 func (c *Pan115Client) RapidUploadOrByMultipart(dirID, fileName string, fileSize int64, r *os.File, opts ...UploadMultipartOption) error {
+	return c.RapidUploadOrByMultipartContext(context.Background(), dirID, fileName, fileSize, r, opts...)
+}
+
+// RapidUploadOrByMultipartContext is like RapidUploadOrByMultipart but with a context.
+func (c *Pan115Client) RapidUploadOrByMultipartContext(ctx context.Context, dirID, fileName string, fileSize int64, r *os.File, opts ...UploadMultipartOption) error {
+	_, err := c.rapidUploadOrByMultipart(ctx, dirID, fileName, fileSize, r, false, opts...)
+	return err
+}
+
+// UploadMethod is the way a file was uploaded.
+type UploadMethod int
+
+const (
+	// UploadMethodRapid means the content was already on 115, nothing was transferred.
+	UploadMethodRapid UploadMethod = iota + 1
+	// UploadMethodOSS means the content was put to OSS at once.
+	UploadMethodOSS
+	// UploadMethodMultipart means the content was uploaded to OSS part by part.
+	UploadMethodMultipart
+	// UploadMethodSkipped means a file of the same name existed, see ConflictSkip.
+	UploadMethodSkipped
+)
+
+func (m UploadMethod) String() string {
+	switch m {
+	case UploadMethodRapid:
+		return "rapid"
+	case UploadMethodOSS:
+		return "oss"
+	case UploadMethodMultipart:
+		return "multipart"
+	case UploadMethodSkipped:
+		return "skipped"
+	}
+	return "unknown"
+}
+
+// UploadReport reports how a file was uploaded.
+type UploadReport struct {
+	File             *File
+	Method           UploadMethod
+	BytesTransferred int64
+}
+
+// UploadFile is like RapidUploadOrByMultipart but reports the uploaded file and
+// whether it was rapid uploaded or transferred.
+func (c *Pan115Client) UploadFile(dirID, fileName string, fileSize int64, r *os.File, opts ...UploadMultipartOption) (*UploadReport, error) {
+	return c.UploadFileContext(context.Background(), dirID, fileName, fileSize, r, opts...)
+}
+
+// UploadFileContext is like UploadFile but with a context.
+func (c *Pan115Client) UploadFileContext(ctx context.Context, dirID, fileName string, fileSize int64, r *os.File, opts ...UploadMultipartOption) (*UploadReport, error) {
+	return c.rapidUploadOrByMultipart(ctx, dirID, fileName, fileSize, r, true, opts...)
+}
+
+// ConflictPolicy is what to do when a file of the same name exists in the
+// directory uploaded into.
+type ConflictPolicy int
+
+const (
+	// ConflictAllow uploads anyway, a directory may have files of the same name.
+	ConflictAllow ConflictPolicy = iota
+	// ConflictSkip uploads nothing and reports the existing file.
+	ConflictSkip
+	// ConflictOverwrite deletes the existing file into the recycle bin first.
+	ConflictOverwrite
+	// ConflictRename uploads as name (1), name (2) and so on.
+	ConflictRename
+	// ConflictError fails with ErrExist.
+	ConflictError
+)
+
+// findFileByName returns the file named name in dirID, nil if not found.
+func (c *Pan115Client) findFileByName(ctx context.Context, dirID, name string) (*File, error) {
+	files, err := c.listAllFiles(ctx, dirID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range files {
+		if !files[i].IsDirectory && files[i].Name == name {
+			return &files[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// freeFileName returns name with the smallest numeric suffix not used in dirID.
+func (c *Pan115Client) freeFileName(ctx context.Context, dirID, name string) (string, error) {
+	files, err := c.listAllFiles(ctx, dirID)
+	if err != nil {
+		return "", err
+	}
+	used := make(map[string]bool, len(files))
+	for _, f := range files {
+		used[f.Name] = true
+	}
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		if n := fmt.Sprintf("%s (%d)%s", base, i, ext); !used[n] {
+			return n, nil
+		}
+	}
+}
+
+// rapidUploadOrByMultipart uploads r, the uploaded file is looked up only if find is set.
+func (c *Pan115Client) rapidUploadOrByMultipart(ctx context.Context, dirID, fileName string, fileSize int64, r *os.File, find bool, opts ...UploadMultipartOption) (*UploadReport, error) {
 	var (
 		err      error
 		digest   *hash.DigestResult
 		fastInfo *UploadInitResp
 	)
 
-	if ok, err := c.UploadAvailable(); err != nil || !ok {
-		return err
+	if ok, err := c.UploadAvailableContext(ctx); err != nil || !ok {
+		return nil, err
 	}
-	if fileSize > c.UploadMetaInfo.SizeLimit {
-		return ErrUploadTooLarge
+	if fileSize > c.uploadSizeLimit() {
+		return nil, ErrUploadTooLarge
+	}
+	options := DefalutUploadMultipartOptions()
+	for _, f := range opts {
+		f(options)
+	}
+	if options.OnConflict != ConflictAllow {
+		existing, err := c.findFileByName(ctx, dirID, fileName)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			switch options.OnConflict {
+			case ConflictSkip:
+				return &UploadReport{File: existing, Method: UploadMethodSkipped}, nil
+			case ConflictOverwrite:
+				if err = c.DeleteContext(ctx, existing.FileID); err != nil {
+					return nil, err
+				}
+				c.invalidatePathIDs(existing.FileID)
+			case ConflictRename:
+				if fileName, err = c.freeFileName(ctx, dirID, fileName); err != nil {
+					return nil, err
+				}
+			default:
+				return nil, errors.Wrapf(ErrExist, "%s in %s", fileName, dirID)
+			}
+		}
 	}
 	if digest, err = c.GetDigestResult(r); err != nil {
-		return err
+		return nil, err
 	}
 	// 闪传
-	if fastInfo, err = c.RapidUpload(
+	if fastInfo, err = c.RapidUploadContext(ctx,
 		digest.Size, fileName, dirID, digest.PreID, digest.QuickID, r,
 	); err != nil {
-		return err
+		return nil, err
 	}
+	report := &UploadReport{Method: UploadMethodRapid}
 	if ok, err := fastInfo.Ok(); err != nil {
-		return err
-	} else if ok {
-		return nil
+		return nil, err
+	} else if !ok {
+		if _, err = r.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		// 闪传失败，上传
+		report.BytesTransferred = digest.Size
+		if digest.Size <= KB { // 文件大小小于1KB，改用普通模式上传
+			report.Method = UploadMethodOSS
+			if report.File, err = c.uploadByOSS(ctx, &fastInfo.UploadOSSParams, r, dirID); err != nil {
+				return report, err
+			}
+		} else {
+			// 分片上传
+			report.Method = UploadMethodMultipart
+			if err = c.UploadByMultipartContext(ctx, &fastInfo.UploadOSSParams, digest.Size, r, dirID, opts...); err != nil {
+				return nil, err
+			}
+		}
 	}
-	if _, err = r.Seek(0, io.SeekStart); err != nil {
-		return err
+	if report.File == nil && (find || !options.ModTime.IsZero()) {
+		if report.File, err = c.findUploadedFile(ctx, dirID, digest.QuickID); err != nil {
+			return nil, err
+		}
 	}
-
-	// 闪传失败，上传
-	if digest.Size <= KB { // 文件大小小于1KB，改用普通模式上传
-		return c.UploadByOSS(&fastInfo.UploadOSSParams, r, dirID)
+	if !options.ModTime.IsZero() {
+		if err = c.SetFileTimeContext(ctx, report.File.FileID, options.ModTime); err != nil {
+			return report, errors.Wrap(err, "file is uploaded but its time is not set")
+		}
+		report.File.UpdateTime = options.ModTime
 	}
-	// 分片上传
-	return c.UploadByMultipart(&fastInfo.UploadOSSParams, digest.Size, r, dirID, opts...)
+	return report, nil
 }
 
 // UploadByMultipart upload by mutipart blocks
 func (c *Pan115Client) UploadByMultipart(params *UploadOSSParams, fileSize int64, f *os.File, dirID string, opts ...UploadMultipartOption) error {
+	return c.UploadByMultipartContext(context.Background(), params, fileSize, f, dirID, opts...)
+}
+
+// UploadByMultipartContext is like UploadByMultipart but with a context.
+// Canceling ctx aborts the in-flight part and the multipart upload.
+func (c *Pan115Client) UploadByMultipartContext(ctx context.Context, params *UploadOSSParams, fileSize int64, f *os.File, dirID string, opts ...UploadMultipartOption) error {
 	var (
 		chunks    []oss.FileChunk
 		parts     []oss.UploadPart
 		imur      oss.InitiateMultipartUploadResult
-		ossClient *oss.Client
+		sess      *ossSession
 		bucket    *oss.Bucket
 		ossToken  *UploadOSSTokenResp
 		bodyBytes []byte
@@ -380,24 +716,26 @@ func (c *Pan115Client) UploadByMultipart(params *UploadOSSParams, fileSize int64
 		}
 	}
 
-	options.ThreadsNum = 1
-	if ossToken, err = c.GetOSSToken(); err != nil {
+	if fileSize == 0 {
+		// an empty file has no part, upload it in one put
+		_, err = c.uploadByOSS(ctx, params, f, dirID)
 		return err
 	}
 
-	if ossClient, err = oss.New(
-		c.getOSSEndpoint(c.UseInternalUpload),
-		ossToken.AccessKeyID,
-		ossToken.AccessKeySecret,
+	// oss 启用Sequential必须按顺序上传，并发上传时不启用
+	threads := options.PartConcurrency
+	if threads < 1 {
+		threads = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if sess, err = c.newOSSSession(ctx, params.Bucket, options.TokenRefreshMargin,
 		oss.EnableMD5(true),
 		oss.EnableCRC(true),
 	); err != nil {
 		return err
 	}
-
-	if bucket, err = ossClient.Bucket(params.Bucket); err != nil {
-		return err
-	}
+	bucket, ossToken = sess.bucket, sess.token
 
 	// ossToken一小时后就会失效，所以每50分钟重新获取一次
 	ticker := time.NewTicker(options.TokenRefreshTime)
@@ -405,19 +743,69 @@ func (c *Pan115Client) UploadByMultipart(params *UploadOSSParams, fileSize int64
 	// 设置超时
 	timeout := time.NewTimer(options.Timeout)
 
-	if chunks, err = SplitFile(f.Name(), fileSize); err != nil {
+	partSize := options.PartSize
+	if partSize == 0 {
+		partSize = ComputePartSize(fileSize)
+	} else if err = checkPartSize(partSize, fileSize); err != nil {
 		return err
 	}
-
-	if imur, err = bucket.InitiateMultipartUpload(params.Object,
-		oss.SetHeader(OssSecurityTokenHeaderName, ossToken.SecurityToken),
-		oss.UserAgentHeader(OSSUserAgent),
-		oss.EnableSha1(),
-		oss.Sequential(), // oss 启用Sequential必须按顺序上传, options.ThreadsNum = 1
-	); err != nil {
+	if chunks, err = oss.SplitFileByPartSize(f.Name(), partSize); err != nil {
 		return err
 	}
 
+	sizes := make(map[int]int64, len(chunks))
+	for _, chunk := range chunks {
+		sizes[chunk.Number] = chunk.Size
+	}
+	prog := newProgress(options.OnProgress, fileSize, options.ProgressEvery)
+
+	state := &ResumeState{Bucket: params.Bucket, Object: params.Object}
+	if options.Resume != nil {
+		// 断点续传，跳过已上传的分片
+		if imur, parts, err = resumeMultipartUpload(bucket, params, options.Resume,
+			oss.SetHeader(OssSecurityTokenHeaderName, ossToken.SecurityToken),
+			oss.UserAgentHeader(OSSUserAgent),
+			oss.WithContext(ctx),
+		); err != nil {
+			return err
+		}
+		done := make(map[int]bool, len(parts))
+		for _, part := range parts {
+			done[part.PartNumber] = true
+			prog.skip(sizes[part.PartNumber])
+			state.Parts = append(state.Parts, ResumePart{Number: part.PartNumber, ETag: part.ETag})
+		}
+		remain := chunks[:0]
+		for _, chunk := range chunks {
+			if !done[chunk.Number] {
+				remain = append(remain, chunk)
+			}
+		}
+		chunks = remain
+	}
+
+	if imur.UploadID == "" {
+		parts, state.Parts = nil, nil
+		initOptions := []oss.Option{
+			oss.SetHeader(OssSecurityTokenHeaderName, ossToken.SecurityToken),
+			oss.UserAgentHeader(OSSUserAgent),
+			oss.EnableSha1(),
+			oss.WithContext(ctx),
+		}
+		if threads == 1 {
+			initOptions = append(initOptions, oss.Sequential())
+		}
+		if imur, err = bucket.InitiateMultipartUpload(params.Object, initOptions...); err != nil {
+			return err
+		}
+	}
+	state.UploadID = imur.UploadID
+	if options.Resume != nil {
+		if err = SaveResumeState(options.Resume, state); err != nil {
+			return err
+		}
+	}
+
 	wg := sync.WaitGroup{}
 	wg.Add(len(chunks))
 
@@ -427,14 +815,14 @@ func (c *Pan115Client) UploadByMultipart(params *UploadOSSParams, fileSize int64
 	quit := make(chan struct{})
 
 	// producter
-	go chunksProducer(chunksCh, chunks)
+	go chunksProducer(ctx, chunksCh, chunks)
 	go func() {
 		wg.Wait()
 		quit <- struct{}{}
 	}()
 
-	// consumers
-	for i := 0; i < options.ThreadsNum; i++ {
+	// consumers, each holds the buffer of one part at a time
+	for i := 0; i < threads; i++ {
 		go func(threadId int) {
 			defer func() {
 				if r := recover(); r != nil {
@@ -442,14 +830,20 @@ func (c *Pan115Client) UploadByMultipart(params *UploadOSSParams, fileSize int64
 				}
 			}()
 			for chunk := range chunksCh {
-				var part oss.UploadPart // 出现错误就继续尝试，共尝试3次
+				var (
+					part   oss.UploadPart
+					bucket *oss.Bucket
+					token  *UploadOSSTokenResp
+					err    error
+				)
+				// 出现错误就继续尝试，共尝试3次
 				for retry := 0; retry < 3; retry++ {
-					select {
-					case <-ticker.C:
-						if ossToken, err = c.GetOSSToken(); err != nil { // 到时重新获取ossToken
-							errCh <- errors.Wrap(err, "刷新token时出现错误")
-						}
-					default:
+					if err = ctx.Err(); err != nil {
+						break
+					}
+					// 临近过期时重新获取ossToken
+					if bucket, token, err = sess.get(); err != nil {
+						break
 					}
 
 					buf := make([]byte, chunk.Size)
@@ -459,17 +853,31 @@ func (c *Pan115Client) UploadByMultipart(params *UploadOSSParams, fileSize int64
 
 					if part, err = bucket.UploadPart(
 						imur,
-						bytes.NewBuffer(buf),
+						c.limitReader(ctx, bytes.NewBuffer(buf)),
 						chunk.Size,
 						chunk.Number,
-						OssOption(params, ossToken)...); err == nil {
+						append(OssOption(params, token), oss.WithContext(ctx))...); err == nil {
 						break
 					}
+					if isOSSTokenExpired(err) {
+						// ossToken已过期，重新获取后重试该分片
+						if err = sess.refresh(); err != nil {
+							break
+						}
+					}
 				}
 				if err != nil {
-					errCh <- errors.Wrap(err, fmt.Sprintf("上传 %s 的第%d个分片时出现错误：%v", f.Name(), chunk.Number, err))
+					select {
+					case errCh <- errors.Wrap(err, fmt.Sprintf("上传 %s 的第%d个分片时出现错误：%v", f.Name(), chunk.Number, err)):
+					case <-ctx.Done():
+					}
+					return
+				}
+				select {
+				case UploadedPartsCh <- part:
+				case <-ctx.Done():
+					return
 				}
-				UploadedPartsCh <- part
 			}
 		}(i)
 	}
@@ -477,6 +885,11 @@ func (c *Pan115Client) UploadByMultipart(params *UploadOSSParams, fileSize int64
 	go func() {
 		for part := range UploadedPartsCh {
 			parts = append(parts, part)
+			prog.add(sizes[part.PartNumber])
+			if options.Resume != nil {
+				state.Parts = append(state.Parts, ResumePart{Number: part.PartNumber, ETag: part.ETag})
+				_ = SaveResumeState(options.Resume, state)
+			}
 			wg.Done()
 		}
 	}()
@@ -485,25 +898,46 @@ LOOP:
 		select {
 		case <-ticker.C:
 			// 到时重新获取ossToken
-			if ossToken, err = c.GetOSSToken(); err != nil {
+			if err = sess.refresh(); err != nil {
 				return err
 			}
 		case <-quit:
 			break LOOP
-		case <-errCh:
+		case err = <-errCh:
 			return err
 		case <-timeout.C:
 			return fmt.Errorf("time out")
+		case <-ctx.Done():
+			// 取消上传，清理已上传的分片；断点续传时保留分片以便下次继续
+			if options.Resume == nil {
+				if bucket, ossToken, err = sess.get(); err == nil {
+					_ = bucket.AbortMultipartUpload(imur,
+						oss.SetHeader(OssSecurityTokenHeaderName, ossToken.SecurityToken),
+						oss.UserAgentHeader(OSSUserAgent),
+					)
+				}
+			}
+			return ctx.Err()
 		}
 	}
 
+	if bucket, ossToken, err = sess.get(); err != nil {
+		return err
+	}
 	if _, err := bucket.CompleteMultipartUpload(imur, parts,
 		append(
 			OssOption(params, ossToken),
 			oss.CallbackResult(&bodyBytes),
+			oss.WithContext(ctx),
 		)...); err != nil {
 		return err
 	}
+	c.invalidateCache(dirID)
+
+	if options.Resume != nil {
+		// 上传完成，清除断点
+		_ = SaveResumeState(options.Resume, &ResumeState{})
+	}
 
 	var uploadResult UploadResult
 	if err = json.Unmarshal(bodyBytes, &uploadResult); err != nil {
@@ -512,34 +946,60 @@ LOOP:
 	return uploadResult.Err(string(bodyBytes))
 }
 
-func chunksProducer(ch chan oss.FileChunk, chunks []oss.FileChunk) {
+func chunksProducer(ctx context.Context, ch chan oss.FileChunk, chunks []oss.FileChunk) {
 	for _, chunk := range chunks {
-		ch <- chunk
+		select {
+		case ch <- chunk:
+		case <-ctx.Done():
+			return
+		}
 	}
 }
 
-// SplitFile pplitFile
-func SplitFile(filePath string, fileSize int64) (chunks []oss.FileChunk, err error) {
+const (
+	// MinPartSize is the min size of a part except the last one.
+	MinPartSize = 100 * KB
+	// MaxPartSize is the max size of a part.
+	MaxPartSize = 5 * GB
+	// MaxPartCount is the max number of parts of a multipart upload.
+	MaxPartCount = 10000
+)
+
+// ComputePartSize computes the part size of the multipart upload of a file of
+// fileSize, which is the default of UploadMultipartOptions.PartSize.
+func ComputePartSize(fileSize int64) int64 {
+	// 文件大小小于iGB时分为i*1000片，最多10000片
+	parts := int64(MaxPartCount)
 	for i := int64(1); i < 10; i++ {
-		if fileSize < i*GB { // 文件大小小于iGB时分为i*1000片
-			if chunks, err = oss.SplitFileByPartNum(filePath, int(i*1000)); err != nil {
-				return
-			}
+		if fileSize < i*GB {
+			parts = i * 1000
 			break
 		}
 	}
-	if fileSize > 9*GB { // 文件大小大于9GB时分为10000片
-		if chunks, err = oss.SplitFileByPartNum(filePath, 10000); err != nil {
-			return
-		}
-	}
+	size := (fileSize + parts - 1) / parts
 	// 单个分片大小不能小于100KB
-	if chunks[0].Size < 100*KB {
-		if chunks, err = oss.SplitFileByPartSize(filePath, 100*KB); err != nil {
-			return
-		}
+	if size < MinPartSize {
+		size = MinPartSize
 	}
-	return
+	return size
+}
+
+func checkPartSize(partSize, fileSize int64) error {
+	if partSize < MinPartSize || partSize > MaxPartSize {
+		return errors.Wrapf(ErrWrongParams, "part size %d out of [%d, %d]", partSize, MinPartSize, int64(MaxPartSize))
+	}
+	if (fileSize+partSize-1)/partSize > MaxPartCount {
+		return errors.Wrapf(ErrWrongParams, "part size %d makes more than %d parts", partSize, MaxPartCount)
+	}
+	return nil
+}
+
+// SplitFile splits the file of fileSize into parts of ComputePartSize.
+func SplitFile(filePath string, fileSize int64) (chunks []oss.FileChunk, err error) {
+	if fileSize <= 0 {
+		return nil, errors.Wrap(ErrWrongParams, "can not split an empty file")
+	}
+	return oss.SplitFileByPartSize(filePath, ComputePartSize(fileSize))
 }
 
 // OssOption get options
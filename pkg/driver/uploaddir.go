@@ -0,0 +1,178 @@
+package driver
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// UploadDir uploads the local directory tree of localDir into destCID
+// concurrently, mirroring its structure by MkdirAll. Each file is uploaded by
+// UploadFile, so the rapid upload is tried first. A failed file does not stop
+// the others, a *BatchError listing the failed paths relative to localDir is
+// returned.
+func (c *Pan115Client) UploadDir(localDir, destCID string, opts ...UploadDirOption) error {
+	return c.UploadDirContext(context.Background(), localDir, destCID, opts...)
+}
+
+// UploadDirContext is like UploadDir but with a context.
+func (c *Pan115Client) UploadDirContext(ctx context.Context, localDir, destCID string, opts ...UploadDirOption) error {
+	o := DefaultUploadDirOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.Concurrency < 1 {
+		o.Concurrency = 1
+	}
+	for _, pattern := range o.Ignore {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Wrapf(ErrWrongParams, "ignore pattern %q", pattern)
+		}
+	}
+	destPath, err := c.dirPath(ctx, destCID)
+	if err != nil {
+		return err
+	}
+
+	type job struct {
+		rel      string
+		dirID    string
+		existing *File
+	}
+	jobs := make(chan job)
+	batchErr := &BatchError{}
+	var mu sync.Mutex
+	fail := func(err error, rel string) {
+		mu.Lock()
+		defer mu.Unlock()
+		batchErr.add(err, rel)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < o.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				localPath := filepath.Join(localDir, filepath.FromSlash(j.rel))
+				if err := c.uploadDirFile(ctx, localPath, j.dirID, j.existing, o); err != nil {
+					fail(err, j.rel)
+				}
+			}
+		}()
+	}
+
+	dirIDs := map[string]string{}
+	existing := map[string]map[string]*File{}
+	walkErr := filepath.WalkDir(localDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "." && o.ignored(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			cid := destCID
+			if rel != "." {
+				if cid, err = c.mkdirAll(ctx, path.Join(destPath, rel)); err != nil {
+					fail(err, rel)
+					return filepath.SkipDir
+				}
+			}
+			dirIDs[rel] = cid
+			if o.SkipExisting {
+				files, err := c.listAllFiles(ctx, cid)
+				if err != nil {
+					fail(err, rel)
+					return filepath.SkipDir
+				}
+				names := make(map[string]*File, len(files))
+				for i := range files {
+					if !files[i].IsDirectory {
+						names[files[i].Name] = &files[i]
+					}
+				}
+				existing[rel] = names
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		dir := path.Dir(rel)
+		mu.Lock()
+		batchErr.Total++
+		mu.Unlock()
+		select {
+		case jobs <- job{rel: rel, dirID: dirIDs[dir], existing: existing[dir][d.Name()]}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(jobs)
+	wg.Wait()
+	if walkErr != nil {
+		return walkErr
+	}
+	return batchErr.orNil()
+}
+
+// uploadDirFile uploads localPath into dirID unless existing has the same SHA1.
+func (c *Pan115Client) uploadDirFile(ctx context.Context, localPath, dirID string, existing *File, o *UploadDirOptions) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Size == fi.Size() && existing.Sha1 != "" {
+		if sum, err := fileSHA1(localPath); err == nil && strings.EqualFold(sum, existing.Sha1) {
+			return nil
+		}
+	}
+	_, err = c.UploadFileContext(ctx, dirID, fi.Name(), fi.Size(), f, o.UploadOptions...)
+	return err
+}
+
+// dirPath gets the absolute path of the directory cid.
+func (c *Pan115Client) dirPath(ctx context.Context, cid string) (string, error) {
+	if cid == "" || cid == "0" {
+		return "/", nil
+	}
+	node, err := c.dirNode(ctx, cid)
+	if err != nil {
+		return "", err
+	}
+	return c.ResolvePathContext(ctx, &File{FileID: cid, Name: node.name, ParentID: node.parent, IsDirectory: true})
+}
+
+// ignored reports whether rel or its name matches one of the ignore patterns.
+func (o *UploadDirOptions) ignored(rel string) bool {
+	for _, pattern := range o.Ignore {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
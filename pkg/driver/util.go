@@ -1,10 +1,12 @@
 package driver
 
 import (
+	"path"
 	"runtime"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 )
 
 type Time int64
@@ -32,11 +34,78 @@ func Date() string {
 }
 
 func isCalledByAlistV3() bool {
-	pc, _, _, ok := runtime.Caller(3)
-	if !ok {
-		return false
+	// methods without context delegate to their Context variants,
+	// so look a few frames up instead of a fixed depth.
+	for skip := 2; skip <= 4; skip++ {
+		pc, _, _, ok := runtime.Caller(skip)
+		if !ok {
+			return false
+		}
+		if strings.Contains(runtime.FuncForPC(pc).Name(), "alist") {
+			return true
+		}
 	}
+	return false
+}
+
+// NaturalLess reports whether a sorts before b in natural order like the
+// natsort of 115: digit runs compare by numeric value, other characters compare
+// case-insensitively by code point, and ties are broken by the exact strings.
+func NaturalLess(a, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	i, j := 0, 0
+	for i < len(ra) && j < len(rb) {
+		if isDigit(ra[i]) && isDigit(rb[j]) {
+			si, sj := i, j
+			for i < len(ra) && isDigit(ra[i]) {
+				i++
+			}
+			for j < len(rb) && isDigit(rb[j]) {
+				j++
+			}
+			na := strings.TrimLeft(string(ra[si:i]), "0")
+			nb := strings.TrimLeft(string(rb[sj:j]), "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			continue
+		}
+		ca, cb := unicode.ToLower(ra[i]), unicode.ToLower(rb[j])
+		if ca != cb {
+			return ca < cb
+		}
+		i++
+		j++
+	}
+	if len(ra)-i != len(rb)-j {
+		return len(ra)-i < len(rb)-j
+	}
+	return a < b
+}
 
-	funcName := runtime.FuncForPC(pc).Name()
-	return strings.Contains(funcName, "alist")
+func isDigit(r rune) bool {
+	return '0' <= r && r <= '9'
+}
+
+// FilterByExt returns the files whose extension is one of exts, ignoring case.
+// An extension may be given with or without the dot, like ".mkv" or "mkv".
+// Directories are dropped.
+func FilterByExt(files []File, exts ...string) []File {
+	set := make(map[string]struct{}, len(exts))
+	for _, ext := range exts {
+		set[strings.ToLower(strings.TrimPrefix(ext, "."))] = struct{}{}
+	}
+	var filtered []File
+	for _, f := range files {
+		if f.IsDirectory {
+			continue
+		}
+		if _, ok := set[strings.ToLower(strings.TrimPrefix(path.Ext(f.Name), "."))]; ok {
+			filtered = append(filtered, f)
+		}
+	}
+	return filtered
 }
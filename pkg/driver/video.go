@@ -0,0 +1,383 @@
+package driver
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Variant is a stream of a HLS master playlist.
+type Variant struct {
+	// Bandwidth is the peak bit rate in bits per second.
+	Bandwidth int64
+	Width     int
+	Height    int
+	Codecs    string
+	// Name is the NAME attribute, 115 uses it for the definition like "UD".
+	Name string
+	URL  string
+}
+
+// M3U8Playlist is a parsed HLS master playlist.
+type M3U8Playlist struct {
+	URL      string
+	Variants []Variant
+}
+
+// TranscodeStatus is the transcoding status of a video.
+type TranscodeStatus struct {
+	// Ready is true if the video is transcoded and can be played by m3u8.
+	Ready      bool
+	FileStatus int
+	Duration   time.Duration
+	Width      int
+	Height     int
+}
+
+type VideoInfoResp struct {
+	BasicResp
+	FileStatus StringInt     `json:"file_status"`
+	PlayLong   StringFloat64 `json:"play_long"`
+	Width      StringInt     `json:"width"`
+	Height     StringInt     `json:"height"`
+	VideoURL   string        `json:"video_url"`
+}
+
+// Subtitle is a subtitle track of a video.
+type Subtitle struct {
+	Title    string
+	Language string
+	// Format is the subtitle format, like "srt", "vtt" or "ass".
+	Format string
+	URL    string
+	// FileID and PickCode are of the subtitle file in the cloud storage,
+	// they are empty for an embedded subtitle.
+	FileID   string
+	PickCode string
+	// Embedded is true if the subtitle is extracted from the video or
+	// generated by 115, rather than a file next to it.
+	Embedded bool
+}
+
+type SubtitleResp struct {
+	BasicResp
+	Data struct {
+		List []struct {
+			SID      string `json:"sid"`
+			Language string `json:"language"`
+			Title    string `json:"title"`
+			URL      string `json:"url"`
+			Type     string `json:"type"`
+			FileID   string `json:"file_id"`
+			PickCode string `json:"pick_code"`
+		} `json:"list"`
+	} `json:"data"`
+}
+
+// GetVideoSubtitles gets the subtitle tracks of a video, including the
+// subtitle files recognized by 115 and the embedded ones.
+func (c *Pan115Client) GetVideoSubtitles(pickCode string) ([]Subtitle, error) {
+	return c.GetVideoSubtitlesContext(context.Background(), pickCode)
+}
+
+// GetVideoSubtitlesContext is like GetVideoSubtitles but with a context.
+func (c *Pan115Client) GetVideoSubtitlesContext(ctx context.Context, pickCode string) ([]Subtitle, error) {
+	if pickCode == "" {
+		return nil, ErrPickCodeIsEmpty
+	}
+	result := SubtitleResp{}
+	req := c.NewRequest().
+		SetContext(ctx).
+		SetQueryParam("pickcode", pickCode).
+		ForceContentType("application/json;charset=UTF-8").
+		SetResult(&result)
+	resp, err := req.Get(ApiVideoSubtitle)
+	if err = CheckErr(err, &result, resp); err != nil {
+		return nil, err
+	}
+	subtitles := make([]Subtitle, 0, len(result.Data.List))
+	for _, s := range result.Data.List {
+		format := strings.ToLower(s.Type)
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(path.Ext(s.Title)), ".")
+		}
+		subtitles = append(subtitles, Subtitle{
+			Title:    s.Title,
+			Language: s.Language,
+			Format:   format,
+			URL:      s.URL,
+			FileID:   s.FileID,
+			PickCode: s.PickCode,
+			Embedded: s.FileID == "",
+		})
+	}
+	return subtitles, nil
+}
+
+// GetTranscodeStatus gets the transcoding status of a video, a freshly uploaded
+// video can not be played by m3u8 until it is transcoded.
+func (c *Pan115Client) GetTranscodeStatus(pickCode string) (*TranscodeStatus, error) {
+	return c.GetTranscodeStatusContext(context.Background(), pickCode)
+}
+
+// GetTranscodeStatusContext is like GetTranscodeStatus but with a context.
+func (c *Pan115Client) GetTranscodeStatusContext(ctx context.Context, pickCode string) (*TranscodeStatus, error) {
+	if pickCode == "" {
+		return nil, ErrPickCodeIsEmpty
+	}
+	result := VideoInfoResp{}
+	req := c.NewRequest().
+		SetContext(ctx).
+		SetQueryParams(map[string]string{
+			"pickcode": pickCode,
+			"share_id": "0",
+			"local":    "1",
+		}).
+		ForceContentType("application/json;charset=UTF-8").
+		SetResult(&result)
+	resp, err := req.Get(ApiVideoInfo)
+	if err = CheckErr(err, &result, resp); err != nil {
+		return nil, err
+	}
+	return &TranscodeStatus{
+		Ready:      result.FileStatus == 1 && result.VideoURL != "",
+		FileStatus: int(result.FileStatus),
+		Duration:   time.Duration(float64(result.PlayLong) * float64(time.Second)),
+		Width:      int(result.Width),
+		Height:     int(result.Height),
+	}, nil
+}
+
+// GetVideoM3U8 gets and parses the m3u8 master playlist of a video, it returns
+// ErrVideoNotReady if the video is still transcoding.
+func (c *Pan115Client) GetVideoM3U8(pickCode string) (*M3U8Playlist, error) {
+	return c.GetVideoM3U8Context(context.Background(), pickCode)
+}
+
+// GetVideoM3U8Context is like GetVideoM3U8 but with a context.
+func (c *Pan115Client) GetVideoM3U8Context(ctx context.Context, pickCode string) (*M3U8Playlist, error) {
+	playlist, _, err := c.getVideoM3U8(ctx, pickCode)
+	return playlist, err
+}
+
+// getVideoM3U8 is like GetVideoM3U8Context but also returns the headers of
+// the request.
+func (c *Pan115Client) getVideoM3U8(ctx context.Context, pickCode string) (*M3U8Playlist, http.Header, error) {
+	if pickCode == "" {
+		return nil, nil, ErrPickCodeIsEmpty
+	}
+	playlistURL := fmt.Sprintf(ApiVideoM3U8, pickCode)
+	var (
+		body, base string
+		header     http.Header
+		err        error
+	)
+	// 115 may answer the first request with an empty playlist and the
+	// real one on the second
+	for i := 0; i < 2; i++ {
+		if body, base, header, err = c.getPlaylist(ctx, playlistURL); err != nil {
+			return nil, nil, err
+		}
+		if strings.HasPrefix(strings.TrimSpace(body), "#EXTM3U") {
+			break
+		}
+	}
+	variants, err := parseMasterPlaylist(body, base)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &M3U8Playlist{URL: playlistURL, Variants: variants}, header, nil
+}
+
+// getPlaylist gets a playlist with the session of the client, it returns the
+// body, the base URL of its entries and the headers of the request.
+func (c *Pan115Client) getPlaylist(ctx context.Context, playlistURL string) (body, base string, header http.Header, err error) {
+	resp, err := c.NewRequest().SetContext(ctx).Get(playlistURL)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return "", "", nil, errors.Wrapf(ErrVideoNotReady, "status %s", resp.Status())
+	}
+	header = resp.Request.Header.Clone()
+	if raw := resp.Request.RawRequest; raw != nil {
+		header = raw.Header.Clone()
+	}
+	return resp.String(), playlistURL, header, nil
+}
+
+// StreamInfo is a playable variant of a video.
+type StreamInfo struct {
+	Variant
+	Duration time.Duration
+	Segments int
+	// Header is the headers of the authenticated request, including the
+	// User-Agent and the cookies, which a player needs to fetch the playlist.
+	Header http.Header
+}
+
+// GetPlayableStreams gets the variants of a video whose playlists are fetched
+// with the session of the client and have segments. It returns ErrVideoNotReady
+// if none is playable.
+func (c *Pan115Client) GetPlayableStreams(pickCode string) ([]StreamInfo, error) {
+	return c.GetPlayableStreamsContext(context.Background(), pickCode)
+}
+
+// GetPlayableStreamsContext is like GetPlayableStreams but with a context.
+func (c *Pan115Client) GetPlayableStreamsContext(ctx context.Context, pickCode string) ([]StreamInfo, error) {
+	playlist, header, err := c.getVideoM3U8(ctx, pickCode)
+	if err != nil {
+		return nil, err
+	}
+	var streams []StreamInfo
+	for _, v := range playlist.Variants {
+		body, _, _, err := c.getPlaylist(ctx, v.URL)
+		if errors.Is(err, ErrVideoNotReady) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		segments, duration := parseMediaPlaylist(body)
+		if segments == 0 {
+			continue
+		}
+		streams = append(streams, StreamInfo{Variant: v, Duration: duration, Segments: segments, Header: header})
+	}
+	if len(streams) == 0 {
+		return nil, errors.Wrap(ErrVideoNotReady, "no playable variant")
+	}
+	return streams, nil
+}
+
+// parseMediaPlaylist counts the segments of a HLS media playlist and sums their durations.
+func parseMediaPlaylist(body string) (segments int, duration time.Duration) {
+	if !strings.HasPrefix(strings.TrimSpace(body), "#EXTM3U") {
+		return 0, 0
+	}
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "#EXTINF:") {
+			continue
+		}
+		secs, _, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+		if f, err := strconv.ParseFloat(strings.TrimSpace(secs), 64); err == nil {
+			duration += time.Duration(f * float64(time.Second))
+		}
+		segments++
+	}
+	return segments, duration
+}
+
+// ParseMasterPlaylist parses the variants of a HLS master playlist, variant
+// URLs are kept as written. An attribute list may span multiple lines when a
+// line ends with a backslash or a comma or inside a quoted value.
+func ParseMasterPlaylist(body string) ([]Variant, error) {
+	return parseMasterPlaylist(body, "")
+}
+
+// parseMasterPlaylist is like ParseMasterPlaylist but relative variant URLs
+// are resolved against base.
+func parseMasterPlaylist(body, base string) ([]Variant, error) {
+	if !strings.HasPrefix(strings.TrimSpace(body), "#EXTM3U") {
+		return nil, errors.Wrap(ErrVideoNotReady, "not a m3u8 playlist")
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	var variants []Variant
+	var variant *Variant
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			for continued(line) && scanner.Scan() {
+				line = strings.TrimSuffix(line, `\`) + strings.TrimSpace(scanner.Text())
+			}
+			variant = parseStreamInf(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))
+		case strings.HasPrefix(line, "#"):
+		case variant != nil:
+			variant.URL = line
+			if base != "" {
+				u, err := baseURL.Parse(line)
+				if err != nil {
+					return nil, err
+				}
+				variant.URL = u.String()
+			}
+			variants = append(variants, *variant)
+			variant = nil
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(variants) == 0 {
+		return nil, errors.Wrap(ErrVideoNotReady, "no variant in playlist")
+	}
+	return variants, nil
+}
+
+func parseStreamInf(attrs string) *Variant {
+	v := &Variant{}
+	for key, value := range parseAttributes(attrs) {
+		switch key {
+		case "BANDWIDTH":
+			v.Bandwidth, _ = strconv.ParseInt(value, 10, 64)
+		case "RESOLUTION":
+			w, h, _ := strings.Cut(value, "x")
+			v.Width, _ = strconv.Atoi(w)
+			v.Height, _ = strconv.Atoi(h)
+		case "CODECS":
+			v.Codecs = value
+		case "NAME":
+			v.Name = value
+		}
+	}
+	return v
+}
+
+// continued reports whether the attribute list of a tag continues on the next line.
+func continued(line string) bool {
+	return strings.HasSuffix(line, `\`) || strings.HasSuffix(line, ",") || strings.Count(line, `"`)%2 == 1
+}
+
+// parseAttributes parses a HLS attribute list, commas in quoted values are kept.
+func parseAttributes(s string) map[string]string {
+	attrs := make(map[string]string)
+	for s != "" {
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		key = strings.TrimSpace(key)
+		rest = strings.TrimLeft(rest, " ")
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+			_, rest, _ = strings.Cut(rest, ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		attrs[key] = strings.TrimSpace(value)
+		s = rest
+	}
+	return attrs
+}
@@ -0,0 +1,62 @@
+package driver
+
+import (
+	"context"
+	"io/fs"
+	"path"
+)
+
+// WalkFunc is called by Walk for each file or directory.
+// Returning fs.SkipDir from a directory skips its contents, from a file skips
+// the remaining files of its parent, any other error stops the walk.
+type WalkFunc func(path string, file *File) error
+
+// Walk walks the directory tree rooted at rootCID depth-first, calling fn for
+// each file or directory in it (the root itself excluded). Files are listed
+// in natural order of their names.
+func (c *Pan115Client) Walk(rootCID string, fn WalkFunc, opts ...GetFileOptions) error {
+	return c.WalkContext(context.Background(), rootCID, fn, opts...)
+}
+
+// WalkContext is like Walk but with a context.
+func (c *Pan115Client) WalkContext(ctx context.Context, rootCID string, fn WalkFunc, opts ...GetFileOptions) error {
+	if rootCID == "" {
+		rootCID = "0"
+	}
+	listOpts := append([]GetFileOptions{
+		WithApiURL(ApiFileListByName),
+		WithOrder(FileOrderByName),
+		WithAsc(true),
+		WithNatsort(true),
+		WithLimit(MaxDirPageLimit),
+	}, opts...)
+	visited := map[string]bool{}
+	return c.walk(ctx, rootCID, "/", fn, listOpts, visited)
+}
+
+func (c *Pan115Client) walk(ctx context.Context, cid, dir string, fn WalkFunc, opts []GetFileOptions, visited map[string]bool) error {
+	if visited[cid] {
+		return nil
+	}
+	visited[cid] = true
+
+	it := c.ListIteratorContext(ctx, cid, opts...)
+	for f, ok := it.Next(); ok; f, ok = it.Next() {
+		p := path.Join(dir, f.Name)
+		if err := fn(p, f); err != nil {
+			if err != fs.SkipDir {
+				return err
+			}
+			if !f.IsDirectory {
+				return nil
+			}
+			continue
+		}
+		if f.IsDirectory {
+			if err := c.walk(ctx, f.FileID, p, fn, opts, visited); err != nil {
+				return err
+			}
+		}
+	}
+	return it.Err()
+}
@@ -0,0 +1,326 @@
+package driver
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/webdav"
+)
+
+// webDAVFS implements webdav.FileSystem by the absolute paths of the cloud storage.
+type webDAVFS struct {
+	c *Pan115Client
+}
+
+// NewWebDAVFS returns a webdav.FileSystem backed by c, it can be served by
+// webdav.Handler. Files are read by range requests, and written to a temporary
+// file which is uploaded on close.
+func NewWebDAVFS(c *Pan115Client) webdav.FileSystem {
+	return &webDAVFS{c: c}
+}
+
+func (w *webDAVFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	name = cleanPath(name)
+	if name == "/" {
+		return pathError("mkdir", name, ErrExist)
+	}
+	dir, base := path.Split(name)
+	pid, err := w.c.dirCID(ctx, cleanPath(dir))
+	if err != nil {
+		return pathError("mkdir", name, err)
+	}
+	if _, err = w.c.MkdirContext(ctx, pid, base); err != nil {
+		return pathError("mkdir", name, err)
+	}
+	w.c.InvalidatePathCache(name)
+	return nil
+}
+
+func (w *webDAVFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	name = cleanPath(name)
+	f, err := w.c.StatByPathContext(ctx, name)
+	if err != nil && !errors.Is(err, ErrNotExist) {
+		return nil, pathError("open", name, err)
+	}
+	exists := err == nil
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) == 0 {
+		if !exists {
+			return nil, pathError("open", name, ErrNotExist)
+		}
+		if f.IsDirectory {
+			return &davDir{ctx: ctx, c: w.c, name: name, file: f}, nil
+		}
+		return &davReader{rangeReader: w.c.newRangeReader(ctx, f.PickCode, f.Size), name: name, file: f}, nil
+	}
+
+	switch {
+	case exists && f.IsDirectory:
+		return nil, pathError("open", name, errors.Wrap(ErrWrongParams, "is a directory"))
+	case exists && flag&os.O_EXCL != 0:
+		return nil, pathError("open", name, ErrExist)
+	case !exists && flag&os.O_CREATE == 0:
+		return nil, pathError("open", name, ErrNotExist)
+	}
+	dir, _ := path.Split(name)
+	pid, err := w.c.dirCID(ctx, cleanPath(dir))
+	if err != nil {
+		return nil, pathError("open", name, err)
+	}
+	tmp, err := os.CreateTemp("", "115webdav-*")
+	if err != nil {
+		return nil, err
+	}
+	dw := &davWriter{ctx: ctx, c: w.c, name: name, pid: pid, tmp: tmp}
+	if exists {
+		dw.old = f
+		if flag&os.O_TRUNC == 0 {
+			r := w.c.newRangeReader(ctx, f.PickCode, f.Size)
+			_, err = io.Copy(tmp, r)
+			_ = r.Close()
+			if err != nil {
+				dw.discard()
+				return nil, pathError("open", name, err)
+			}
+			if flag&os.O_APPEND == 0 {
+				_, _ = tmp.Seek(0, io.SeekStart)
+			}
+		}
+	}
+	return dw, nil
+}
+
+func (w *webDAVFS) RemoveAll(ctx context.Context, name string) error {
+	name = cleanPath(name)
+	if name == "/" {
+		return pathError("remove", name, errors.Wrap(ErrWrongParams, "can not remove the root"))
+	}
+	f, err := w.c.StatByPathContext(ctx, name)
+	if err != nil {
+		return pathError("remove", name, err)
+	}
+	if err = w.c.DeleteContext(ctx, f.FileID); err != nil {
+		return pathError("remove", name, err)
+	}
+	w.c.InvalidatePathCache(name)
+	return nil
+}
+
+func (w *webDAVFS) Rename(ctx context.Context, oldName, newName string) error {
+	oldName, newName = cleanPath(oldName), cleanPath(newName)
+	if oldName == "/" || newName == "/" {
+		return pathError("rename", oldName, errors.Wrap(ErrWrongParams, "can not rename the root"))
+	}
+	f, err := w.c.StatByPathContext(ctx, oldName)
+	if err != nil {
+		return pathError("rename", oldName, err)
+	}
+	oldDir, oldBase := path.Split(oldName)
+	newDir, newBase := path.Split(newName)
+	if newDir != oldDir {
+		pid, err := w.c.dirCID(ctx, cleanPath(newDir))
+		if err != nil {
+			return pathError("rename", newName, err)
+		}
+		if err = w.c.MoveContext(ctx, pid, f.FileID); err != nil {
+			return pathError("rename", oldName, err)
+		}
+	}
+	if newBase != oldBase {
+		if err = w.c.RenameContext(ctx, f.FileID, newBase); err != nil {
+			return pathError("rename", oldName, err)
+		}
+	}
+	w.c.InvalidatePathCache(oldName)
+	w.c.InvalidatePathCache(newName)
+	return nil
+}
+
+func (w *webDAVFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	name = cleanPath(name)
+	f, err := w.c.StatByPathContext(ctx, name)
+	if err != nil {
+		return nil, pathError("stat", name, err)
+	}
+	return &fileInfo{name: path.Base(name), file: f}, nil
+}
+
+// ETag returns the SHA1 of a file, it implements webdav.ETager.
+func (fi *fileInfo) ETag(ctx context.Context) (string, error) {
+	if fi.file.Sha1 == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return `"` + fi.file.Sha1 + `"`, nil
+}
+
+// pathError converts the errors of ErrNotExist and ErrExist to the ones of
+// io/fs, so that os.IsNotExist and os.IsExist work.
+func pathError(op, name string, err error) error {
+	switch {
+	case errors.Is(err, ErrNotExist):
+		err = fs.ErrNotExist
+	case errors.Is(err, ErrExist):
+		err = fs.ErrExist
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// davDir is an opened directory.
+type davDir struct {
+	ctx  context.Context
+	c    *Pan115Client
+	name string
+	file *File
+
+	children []fs.FileInfo
+	listed   bool
+	pos      int
+}
+
+func (d *davDir) Read(p []byte) (int, error) {
+	return 0, pathError("read", d.name, errors.Wrap(ErrWrongParams, "is a directory"))
+}
+
+func (d *davDir) Write(p []byte) (int, error) {
+	return 0, pathError("write", d.name, errors.Wrap(ErrWrongParams, "is a directory"))
+}
+
+func (d *davDir) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == io.SeekStart {
+		d.pos = 0
+		return 0, nil
+	}
+	return 0, pathError("seek", d.name, errors.Wrap(ErrWrongParams, "is a directory"))
+}
+
+func (d *davDir) Readdir(count int) ([]fs.FileInfo, error) {
+	if !d.listed {
+		files, err := d.c.listAllFiles(d.ctx, d.file.FileID)
+		if err != nil {
+			return nil, pathError("readdir", d.name, err)
+		}
+		pc := d.c.getPathCache()
+		for i := range files {
+			f := &files[i]
+			if pc != nil {
+				file := *f
+				pc.put(path.Join(d.name, f.Name), f.FileID, &file)
+			}
+			d.children = append(d.children, &fileInfo{name: f.Name, file: f})
+		}
+		d.listed = true
+	}
+	rest := d.children[d.pos:]
+	if count <= 0 {
+		d.pos = len(d.children)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(rest) {
+		count = len(rest)
+	}
+	d.pos += count
+	return rest[:count], nil
+}
+
+func (d *davDir) Stat() (fs.FileInfo, error) {
+	return &fileInfo{name: path.Base(d.name), file: d.file}, nil
+}
+
+func (d *davDir) Close() error {
+	return nil
+}
+
+// davReader is a file opened for reading.
+type davReader struct {
+	*rangeReader
+	name string
+	file *File
+}
+
+func (r *davReader) Write(p []byte) (int, error) {
+	return 0, pathError("write", r.name, fs.ErrPermission)
+}
+
+func (r *davReader) Readdir(count int) ([]fs.FileInfo, error) {
+	return nil, pathError("readdir", r.name, errors.Wrap(ErrWrongParams, "not a directory"))
+}
+
+func (r *davReader) Stat() (fs.FileInfo, error) {
+	return &fileInfo{name: path.Base(r.name), file: r.file}, nil
+}
+
+// davWriter is a file opened for writing, the content is uploaded on close and
+// replaces the old file if any.
+type davWriter struct {
+	ctx  context.Context
+	c    *Pan115Client
+	name string
+	pid  string
+	old  *File
+	tmp  *os.File
+}
+
+func (w *davWriter) Read(p []byte) (int, error) {
+	return w.tmp.Read(p)
+}
+
+func (w *davWriter) Write(p []byte) (int, error) {
+	return w.tmp.Write(p)
+}
+
+func (w *davWriter) Seek(offset int64, whence int) (int64, error) {
+	return w.tmp.Seek(offset, whence)
+}
+
+func (w *davWriter) Readdir(count int) ([]fs.FileInfo, error) {
+	return nil, pathError("readdir", w.name, errors.Wrap(ErrWrongParams, "not a directory"))
+}
+
+func (w *davWriter) Stat() (fs.FileInfo, error) {
+	fi, err := w.tmp.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &fileInfo{name: path.Base(w.name), file: &File{Name: path.Base(w.name), Size: fi.Size(), UpdateTime: fi.ModTime()}}, nil
+}
+
+func (w *davWriter) Close() error {
+	defer w.discard()
+	fi, err := w.tmp.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err = w.tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	base := path.Base(w.name)
+	f, err := w.c.UploadStreamContext(w.ctx, w.tmp, fi.Size(), w.pid, base)
+	if err != nil {
+		return pathError("close", w.name, err)
+	}
+	// the old file is removed after the upload succeeded, then 115 may have
+	// renamed the new one for the name conflict
+	if w.old != nil && w.old.FileID != f.FileID {
+		if err = w.c.DeleteContext(w.ctx, w.old.FileID); err != nil {
+			return pathError("close", w.name, err)
+		}
+		if f.Name != base {
+			if err = w.c.RenameContext(w.ctx, f.FileID, base); err != nil {
+				return pathError("close", w.name, err)
+			}
+		}
+	}
+	w.c.InvalidatePathCache(w.name)
+	return nil
+}
+
+func (w *davWriter) discard() {
+	_ = w.tmp.Close()
+	_ = os.Remove(w.tmp.Name())
+}